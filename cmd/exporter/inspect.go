@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/BattlesnakeOfficial/exporter/render"
+)
+
+// inspect prints the metadata embedded in exported GIFs and PNGs.
+// It returns the exit code, which is non-zero if any of the files couldn't be inspected.
+func inspect(files []string) int {
+	if len(files) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: exporter inspect FILE...")
+		return 2
+	}
+
+	code := 0
+	for _, file := range files {
+		m, err := inspectFile(file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", file, err)
+			code = 1
+			continue
+		}
+		fmt.Printf("%s:\n", file)
+		fmt.Printf("  game:     %s\n", m.GameID)
+		fmt.Printf("  turns:    %d-%d\n", m.FirstTurn, m.LastTurn)
+		fmt.Printf("  version:  %s\n", m.Version)
+		fmt.Printf("  engine:   %s\n", m.EngineHost)
+	}
+	return code
+}
+
+func inspectFile(file string) (*render.Metadata, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return render.ReadMetadata(f)
+}
@@ -1,10 +1,25 @@
 package main
 
 import (
+	"fmt"
+	"os"
+
 	"github.com/BattlesnakeOfficial/exporter/http"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "inspect":
+			os.Exit(inspect(os.Args[2:]))
+		case "media":
+			os.Exit(mediaCommand(os.Args[2:]))
+		default:
+			fmt.Fprintf(os.Stderr, "unknown command: %s\nusage: exporter [inspect FILE... | media sync]\n", os.Args[1])
+			os.Exit(2)
+		}
+	}
+
 	httpServer := http.NewServer()
 	httpServer.Run()
 }
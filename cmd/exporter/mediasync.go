@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/BattlesnakeOfficial/exporter/media"
+)
+
+// mediaCommand runs the media subcommands, and returns the exit code.
+func mediaCommand(args []string) int {
+	if len(args) == 0 || args[0] != "sync" {
+		fmt.Fprintln(os.Stderr, "usage: exporter media sync [-dir DIR] [-server URL] [-heads NAME,...] [-tails NAME,...]")
+		return 2
+	}
+	return mediaSync(args[1:])
+}
+
+// mediaSync mirrors customizations from the media server into an asset bundle directory.
+// It returns a non-zero exit code if anything couldn't be synced.
+func mediaSync(args []string) int {
+	flags := flag.NewFlagSet("exporter media sync", flag.ContinueOnError)
+	dir := flags.String("dir", "media/bundle", "the asset bundle directory to sync into")
+	server := flags.String("server", "", "the media server to sync from (default the Battlesnake media server)")
+	heads := flags.String("heads", "", "comma-separated heads to add to the bundle, as well as the ones already in it")
+	tails := flags.String("tails", "", "comma-separated tails to add to the bundle, as well as the ones already in it")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	result, err := media.SyncBundle(media.ServerSource{URL: *server}, *dir, splitNames(*heads), splitNames(*tails))
+	for _, p := range result.Added {
+		fmt.Printf("added     %s\n", p)
+	}
+	for _, p := range result.Updated {
+		fmt.Printf("updated   %s\n", p)
+	}
+	for _, p := range result.Removed {
+		fmt.Printf("removed   %s\n", p)
+	}
+	fmt.Printf("%d unchanged\n", len(result.Unchanged))
+
+	failed := make([]string, 0, len(result.Failed))
+	for p := range result.Failed {
+		failed = append(failed, p)
+	}
+	sort.Strings(failed)
+	for _, p := range failed {
+		fmt.Fprintf(os.Stderr, "failed    %s: %v\n", p, result.Failed[p])
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "sync failed: %v\n", err)
+		return 1
+	}
+	if len(failed) > 0 {
+		return 1
+	}
+	return 0
+}
+
+func splitNames(s string) []string {
+	var names []string
+	for _, name := range strings.Split(s, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
@@ -1,6 +1,7 @@
 package engine
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -8,19 +9,27 @@ import (
 	"net/http"
 )
 
+// DefaultHost is the engine that's used when no host is given.
+const DefaultHost = "https://engine.battlesnake.com"
+
 var ErrNotFound = errors.New("resource not found")
 
-func apiCall(path, host string) ([]byte, error) {
+func apiCall(ctx context.Context, path, host string) ([]byte, error) {
 	if len(host) == 0 {
-		host = "https://engine.battlesnake.com"
+		host = DefaultHost
 	}
 	url := fmt.Sprintf("%s/%s", host, path)
 	client := http.Client{}
 
-	response, err := client.Get(url)
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := client.Do(request)
 	if err != nil {
 		return nil, err
 	}
+	defer response.Body.Close()
 	if response.StatusCode == http.StatusNotFound {
 		return nil, ErrNotFound
 	}
@@ -36,9 +45,9 @@ func apiCall(path, host string) ([]byte, error) {
 	return body, nil
 }
 
-func getFrames(gameID, host string, offset int, limit int) ([]*GameFrame, error) {
+func getFrames(ctx context.Context, gameID, host string, offset int, limit int) ([]*GameFrame, error) {
 	path := fmt.Sprintf("games/%s/frames?offset=%d&limit=%d", gameID, offset, limit)
-	body, err := apiCall(path, host)
+	body, err := apiCall(ctx, path, host)
 	if err != nil {
 		return nil, err
 	}
@@ -53,9 +62,9 @@ func getFrames(gameID, host string, offset int, limit int) ([]*GameFrame, error)
 }
 
 // GetGame is commented
-func GetGame(gameID, host string) (*Game, error) {
+func GetGame(ctx context.Context, gameID, host string) (*Game, error) {
 	path := fmt.Sprintf("games/%s", gameID)
-	body, err := apiCall(path, host)
+	body, err := apiCall(ctx, path, host)
 	if err != nil {
 		return nil, err
 	}
@@ -70,8 +79,8 @@ func GetGame(gameID, host string) (*Game, error) {
 }
 
 // GetGameFrame is commented
-func GetGameFrame(gameID, host string, frameNum int) (*GameFrame, error) {
-	gameFrames, err := getFrames(gameID, host, frameNum, 1)
+func GetGameFrame(ctx context.Context, gameID, host string, frameNum int) (*GameFrame, error) {
+	gameFrames, err := getFrames(ctx, gameID, host, frameNum, 1)
 	if err != nil {
 		return nil, err
 	}
@@ -80,7 +89,7 @@ func GetGameFrame(gameID, host string, frameNum int) (*GameFrame, error) {
 }
 
 // GetGameFrames is commented
-func GetGameFrames(gameID, host string, offset int, limit int) ([]*GameFrame, error) {
+func GetGameFrames(ctx context.Context, gameID, host string, offset int, limit int) ([]*GameFrame, error) {
 	var gameFrames []*GameFrame
 
 	if limit <= 0 {
@@ -93,7 +102,7 @@ func GetGameFrames(gameID, host string, offset int, limit int) ([]*GameFrame, er
 			batchSize = (limit - len(gameFrames))
 		}
 
-		newFrames, err := getFrames(gameID, host, offset, batchSize)
+		newFrames, err := getFrames(ctx, gameID, host, offset, batchSize)
 		if err != nil {
 			return nil, err
 		}
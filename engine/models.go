@@ -30,6 +30,9 @@ type GameFrame struct {
 	Hazards []Point `json:"Hazards"`
 }
 
+// MaxBoardSize is the largest width or height, in squares, of the boards that the engine creates.
+const MaxBoardSize = 25
+
 type Game struct {
 	ID     string `json:"ID"`
 	Status string `json:"Status"`
@@ -1,14 +1,19 @@
 package fixtures
 
 import (
+	"encoding/json"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path"
 	"runtime"
+	"strconv"
+	"strings"
 	"testing"
 
+	"github.com/BattlesnakeOfficial/exporter/engine"
+	"github.com/BattlesnakeOfficial/exporter/render"
 	"github.com/stretchr/testify/require"
 )
 
@@ -17,6 +22,52 @@ func StubEngineServer(handler http.HandlerFunc) *httptest.Server {
 	return httptest.NewServer(handler)
 }
 
+// Helper for parsing a hand-written ASCII board (see render.ASCIIToGameFrame) into a game and game frame
+func ASCIIGameFrame(t *testing.T, board string) (*engine.Game, *engine.GameFrame) {
+	game, gameFrame, err := render.ASCIIToGameFrame(strings.NewReader(board))
+	require.NoError(t, err)
+
+	return game, gameFrame
+}
+
+// Helper for setting up an engine endpoint that serves a game made from hand-written ASCII boards, one per frame.
+// The game size is taken from the first board.
+func StubEngineServerFromASCII(t *testing.T, gameID string, boards ...string) *httptest.Server {
+	require.NotEmpty(t, boards)
+
+	var game *engine.Game
+	var gameFrames []*engine.GameFrame
+	for i, board := range boards {
+		g, gf := ASCIIGameFrame(t, board)
+		if game == nil {
+			game = g
+		}
+		if !strings.Contains(board, "turn:") {
+			gf.Turn = i
+		}
+		gameFrames = append(gameFrames, gf)
+	}
+	game.ID = gameID
+	game.Status = "complete"
+
+	return StubEngineServer(func(res http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/games/" + gameID:
+			_ = json.NewEncoder(res).Encode(map[string]interface{}{"Game": game})
+		case "/games/" + gameID + "/frames":
+			offset, _ := strconv.Atoi(req.URL.Query().Get("offset"))
+			limit, _ := strconv.Atoi(req.URL.Query().Get("limit"))
+			frames := []*engine.GameFrame{}
+			for i := offset; i < len(gameFrames) && i < offset+limit; i++ {
+				frames = append(frames, gameFrames[i])
+			}
+			_ = json.NewEncoder(res).Encode(map[string]interface{}{"count": len(frames), "frames": frames})
+		default:
+			res.WriteHeader(http.StatusNotFound)
+		}
+	})
+}
+
 // Helper for creating a request and recording the response
 func TestRequest(t *testing.T, method, url string, body io.Reader) (*http.Request, *httptest.ResponseRecorder) {
 	clientRequest, err := http.NewRequest(method, url, body)
@@ -1,16 +1,23 @@
 package http
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"image"
 	"image/color"
 	"image/png"
+	"io"
 	"math"
 	"net/http"
+	"net/url"
 	"os"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"goji.io/v3/pat"
 
@@ -32,22 +39,99 @@ const maxGIFResolution = 504 * 504
 // allowedPixelsPerSquare is a list of resolutions that the API will allow.
 var allowedPixelsPerSquare = []int{10, 20, 30, 40}
 
+// maxGIFFrameDelay is the longest delay (in hundredths of a second) that can be requested for GIF frames.
+const maxGIFFrameDelay = 1000
+
+// maxGIFFrameStep is the most turns that can be skipped between GIF frames.
+const maxGIFFrameStep = 100
+
+// speedPresets are named frame delays (in hundredths of a second) for the speed parameter.
+// The smallest delay is 2, because most browsers slow down GIFs with shorter delays.
+var speedPresets = map[string]int{
+	"slow":    16,
+	"normal":  render.GIFFrameDelay,
+	"fast":    4,
+	"fastest": 2,
+}
+
+// speedPresetNames lists the speed presets from slowest to fastest, for error messages.
+var speedPresetNames = []string{"slow", "normal", "fast", "fastest"}
+
+// defaultPixelsPerSquare is the resolution used by the legacy endpoints, which don't have a width/height.
+const defaultPixelsPerSquare = 20
+
+// defaultCustomizationSize is the width and height of customization PNGs when a request doesn't choose a size.
+const defaultCustomizationSize = 100
+const minCustomizationSize = 10
+const maxCustomizationSize = 1000
+
+// maxGalleryTiles is how many customizations are shown on each page of a gallery.
+const maxGalleryTiles = 100
+
+// maxASCIIBoardBytes is the largest hand-written ASCII board that can be posted for rendering.
+const maxASCIIBoardBytes = 64 * 1024
+
+// maxStreamFrameDelay is the longest delay between frames (in hundredths of a second) when streaming a game as text.
+// This stops streams from holding connections open for too long.
+const maxStreamFrameDelay = 500
+
+// maxStreamDuration and maxStreamFrames limit how long a game can be streamed as text for.
+// The stream ends when either limit is reached, even if there are more frames.
+const maxStreamDuration = 10 * time.Minute
+const maxStreamFrames = 1000
+
+// defaultStreamConcurrency is how many games can be streamed as text at the same time, by default.
+const defaultStreamConcurrency = 100
+
+// streamSlots limits how many games can be streamed as text at the same time.
+// Streams hold their connection open for a long time, so they're limited separately from renders.
+var streamSlots = make(chan struct{}, defaultStreamConcurrency)
+
+// streamsStopped is cancelled when the server shuts down, so that streams end instead of holding up the shutdown.
+var streamsStopped, stopStreams = context.WithCancel(context.Background())
+
 var errBadRequest = fmt.Errorf("bad request")
 var errBadColor = fmt.Errorf("color parameter should have the format #FFFFFF")
+var errBadCustomizationSize = fmt.Errorf("size parameter should be between %d and %d", minCustomizationSize, maxCustomizationSize)
+var errGalleryPageNotFound = errors.New("gallery page not found")
 
-var reCustomizationParam = regexp.MustCompile(`^[A-Za-z-0-9#]{1,32}$`)
+// customization names can be namespaced to a media source, like "private:beluga"
+var reCustomizationParam = regexp.MustCompile(`^(?:[a-z0-9-]{1,32}:)?[A-Za-z-0-9#]{1,32}$`)
 var reColorParam = regexp.MustCompile(`^#?[A-Fa-f0-9]{6}$`)
+var reSnakeIDParam = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// defaultWatermark is the watermark drawn when a request doesn't override it.
+// It's configured when the server is created.
+var defaultWatermark = render.DefaultWatermark
 
 func handleVersion(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprint(w, appVersion())
+}
+
+// appVersion gets the version of the exporter.
+func appVersion() string {
 	version := os.Getenv("APP_VERSION")
 	if len(version) == 0 {
 		version = "unknown"
 	}
-	fmt.Fprint(w, version)
+	return version
+}
+
+// exportMetadata creates the metadata embedded in exported files, to record where they came from.
+func exportMetadata(gameID string, gameFrames []*engine.GameFrame, engineURL string) *render.Metadata {
+	host := engineURL
+	if host == "" {
+		host = engine.DefaultHost
+	}
+	// only the host is recorded, in case the URL has credentials or other private details
+	if u, err := url.Parse(host); err == nil && u.Host != "" {
+		host = u.Host
+	}
+	return render.NewMetadata(gameID, gameFrames, appVersion(), host)
 }
 
-var reAvatarParams = regexp.MustCompile(`^/(?:[a-z-]{1,32}:[A-Za-z-0-9#]{0,32}/)*(?P<width>[0-9]{2,4})x(?P<height>[0-9]{2,4}).(?P<ext>[a-z]{3,4})$`)
-var reAvatarCustomizations = regexp.MustCompile(`(?P<key>[a-z-]{1,32}):(?P<value>[A-Za-z-0-9#]{0,32})`)
+var reAvatarParams = regexp.MustCompile(`^/(?:[a-z-]{1,32}:(?:[a-z0-9-]{1,32}:)?[A-Za-z-0-9#]{0,32}/)*(?P<width>[0-9]{2,4})x(?P<height>[0-9]{2,4}).(?P<ext>[a-z]{3,4})$`)
+var reAvatarCustomizations = regexp.MustCompile(`(?P<key>[a-z-]{1,32}):(?P<value>(?:[a-z0-9-]{1,32}:)?[A-Za-z-0-9#]{0,32})`)
 
 func handleAvatar(w http.ResponseWriter, r *http.Request) {
 	subPath := strings.TrimPrefix(r.URL.Path, "/avatars")
@@ -95,7 +179,8 @@ func handleAvatar(w http.ResponseWriter, r *http.Request) {
 				if errors.Is(err, media.ErrNotFound) {
 					handleBadRequest(w, r, errBadRequest)
 				} else {
-					handleError(w, r, err, http.StatusInternalServerError)
+					// the media source's SVG may be rejected when it's sanitized
+					handleError(w, r, err, rasterizeErrorStatus(err))
 				}
 				return
 			}
@@ -105,7 +190,8 @@ func handleAvatar(w http.ResponseWriter, r *http.Request) {
 				if errors.Is(err, media.ErrNotFound) {
 					handleBadRequest(w, r, errBadRequest)
 				} else {
-					handleError(w, r, err, http.StatusInternalServerError)
+					// the media source's SVG may be rejected when it's sanitized
+					handleError(w, r, err, rasterizeErrorStatus(err))
 				}
 				return
 			}
@@ -133,9 +219,9 @@ func handleAvatar(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if pExt == "png" {
-		image, err := media.ConvertSVGStringToPNG(avatarSVG, avatarSettings.Width, avatarSettings.Height)
+		image, err := media.ConvertSVGStringToPNG(r.Context(), avatarSVG, avatarSettings.Width, avatarSettings.Height)
 		if err != nil {
-			handleError(w, r, err, http.StatusInternalServerError)
+			handleError(w, r, err, rasterizeErrorStatus(err))
 			return
 		}
 
@@ -155,7 +241,7 @@ func handleCustomization(w http.ResponseWriter, r *http.Request) {
 	customizationName := pat.Param(r, "name")
 	ext := pat.Param(r, "ext")
 
-	if ext != "svg" {
+	if ext != "svg" && ext != "png" {
 		handleBadRequest(w, r, errBadRequest)
 		return
 	}
@@ -170,6 +256,16 @@ func handleCustomization(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	size := defaultCustomizationSize
+	if sizeParam := r.URL.Query().Get("size"); sizeParam != "" {
+		var err error
+		size, err = strconv.Atoi(sizeParam)
+		if err != nil || size < minCustomizationSize || size > maxCustomizationSize {
+			handleBadRequest(w, r, errBadCustomizationSize)
+			return
+		}
+	}
+
 	var customizationColor color.Color = color.Black
 	colorParam := r.URL.Query().Get("color")
 	if colorParam != "" {
@@ -196,20 +292,215 @@ func handleCustomization(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err != nil {
-		if err == media.ErrNotFound {
+		if errors.Is(err, media.ErrNotFound) {
 			handleError(w, r, err, http.StatusNotFound)
 		} else {
-			handleError(w, r, err, http.StatusInternalServerError)
+			// the media source's SVG may be rejected when it's sanitized
+			handleError(w, r, err, rasterizeErrorStatus(err))
 		}
 		return
 	}
 
 	svg = media.CustomizeSnakeSVG(svg, customizationColor, shouldFlip)
 
+	if ext == "png" {
+		image, err := render.CustomizationPNG(r.Context(), svg, size, r.URL.Query().Get("direction"))
+		if errors.Is(err, render.ErrInvalidDirection) {
+			handleBadRequest(w, r, err)
+			return
+		}
+		if err != nil {
+			handleError(w, r, err, rasterizeErrorStatus(err))
+			return
+		}
+
+		w.Header().Set("Content-Type", "image/png")
+		if err := png.Encode(w, image); err != nil {
+			log.WithError(err).Error("unable to write PNG to response stream")
+		}
+		return
+	}
+
 	w.Header().Set("Content-Type", "image/svg+xml")
 	fmt.Fprint(w, svg)
 }
 
+// customizationInfo describes a head or tail in the customization catalog.
+type customizationInfo struct {
+	Type      string `json:"type"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+	// PreviewURL is the path of the customization's SVG, which can be given the same options as the customization endpoint.
+	PreviewURL string `json:"preview_url"`
+}
+
+// customizationCatalog is the heads and tails in the customization catalog.
+type customizationCatalog struct {
+	Heads []customizationInfo `json:"heads,omitempty"`
+	Tails []customizationInfo `json:"tails,omitempty"`
+	// Complete is false when some media sources couldn't be listed, so there may be heads and tails that aren't in the catalog.
+	Complete bool `json:"complete"`
+}
+
+// listCustomizations lists the customizations of a type ("head" or "tail") that the media source has,
+// and whether every media source could be listed.
+func listCustomizations(customizationType string) ([]customizationInfo, bool, error) {
+	var list media.CustomizationList
+	var err error
+	switch customizationType {
+	case "head":
+		list, err = media.ListHeads()
+	case "tail":
+		list, err = media.ListTails()
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	infos := make([]customizationInfo, 0, len(list.Names))
+	for _, name := range list.Names {
+		info := customizationInfo{
+			Type:       customizationType,
+			Name:       name,
+			PreviewURL: fmt.Sprintf("/customizations/%s/%s.svg", customizationType, name),
+		}
+		if namespace, _, ok := strings.Cut(name, ":"); ok {
+			info.Namespace = namespace
+		}
+		infos = append(infos, info)
+	}
+	return infos, list.Complete, nil
+}
+
+func handleCustomizationCatalog(w http.ResponseWriter, r *http.Request) {
+	heads, headsComplete, err := listCustomizations("head")
+	if err != nil {
+		handleError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	tails, tailsComplete, err := listCustomizations("tail")
+	if err != nil {
+		handleError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, customizationCatalog{Heads: heads, Tails: tails, Complete: headsComplete && tailsComplete})
+}
+
+func handleCustomizationTypeCatalog(w http.ResponseWriter, r *http.Request) {
+	customizationType := pat.Param(r, "type")
+	if customizationType != "head" && customizationType != "tail" {
+		handleBadRequest(w, r, errBadRequest)
+		return
+	}
+
+	customizations, complete, err := listCustomizations(customizationType)
+	if err != nil {
+		handleError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	catalog := customizationCatalog{Complete: complete}
+	if customizationType == "head" {
+		catalog.Heads = customizations
+	} else {
+		catalog.Tails = customizations
+	}
+	writeJSON(w, catalog)
+}
+
+func handleCustomizationGallery(w http.ResponseWriter, r *http.Request) {
+	customizationType := pat.Param(r, "type")
+	ext := pat.Param(r, "ext")
+
+	if ext != "svg" && ext != "png" {
+		handleBadRequest(w, r, errBadRequest)
+		return
+	}
+
+	if customizationType != "head" && customizationType != "tail" {
+		handleBadRequest(w, r, errBadRequest)
+		return
+	}
+
+	var customizationColor color.Color = color.Black
+	colorParam := r.URL.Query().Get("color")
+	if colorParam != "" {
+		if !reColorParam.MatchString(colorParam) {
+			handleBadRequest(w, r, errBadColor)
+			return
+		}
+
+		customizationColor = parse.HexColor(colorParam)
+	}
+
+	page, err := parseIntParam(r.URL.Query(), "page", 1, 1, math.MaxInt32)
+	if err != nil {
+		handleBadRequest(w, r, err)
+		return
+	}
+
+	customizations, _, err := listCustomizations(customizationType)
+	if err != nil {
+		handleError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	// large catalogs are split into pages, so that a single gallery doesn't rasterize all of them
+	start := (page - 1) * maxGalleryTiles
+	if start >= len(customizations) {
+		handleError(w, r, errGalleryPageNotFound, http.StatusNotFound)
+		return
+	}
+	customizations = customizations[start:min(start+maxGalleryTiles, len(customizations))]
+
+	tiles := make([]render.GalleryTile, 0, len(customizations))
+	for _, c := range customizations {
+		var svg string
+		switch customizationType {
+		case "head":
+			svg, err = media.GetHeadSVG(c.Name)
+		case "tail":
+			svg, err = media.GetTailSVG(c.Name)
+		}
+		if errors.Is(err, media.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			handleError(w, r, err, http.StatusInternalServerError)
+			return
+		}
+
+		// tails are flipped to face the same way as they do on the customization endpoint
+		svg = media.CustomizeSnakeSVG(svg, customizationColor, customizationType == "tail")
+		tiles = append(tiles, render.GalleryTile{Label: c.Name, SVG: svg})
+	}
+
+	if ext == "png" {
+		image, err := render.GalleryPNG(r.Context(), tiles)
+		if err != nil {
+			handleError(w, r, err, rasterizeErrorStatus(err))
+			return
+		}
+
+		w.Header().Set("Content-Type", "image/png")
+		if err := png.Encode(w, image); err != nil {
+			log.WithError(err).Error("unable to write PNG to response stream")
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/svg+xml")
+	fmt.Fprint(w, render.GallerySVG(tiles))
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.WithError(err).Error("unable to write JSON to response stream")
+	}
+}
+
 func handleASCIIFrame(w http.ResponseWriter, r *http.Request) {
 	gameID := pat.Param(r, "game")
 	engineURL := r.URL.Query().Get("engine_url")
@@ -219,7 +510,7 @@ func handleASCIIFrame(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	game, err := engine.GetGame(gameID, engineURL)
+	game, err := engine.GetGame(r.Context(), gameID, engineURL)
 	if err != nil {
 		if errors.Is(err, engine.ErrNotFound) {
 			handleError(w, r, err, http.StatusNotFound)
@@ -229,7 +520,7 @@ func handleASCIIFrame(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	gameFrame, err := engine.GetGameFrame(game.ID, engineURL, frameID)
+	gameFrame, err := engine.GetGameFrame(r.Context(), game.ID, engineURL, frameID)
 	if err != nil {
 		if errors.Is(err, engine.ErrNotFound) {
 			handleError(w, r, err, http.StatusNotFound)
@@ -239,14 +530,119 @@ func handleASCIIFrame(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err = render.GameFrameToASCII(w, game, gameFrame); err != nil {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if r.URL.Query().Get("ansi") != "" {
+		err = render.GameFrameToANSI(w, game, gameFrame)
+	} else {
+		err = render.GameFrameToASCII(w, game, gameFrame)
+	}
+	if err != nil {
 		handleError(w, r, err, http.StatusInternalServerError)
 		return
 	}
 }
 
+// handleASCIIGame exports every frame of a game as text.
+// In streaming mode, the frames are played back one at a time, clearing the terminal between frames.
+func handleASCIIGame(w http.ResponseWriter, r *http.Request) {
+	gameID := pat.Param(r, "game")
+	engineURL := r.URL.Query().Get("engine_url")
+
+	renderFrame := render.GameFrameToASCII
+	if r.URL.Query().Get("ansi") != "" {
+		renderFrame = render.GameFrameToANSI
+	}
+
+	stream := r.URL.Query().Get("stream") != ""
+	frameDelay := render.GIFFrameDelay
+	if param := r.URL.Query().Get("frameDelay"); param != "" {
+		d, err := strconv.Atoi(param)
+		if err != nil || d < 0 || d > maxStreamFrameDelay {
+			handleBadRequest(w, r, fmt.Errorf("invalid frameDelay parameter: %s - must be between 0 and %d", param, maxStreamFrameDelay))
+			return
+		}
+		frameDelay = d
+	}
+
+	game, err := engine.GetGame(r.Context(), gameID, engineURL)
+	if err != nil {
+		if errors.Is(err, engine.ErrNotFound) {
+			handleError(w, r, err, http.StatusNotFound)
+		} else {
+			handleError(w, r, err, http.StatusInternalServerError)
+		}
+		return
+	}
+
+	gameFrames, err := engine.GetGameFrames(r.Context(), game.ID, engineURL, 0, math.MaxInt32)
+	if err != nil {
+		if errors.Is(err, engine.ErrNotFound) {
+			handleError(w, r, err, http.StatusNotFound)
+		} else {
+			handleError(w, r, err, http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if !stream {
+		if err := render.GameFramesToText(w, game, gameFrames, renderFrame); err != nil {
+			handleError(w, r, err, http.StatusInternalServerError)
+		}
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		handleError(w, r, errors.New("streaming is not supported"), http.StatusInternalServerError)
+		return
+	}
+
+	select {
+	case streamSlots <- struct{}{}:
+		defer func() { <-streamSlots }()
+	default:
+		log.WithField("url", r.URL.String()).Print("Too many streams, rejecting request")
+		w.WriteHeader(http.StatusTooManyRequests)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), maxStreamDuration)
+	defer cancel()
+	stop := context.AfterFunc(streamsStopped, cancel)
+	defer stop()
+
+	if len(gameFrames) > maxStreamFrames {
+		gameFrames = gameFrames[:maxStreamFrames]
+	}
+
+	// stop browsers from buffering the response while they guess the content type
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	for i, gf := range gameFrames {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Duration(frameDelay) * 10 * time.Millisecond):
+			}
+		}
+
+		if _, err := fmt.Fprint(w, render.ANSIClearScreen); err != nil {
+			log.WithError(err).Error("unable to write to response stream")
+			return
+		}
+		if err := render.GameFramesToText(w, game, []*engine.GameFrame{gf}, renderFrame); err != nil {
+			log.WithError(err).Error("unable to write to response stream")
+			return
+		}
+		flusher.Flush()
+	}
+}
+
 // validateDimensionsForBoard checks whether the width/height is valid for the given board width/height.
-func validateDimensionsForBoard(game *engine.Game, w, h int) error {
+// The board width/height are in squares and should be the size of the region being drawn.
+func validateDimensionsForBoard(boardWidth, boardHeight int, w, h int) error {
 
 	// handle the legacy case where w/h are 0
 	if w == 0 || h == 0 {
@@ -257,8 +653,8 @@ func validateDimensionsForBoard(game *engine.Game, w, h int) error {
 	options := make([]string, 0, len(allowedPixelsPerSquare)) // used to build a helpful error message
 	for _, r := range allowedPixelsPerSquare {
 		// should match one of the allowed resolutions
-		aw := (game.Width*r + b)
-		ah := (game.Height*r + b)
+		aw := (boardWidth*r + b)
+		ah := (boardHeight*r + b)
 		options = append(options, fmt.Sprintf("%dx%d", aw, ah))
 		if aw == w && ah == h {
 			return nil
@@ -274,14 +670,38 @@ func handleGIFFrameDimensions(w http.ResponseWriter, r *http.Request) {
 		handleBadRequest(w, r, err)
 		return
 	}
-	handleGIFFrameCommon(w, r, width, height)
+	handleFrameCommon(w, r, width, height, frameFormatGIF)
 }
 
 func handleGIFFrame(w http.ResponseWriter, r *http.Request) {
-	handleGIFFrameCommon(w, r, 0, 0)
+	handleFrameCommon(w, r, 0, 0, frameFormatGIF)
+}
+
+func handlePNGFrameDimensions(w http.ResponseWriter, r *http.Request) {
+	width, height, err := getGameDimensions(r)
+	if err != nil {
+		handleBadRequest(w, r, err)
+		return
+	}
+	handleFrameCommon(w, r, width, height, frameFormatPNG)
 }
 
-func handleGIFFrameCommon(w http.ResponseWriter, r *http.Request, width, height int) {
+func handlePNGFrame(w http.ResponseWriter, r *http.Request) {
+	handleFrameCommon(w, r, 0, 0, frameFormatPNG)
+}
+
+// frameFormat is an image format that single frames can be exported as.
+type frameFormat struct {
+	contentType string
+	encode      func(ctx context.Context, w io.Writer, g *engine.Game, gf *engine.GameFrame, width, height int, settings render.DrawSettings) error
+}
+
+var (
+	frameFormatGIF = frameFormat{contentType: "image/gif", encode: render.GameFrameToGIF}
+	frameFormatPNG = frameFormat{contentType: "image/png", encode: render.GameFrameToPNG}
+)
+
+func handleFrameCommon(w http.ResponseWriter, r *http.Request, width, height int, format frameFormat) {
 	gameID := pat.Param(r, "game")
 	frameID, err := strconv.Atoi(pat.Param(r, "frame"))
 	if err != nil {
@@ -292,7 +712,7 @@ func handleGIFFrameCommon(w http.ResponseWriter, r *http.Request, width, height
 	log.Infof("exporting frame %s:%d", gameID, frameID)
 
 	engineURL := r.URL.Query().Get("engine_url")
-	game, err := engine.GetGame(gameID, engineURL)
+	game, err := engine.GetGame(r.Context(), gameID, engineURL)
 	if err != nil {
 		if errors.Is(err, engine.ErrNotFound) {
 			handleError(w, r, err, http.StatusNotFound)
@@ -301,13 +721,19 @@ func handleGIFFrameCommon(w http.ResponseWriter, r *http.Request, width, height
 		}
 		return
 	}
-	err = validateDimensionsForBoard(game, width, height)
+	settings, err := parseDrawSettings(r, game)
+	if err != nil {
+		handleBadRequest(w, r, err)
+		return
+	}
+	boardWidth, boardHeight := visibleBoardSize(game, settings)
+	err = validateDimensionsForBoard(boardWidth, boardHeight, width, height)
 	if err != nil {
 		handleBadRequest(w, r, err)
 		return
 	}
 
-	gameFrame, err := engine.GetGameFrame(game.ID, engineURL, frameID)
+	gameFrame, err := engine.GetGameFrame(r.Context(), game.ID, engineURL, frameID)
 	if err != nil {
 		if errors.Is(err, engine.ErrNotFound) {
 			handleError(w, r, err, http.StatusNotFound)
@@ -317,8 +743,16 @@ func handleGIFFrameCommon(w http.ResponseWriter, r *http.Request, width, height
 		return
 	}
 
-	w.Header().Set("Content-Type", "image/gif")
-	if err = render.GameFrameToGIF(w, game, gameFrame, width, height); err != nil {
+	err = validateDrawSettings(settings, []*engine.GameFrame{gameFrame})
+	if err != nil {
+		handleBadRequest(w, r, err)
+		return
+	}
+
+	settings.Metadata = exportMetadata(game.ID, []*engine.GameFrame{gameFrame}, engineURL)
+
+	w.Header().Set("Content-Type", format.contentType)
+	if err = format.encode(r.Context(), w, game, gameFrame, width, height, settings); err != nil {
 		handleError(w, r, err, http.StatusInternalServerError)
 		return
 	}
@@ -359,7 +793,7 @@ func handleCommonGIFGame(w http.ResponseWriter, r *http.Request, width, height i
 
 	log.WithField("game", gameID).WithField("engine_url", engineURL).Info("rendering gif for game")
 
-	game, err := engine.GetGame(gameID, engineURL)
+	game, err := engine.GetGame(r.Context(), gameID, engineURL)
 	if err != nil {
 		if errors.Is(err, engine.ErrNotFound) {
 			handleError(w, r, err, http.StatusNotFound)
@@ -368,27 +802,35 @@ func handleCommonGIFGame(w http.ResponseWriter, r *http.Request, width, height i
 		}
 		return
 	}
-	err = validateDimensionsForBoard(game, width, height)
+	settings, err := parseDrawSettings(r, game)
+	if err != nil {
+		handleBadRequest(w, r, err)
+		return
+	}
+	boardWidth, boardHeight := visibleBoardSize(game, settings)
+	err = validateDimensionsForBoard(boardWidth, boardHeight, width, height)
+	if err != nil {
+		handleBadRequest(w, r, err)
+		return
+	}
+	maxBytes, err := parseMaxBytesParam(r.URL.Query().Get("maxBytes"))
 	if err != nil {
 		handleBadRequest(w, r, err)
 		return
 	}
 
-	offset := 0
-	limit := math.MaxInt32
-	frames := strings.Split(r.URL.Query().Get("frames"), "-")
-	if len(frames) == 2 {
-		valOne, errOne := strconv.Atoi(frames[0])
-		valTwo, errTwo := strconv.Atoi(frames[1])
-		if errOne != nil || errTwo != nil {
-			handleBadRequest(w, r, fmt.Errorf("invalid frames parameter: %s", r.URL.Query().Get("frames")))
-		}
-
-		offset = valOne
-		limit = valTwo - valOne + 1
+	offset, limit, err := parseFramesParam(r.URL.Query().Get("frames"))
+	if err != nil {
+		handleBadRequest(w, r, err)
+		return
+	}
+	playback, err := parsePlayback(r)
+	if err != nil {
+		handleBadRequest(w, r, err)
+		return
 	}
 
-	gameFrames, err := engine.GetGameFrames(game.ID, engineURL, offset, limit)
+	gameFrames, err := engine.GetGameFrames(r.Context(), game.ID, engineURL, offset, limit)
 	if err != nil {
 		if errors.Is(err, engine.ErrNotFound) {
 			handleError(w, r, err, http.StatusNotFound)
@@ -398,24 +840,377 @@ func handleCommonGIFGame(w http.ResponseWriter, r *http.Request, width, height i
 		return
 	}
 
-	frameDelay, err := strconv.Atoi(r.URL.Query().Get("frameDelay"))
+	err = validateDrawSettings(settings, gameFrames)
 	if err != nil {
-		frameDelay = render.GIFFrameDelay
+		handleBadRequest(w, r, err)
+		return
 	}
+	settings.Metadata = exportMetadata(game.ID, gameFrames, engineURL)
 
-	loopDelay, err := strconv.Atoi(r.URL.Query().Get("loopDelay"))
-	if err != nil {
-		loopDelay = render.GIFLoopDelay
+	gameFrames, delays := playback.Apply(gameFrames)
+
+	if maxBytes > 0 {
+		sizes := budgetSizes(boardWidth, boardHeight, width, height)
+		plan, err := render.FitAnimatedGIF(r.Context(), game, gameFrames, delays, sizes, settings, maxBytes)
+		if err != nil {
+			if errors.Is(err, render.ErrGIFTooLarge) {
+				handleError(w, r, err, http.StatusUnprocessableEntity)
+			} else {
+				handleError(w, r, err, http.StatusInternalServerError)
+			}
+			return
+		}
+
+		planWidth, planHeight := plan.Width, plan.Height
+		if planWidth == 0 || planHeight == 0 {
+			planWidth, planHeight = defaultGIFSize(boardWidth, boardHeight)
+		}
+		w.Header().Set("X-GIF-Size", fmt.Sprintf("%dx%d", planWidth, planHeight))
+		w.Header().Set("X-GIF-Frames", strconv.Itoa(len(plan.GameFrames)))
+		w.Header().Set("X-GIF-Frame-Step", strconv.Itoa(plan.FrameStep))
+		w.Header().Set("X-GIF-Merged-Frames", strconv.Itoa(plan.MergedFrames))
+		w.Header().Set("X-GIF-Estimated-Bytes", strconv.Itoa(plan.EstimatedBytes))
+
+		w.Header().Set("Content-Type", "image/gif")
+		if err := plan.Encode(r.Context(), w, game, settings); err != nil {
+			handleError(w, r, err, http.StatusInternalServerError)
+		}
+		return
 	}
 
 	w.Header().Set("Content-Type", "image/gif")
-	err = render.GameFramesToAnimatedGIF(w, game, gameFrames, frameDelay, loopDelay, width, height)
+	err = render.GameFramesToAnimatedGIFWithDelays(r.Context(), w, game, gameFrames, delays, width, height, settings)
+	if err != nil {
+		handleError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+}
+
+var framesRegex = regexp.MustCompile(`^(\d+)-(\d+)$`)
+
+// parseFramesParam parses the optional range of frames to export, in the form "<FIRST>-<LAST>" (inclusive).
+// It returns the offset and number of frames to fetch, which is every frame when there's no range.
+func parseFramesParam(param string) (int, int, error) {
+	if param == "" {
+		return 0, math.MaxInt32, nil
+	}
+
+	m := framesRegex.FindStringSubmatch(param)
+	if len(m) != 3 {
+		return 0, 0, fmt.Errorf("invalid frames parameter: %s - should be in the format <FIRST>-<LAST>", param)
+	}
+	first, errFirst := strconv.Atoi(m[1])
+	last, errLast := strconv.Atoi(m[2])
+	if errFirst != nil || errLast != nil || first > last {
+		return 0, 0, fmt.Errorf("invalid frames parameter: %s - the first frame can't be after the last frame", param)
+	}
+
+	return first, last - first + 1, nil
+}
+
+// parsePlayback reads the options that control which frames are shown, and for how long, from the request query.
+func parsePlayback(r *http.Request) (render.Playback, error) {
+	query := r.URL.Query()
+	playback := render.DefaultPlayback
+
+	speed := query.Get("speed")
+	if speed != "" {
+		if query.Get("frameDelay") != "" {
+			return playback, errors.New("the speed and frameDelay parameters can't be used together")
+		}
+		delay, ok := speedPresets[speed]
+		if !ok {
+			return playback, fmt.Errorf("invalid speed parameter: %s - valid speeds are: %s", speed, strings.Join(speedPresetNames, ", "))
+		}
+		playback.FrameDelay = delay
+	}
+
+	var err error
+	if playback.FrameDelay, err = parseIntParam(query, "frameDelay", playback.FrameDelay, 0, maxGIFFrameDelay); err != nil {
+		return playback, err
+	}
+	if playback.LoopDelay, err = parseIntParam(query, "loopDelay", playback.LoopDelay, 0, maxGIFFrameDelay); err != nil {
+		return playback, err
+	}
+	if playback.Step, err = parseIntParam(query, "step", 1, 1, maxGIFFrameStep); err != nil {
+		return playback, err
+	}
+	if playback.Timelapse, err = parseBoolParam(query, "timelapse"); err != nil {
+		return playback, err
+	}
+	if playback.SlowEvents, err = parseBoolParam(query, "slowEvents"); err != nil {
+		return playback, err
+	}
+
+	if mode := query.Get("playback"); mode != "" {
+		playback.Mode = render.PlaybackMode(mode)
+		if !slices.Contains(render.PlaybackModes, playback.Mode) {
+			return playback, fmt.Errorf("invalid playback parameter: %s - valid modes are: %s", mode, playbackModeNames())
+		}
+	}
+
+	return playback, nil
+}
+
+// playbackModeNames lists the valid playback modes, for error messages.
+func playbackModeNames() string {
+	names := make([]string, len(render.PlaybackModes))
+	for i, mode := range render.PlaybackModes {
+		names[i] = string(mode)
+	}
+	return strings.Join(names, ", ")
+}
+
+// parseIntParam parses an optional integer query parameter, which must be between min and max (inclusive).
+func parseIntParam(query url.Values, name string, defaultValue, min, max int) (int, error) {
+	param := query.Get(name)
+	if param == "" {
+		return defaultValue, nil
+	}
+	v, err := strconv.Atoi(param)
+	if err != nil || v < min || v > max {
+		return 0, fmt.Errorf("invalid %s parameter: %s - must be between %d and %d", name, param, min, max)
+	}
+	return v, nil
+}
+
+// parseBoolParam parses an optional boolean query parameter, which is false when it isn't set.
+func parseBoolParam(query url.Values, name string) (bool, error) {
+	param := query.Get(name)
+	if param == "" {
+		return false, nil
+	}
+	v, err := strconv.ParseBool(param)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s parameter: %s - must be true or false", name, param)
+	}
+	return v, nil
+}
+
+// parseMaxBytesParam parses the optional size budget for a GIF, in bytes.
+// It returns 0 when there is no budget.
+func parseMaxBytesParam(param string) (int, error) {
+	if param == "" {
+		return 0, nil
+	}
+	maxBytes, err := strconv.Atoi(param)
+	if err != nil || maxBytes < 1 {
+		return 0, fmt.Errorf("invalid maxBytes parameter: %s - must be a positive number of bytes", param)
+	}
+	return maxBytes, nil
+}
+
+// defaultGIFSize gets the size of a GIF when no width/height is given, for a board of the given width/height (in squares).
+func defaultGIFSize(boardWidth, boardHeight int) (int, int) {
+	b := int(render.BoardBorder * 2)
+	return boardWidth*defaultPixelsPerSquare + b, boardHeight*defaultPixelsPerSquare + b
+}
+
+// budgetSizes lists the GIF sizes that can be used to fit a size budget, from the requested size down to the smallest allowed size.
+// The board width/height are in squares and should be the size of the region being drawn.
+func budgetSizes(boardWidth, boardHeight, w, h int) []image.Point {
+	b := int(render.BoardBorder * 2)
+
+	pixelsPerSquare := defaultPixelsPerSquare
+	sizes := []image.Point{{X: w, Y: h}}
+	if w != 0 && h != 0 {
+		pixelsPerSquare = (w - b) / boardWidth
+	}
+
+	for i := len(allowedPixelsPerSquare) - 1; i >= 0; i-- {
+		r := allowedPixelsPerSquare[i]
+		if r >= pixelsPerSquare {
+			continue
+		}
+		size := image.Point{X: boardWidth*r + b, Y: boardHeight*r + b}
+		if validateGIFSize(size.X, size.Y) == nil {
+			sizes = append(sizes, size)
+		}
+	}
+	return sizes
+}
+
+func handleRenderGIFDimensions(w http.ResponseWriter, r *http.Request) {
+	width, height, err := getGameDimensions(r)
+	if err != nil {
+		handleBadRequest(w, r, err)
+		return
+	}
+	handleRenderGIFCommon(w, r, width, height)
+}
+
+func handleRenderGIF(w http.ResponseWriter, r *http.Request) {
+	handleRenderGIFCommon(w, r, 0, 0)
+}
+
+// handleRenderGIFCommon renders a hand-written ASCII board from the request body, instead of a frame from the engine.
+// See render.ASCIIToGameFrame for the board format.
+func handleRenderGIFCommon(w http.ResponseWriter, r *http.Request, width, height int) {
+	game, gameFrame, err := render.ASCIIToGameFrame(http.MaxBytesReader(w, r.Body, maxASCIIBoardBytes))
+	if err != nil {
+		handleBadRequest(w, r, err)
+		return
+	}
+
+	settings, err := parseDrawSettings(r, game)
+	if err != nil {
+		handleBadRequest(w, r, err)
+		return
+	}
+	boardWidth, boardHeight := visibleBoardSize(game, settings)
+	err = validateDimensionsForBoard(boardWidth, boardHeight, width, height)
 	if err != nil {
+		handleBadRequest(w, r, err)
+		return
+	}
+
+	// the legacy endpoint doesn't have a width/height, so check the size the board will be drawn at
+	if width == 0 || height == 0 {
+		if err = validateGIFSize(defaultGIFSize(boardWidth, boardHeight)); err != nil {
+			handleBadRequest(w, r, err)
+			return
+		}
+	}
+
+	err = validateDrawSettings(settings, []*engine.GameFrame{gameFrame})
+	if err != nil {
+		handleBadRequest(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/gif")
+	if err = render.GameFrameToGIF(r.Context(), w, game, gameFrame, width, height, settings); err != nil {
 		handleError(w, r, err, http.StatusInternalServerError)
 		return
 	}
 }
 
+// parseDrawSettings reads the optional board drawing settings from the request query.
+// The viewport is validated against the size of the game board.
+func parseDrawSettings(r *http.Request, game *engine.Game) (render.DrawSettings, error) {
+	settings := render.DrawSettings{}
+	query := r.URL.Query()
+
+	focus := query.Get("focus")
+	if focus != "" {
+		if !reSnakeIDParam.MatchString(focus) {
+			return settings, fmt.Errorf("invalid focus parameter: %s", focus)
+		}
+		settings.Focus = focus
+	}
+
+	viewport := query.Get("viewport")
+	if viewport != "" {
+		v, err := parseViewportParam(viewport)
+		if err != nil {
+			return settings, err
+		}
+		if err := v.Validate(game.Width, game.Height); err != nil {
+			return settings, err
+		}
+		settings.Viewport = v
+	}
+
+	follow := query.Get("follow")
+	if follow != "" {
+		if !reSnakeIDParam.MatchString(follow) {
+			return settings, fmt.Errorf("invalid follow parameter: %s", follow)
+		}
+		if settings.Viewport.IsZero() {
+			return settings, errors.New("the follow parameter requires a viewport")
+		}
+		settings.Follow = follow
+	}
+
+	overlays := query.Get("overlays")
+	if overlays != "" {
+		for _, name := range strings.Split(overlays, ",") {
+			if !render.IsOverlay(name) {
+				return settings, fmt.Errorf("invalid overlay: %s - valid overlays are: %s", name, strings.Join(render.Overlays(), ", "))
+			}
+			settings.Overlays = append(settings.Overlays, name)
+		}
+	}
+
+	watermark, err := parseWatermark(defaultWatermark, query.Get("watermark"), query.Get("watermarkOpacity"), query.Get("watermarkPosition"))
+	if err != nil {
+		return settings, err
+	}
+	settings.Watermark = &watermark
+
+	return settings, nil
+}
+
+// parseWatermark applies any of the given watermark options on top of the base watermark.
+// Empty options are left unchanged. The asset can be "none" to disable the watermark.
+func parseWatermark(base render.Watermark, asset, opacity, position string) (render.Watermark, error) {
+	wm := base
+
+	if asset == "none" {
+		wm.Asset = ""
+	} else if asset != "" {
+		if !media.IsBrandingAsset(asset) {
+			return wm, fmt.Errorf("invalid watermark: %s", asset)
+		}
+		wm.Asset = asset
+	}
+
+	if opacity != "" {
+		o, err := strconv.ParseFloat(opacity, 64)
+		if err != nil || !(o >= 0 && o <= 1) {
+			return wm, fmt.Errorf("invalid watermark opacity: %s - must be between 0 and 1", opacity)
+		}
+		wm.Opacity = o
+	}
+
+	if position != "" {
+		valid := false
+		for _, p := range render.WatermarkPositions {
+			if string(p) == position {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return wm, fmt.Errorf("invalid watermark position: %s", position)
+		}
+		wm.Position = render.WatermarkPosition(position)
+	}
+
+	return wm, nil
+}
+
+// validateDrawSettings makes sure that any snakes referenced by the settings exist in the game frames.
+func validateDrawSettings(settings render.DrawSettings, gameFrames []*engine.GameFrame) error {
+	if settings.Focus != "" && !snakeExists(gameFrames, settings.Focus) {
+		return fmt.Errorf("invalid focus parameter: %s", settings.Focus)
+	}
+	if settings.Follow != "" && !snakeExists(gameFrames, settings.Follow) {
+		return fmt.Errorf("invalid follow parameter: %s", settings.Follow)
+	}
+	return nil
+}
+
+// visibleBoardSize gets the width/height (in squares) of the region of the board that will be drawn.
+func visibleBoardSize(game *engine.Game, settings render.DrawSettings) (int, int) {
+	if settings.Viewport.IsZero() {
+		return game.Width, game.Height
+	}
+	return settings.Viewport.Width, settings.Viewport.Height
+}
+
+// snakeExists checks whether a snake with the given ID is present in any of the game frames.
+func snakeExists(gameFrames []*engine.GameFrame, snakeID string) bool {
+	for _, gf := range gameFrames {
+		for _, snake := range gf.Snakes {
+			if snake.ID == snakeID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func handleBadRequest(w http.ResponseWriter, r *http.Request, e error) {
 	w.WriteHeader(http.StatusBadRequest)
 	_, err := w.Write([]byte(e.Error()))
@@ -425,6 +1220,12 @@ func handleBadRequest(w http.ResponseWriter, r *http.Request, e error) {
 }
 
 func handleError(w http.ResponseWriter, r *http.Request, err error, statusCode int) {
+	// the client has gone away, so there's nobody to send the error to
+	if errors.Is(err, context.Canceled) {
+		log.WithField("url", r.URL.String()).Info("request cancelled by client")
+		return
+	}
+
 	log.WithError(err).
 		WithFields(log.Fields{
 			"httpRequest": map[string]interface{}{
@@ -442,6 +1243,17 @@ func handleError(w http.ResponseWriter, r *http.Request, err error, statusCode i
 	}
 }
 
+// rasterizeErrorStatus chooses the response status for an error from rasterizing an SVG.
+func rasterizeErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, media.ErrRasterizeTimeout):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, media.ErrInvalidSVG):
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusInternalServerError
+}
+
 func handleAlive(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprint(w, "alive")
 }
@@ -474,6 +1286,28 @@ func validateGIFSize(w, h int) error {
 	return nil
 }
 
+var viewportRegex = regexp.MustCompile(`^(\d+),(\d+),(\d+),(\d+)$`)
+
+// parseViewportParam parses a query parameter that is expected to be in the form "<X>,<Y>,<WIDTH>,<HEIGHT>".
+// All values are in board squares.
+func parseViewportParam(param string) (render.Viewport, error) {
+	m := viewportRegex.FindStringSubmatch(param)
+	if len(m) != 5 {
+		return render.Viewport{}, fmt.Errorf(`Invalid viewport: "%s" not of the format <X>,<Y>,<WIDTH>,<HEIGHT>.`, param)
+	}
+
+	values := make([]int, 4)
+	for i := range values {
+		v, err := strconv.Atoi(m[i+1])
+		if err != nil {
+			return render.Viewport{}, err
+		}
+		values[i] = v
+	}
+
+	return render.Viewport{X: values[0], Y: values[1], Width: values[2], Height: values[3]}, nil
+}
+
 // parseSizeParam parses a path parameter that is expected to be in the form "<WIDTH>x<HEIGHT>".
 // If the size param is empty, 0,0 is returned.
 func parseSizeParam(param string) (int, int, error) {
@@ -1,15 +1,26 @@
 package http
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"image"
+	"image/gif"
+	"image/png"
+	"math"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"strings"
 	"testing"
+	"testing/fstest"
+	"time"
 
 	"github.com/BattlesnakeOfficial/exporter/fixtures"
+	"github.com/BattlesnakeOfficial/exporter/media"
+	"github.com/BattlesnakeOfficial/exporter/render"
 	"github.com/stretchr/testify/require"
 )
 
@@ -79,6 +90,12 @@ func TestHandleAvatar_BadRequest(t *testing.T) {
 	}
 }
 
+func TestRasterizeErrorStatus(t *testing.T) {
+	require.Equal(t, http.StatusGatewayTimeout, rasterizeErrorStatus(fmt.Errorf("%w: slow", media.ErrRasterizeTimeout)))
+	require.Equal(t, http.StatusUnprocessableEntity, rasterizeErrorStatus(fmt.Errorf("%w: bad", media.ErrInvalidSVG)))
+	require.Equal(t, http.StatusInternalServerError, rasterizeErrorStatus(errors.New("something else")))
+}
+
 func TestHandlerCustomization_OK(t *testing.T) {
 	server := NewServer()
 
@@ -100,6 +117,210 @@ func TestHandlerCustomization_OK(t *testing.T) {
 	}
 }
 
+func TestHandleCustomization_PNG(t *testing.T) {
+	defer media.SetSource(media.ServerSource{})
+	media.SetSource(media.BundleSource{FS: fstest.MapFS{
+		"snakes/heads/half.svg": {Data: []byte(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 100 100"><rect width="50" height="100"/></svg>`)},
+	}})
+	server := NewServer()
+
+	for _, test := range []struct {
+		path   string
+		size   int
+		filled image.Point
+	}{
+		{"/head/half.png", 100, image.Pt(10, 50)},
+		{"/head/half.png?size=40", 40, image.Pt(4, 20)},
+		{"/head/half.png?flipped=1", 100, image.Pt(90, 50)},
+		{"/head/half.png?direction=up&color=%23ff00ff", 100, image.Pt(50, 90)},
+		{"/head/half.png?direction=left&flipped=1", 100, image.Pt(10, 50)},
+	} {
+		req, res := fixtures.TestRequest(t, "GET", "http://localhost/customizations"+test.path, nil)
+		server.router.ServeHTTP(res, req)
+		require.Equal(t, http.StatusOK, res.Code, test.path)
+		require.Equal(t, "image/png", res.Result().Header.Get("content-type"))
+		img, err := png.Decode(res.Body)
+		require.NoError(t, err)
+		require.Equal(t, image.Rect(0, 0, test.size, test.size), img.Bounds(), test.path)
+		_, _, _, a := img.At(test.filled.X, test.filled.Y).RGBA()
+		require.Equal(t, uint32(0xffff), a, test.path)
+	}
+
+	for _, path := range []string{
+		"/head/half.png?size=5",
+		"/head/half.png?size=5000",
+		"/head/half.png?size=big",
+		"/head/half.png?direction=sideways",
+		"/head/half.gif",
+	} {
+		req, res := fixtures.TestRequest(t, "GET", "http://localhost/customizations"+path, nil)
+		server.router.ServeHTTP(res, req)
+		require.Equal(t, http.StatusBadRequest, res.Code, path)
+	}
+
+	req, res := fixtures.TestRequest(t, "GET", "http://localhost/customizations/head/missing.png", nil)
+	server.router.ServeHTTP(res, req)
+	require.Equal(t, http.StatusNotFound, res.Code)
+}
+
+func TestHandleCustomization_Sanitized(t *testing.T) {
+	defer media.SetSource(media.ServerSource{})
+	unsafe := []byte(`<?xml version="1.0"?><svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 100 100" onload="alert(1)"><script>alert(2)</script><foreignObject><p>hi</p></foreignObject><image href="https://example.com/track.png"/><path d="M0 0h100v100z"/></svg>`)
+	media.SetSource(media.BundleSource{FS: fstest.MapFS{
+		"snakes/heads/unsafe.svg": {Data: unsafe},
+		"snakes/tails/unsafe.svg": {Data: unsafe},
+	}})
+	server := NewServer()
+
+	for _, path := range []string{
+		"/customizations/head/unsafe.svg",
+		"/customizations/tail/gallery.svg",
+		"/avatars/head:unsafe/tail:unsafe/500x100.svg",
+	} {
+		req, res := fixtures.TestRequest(t, "GET", "http://localhost"+path, nil)
+		server.router.ServeHTTP(res, req)
+		require.Equal(t, http.StatusOK, res.Code, path)
+		for _, s := range []string{"alert", "script", "foreignObject", "example.com", "<?xml"} {
+			require.NotContains(t, res.Body.String(), s, path)
+		}
+	}
+}
+
+func TestHandleCustomization_InvalidSVG(t *testing.T) {
+	defer media.SetSource(media.ServerSource{})
+	media.SetSource(media.BundleSource{FS: fstest.MapFS{
+		"snakes/heads/malformed.svg": {Data: []byte(`<svg><script>alert(1)`)},
+		"snakes/tails/malformed.svg": {Data: []byte(`<!DOCTYPE svg [<!ENTITY lol "lol">]><svg>&lol;</svg>`)},
+	}})
+	server := NewServer()
+
+	// SVGs that can't be sanitized are reported the same way as SVGs that can't be rasterized
+	for _, path := range []string{
+		"/customizations/head/malformed.svg",
+		"/customizations/tail/malformed.png",
+		"/avatars/head:malformed/500x100.svg",
+		"/avatars/tail:malformed/500x100.svg",
+	} {
+		req, res := fixtures.TestRequest(t, "GET", "http://localhost"+path, nil)
+		server.router.ServeHTTP(res, req)
+		require.Equal(t, http.StatusUnprocessableEntity, res.Code, path)
+	}
+}
+
+func TestHandleCustomizationCatalog(t *testing.T) {
+	defer media.SetSource(media.ServerSource{})
+	media.SetSource(media.Sources{
+		{Source: media.BundleSource{FS: fstest.MapFS{
+			"snakes/heads/beluga.svg": {Data: []byte("<svg></svg>")},
+			"snakes/tails/bolt.svg":   {Data: []byte("<svg></svg>")},
+		}}},
+		{Namespace: "private", Source: media.BundleSource{FS: fstest.MapFS{
+			"snakes/heads/secret.svg": {Data: []byte("<svg></svg>")},
+		}}},
+	})
+	server := NewServer()
+
+	req, res := fixtures.TestRequest(t, "GET", "http://localhost/customizations", nil)
+	server.router.ServeHTTP(res, req)
+	require.Equal(t, http.StatusOK, res.Code)
+	require.Equal(t, "application/json", res.Result().Header.Get("content-type"))
+	var catalog customizationCatalog
+	require.NoError(t, json.Unmarshal(res.Body.Bytes(), &catalog))
+	require.True(t, catalog.Complete)
+	require.Equal(t, []customizationInfo{
+		{Type: "head", Name: "beluga", PreviewURL: "/customizations/head/beluga.svg"},
+		{Type: "head", Name: "default", PreviewURL: "/customizations/head/default.svg"},
+		{Type: "head", Name: "private:secret", Namespace: "private", PreviewURL: "/customizations/head/private:secret.svg"},
+	}, catalog.Heads)
+	require.Equal(t, []customizationInfo{
+		{Type: "tail", Name: "bolt", PreviewURL: "/customizations/tail/bolt.svg"},
+		{Type: "tail", Name: "default", PreviewURL: "/customizations/tail/default.svg"},
+	}, catalog.Tails)
+
+	req, res = fixtures.TestRequest(t, "GET", "http://localhost/customizations/tail", nil)
+	server.router.ServeHTTP(res, req)
+	require.Equal(t, http.StatusOK, res.Code)
+	catalog = customizationCatalog{}
+	require.NoError(t, json.Unmarshal(res.Body.Bytes(), &catalog))
+	require.Empty(t, catalog.Heads)
+	require.Len(t, catalog.Tails, 2)
+
+	// the preview URLs work
+	req, res = fixtures.TestRequest(t, "GET", "http://localhost/customizations/head/private:secret.svg", nil)
+	server.router.ServeHTTP(res, req)
+	require.Equal(t, http.StatusOK, res.Code)
+
+	req, res = fixtures.TestRequest(t, "GET", "http://localhost/customizations/body", nil)
+	server.router.ServeHTTP(res, req)
+	require.Equal(t, http.StatusBadRequest, res.Code)
+
+	// sources that can't be listed make the catalog incomplete
+	media.SetSource(media.Sources{{Source: media.ServerSource{URL: "http://localhost:0"}}})
+	req, res = fixtures.TestRequest(t, "GET", "http://localhost/customizations/head", nil)
+	server.router.ServeHTTP(res, req)
+	require.Equal(t, http.StatusOK, res.Code)
+	require.JSONEq(t, `{"heads":[{"type":"head","name":"default","preview_url":"/customizations/head/default.svg"}],"complete":false}`, res.Body.String())
+}
+
+func TestHandleCustomizationGallery(t *testing.T) {
+	defer media.SetSource(media.ServerSource{})
+	square := []byte(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 100 100"><rect width="100" height="100"/></svg>`)
+	media.SetSource(media.BundleSource{FS: fstest.MapFS{
+		"snakes/heads/default.svg": {Data: square},
+		"snakes/heads/beluga.svg":  {Data: square},
+		"snakes/tails/default.svg": {Data: square},
+	}})
+	server := NewServer()
+
+	req, res := fixtures.TestRequest(t, "GET", "http://localhost/customizations/head/gallery.svg?color=%2331688e", nil)
+	server.router.ServeHTTP(res, req)
+	require.Equal(t, http.StatusOK, res.Code)
+	require.Equal(t, "image/svg+xml", res.Result().Header.Get("content-type"))
+	require.Contains(t, res.Body.String(), ">beluga</text>")
+	require.Contains(t, res.Body.String(), ">default</text>")
+
+	req, res = fixtures.TestRequest(t, "GET", "http://localhost/customizations/tail/gallery.png", nil)
+	server.router.ServeHTTP(res, req)
+	require.Equal(t, http.StatusOK, res.Code)
+	require.Equal(t, "image/png", res.Result().Header.Get("content-type"))
+	img, err := png.Decode(res.Body)
+	require.NoError(t, err)
+	require.Equal(t, image.Rect(0, 0, 120, 140), img.Bounds())
+
+	for _, path := range []string{
+		"/customizations/body/gallery.svg",
+		"/customizations/head/gallery.gif",
+		"/customizations/head/gallery.svg?color=barf",
+		"/customizations/head/gallery.svg?page=0",
+	} {
+		req, res := fixtures.TestRequest(t, "GET", "http://localhost"+path, nil)
+		server.router.ServeHTTP(res, req)
+		require.Equal(t, http.StatusBadRequest, res.Code, path)
+	}
+}
+
+func TestHandleCustomizationGallery_Pages(t *testing.T) {
+	defer media.SetSource(media.ServerSource{})
+	bundle := fstest.MapFS{"snakes/heads/default.svg": {Data: []byte("<svg></svg>")}}
+	for i := 0; i < maxGalleryTiles+1; i++ {
+		bundle[fmt.Sprintf("snakes/heads/head%03d.svg", i)] = &fstest.MapFile{Data: []byte("<svg></svg>")}
+	}
+	media.SetSource(media.BundleSource{FS: bundle})
+	server := NewServer()
+
+	// the default head and the others don't fit on one page
+	for page, tiles := range map[string]int{"": maxGalleryTiles, "?page=1": maxGalleryTiles, "?page=2": 2} {
+		req, res := fixtures.TestRequest(t, "GET", "http://localhost/customizations/head/gallery.svg"+page, nil)
+		server.router.ServeHTTP(res, req)
+		require.Equal(t, http.StatusOK, res.Code, page)
+		require.Equal(t, tiles, strings.Count(res.Body.String(), "</text>"), page)
+	}
+
+	req, res := fixtures.TestRequest(t, "GET", "http://localhost/customizations/head/gallery.svg?page=3", nil)
+	server.router.ServeHTTP(res, req)
+	require.Equal(t, http.StatusNotFound, res.Code)
+}
+
 func TestHandleGIFGame_NotFound(t *testing.T) {
 	server := NewServer()
 
@@ -286,6 +507,502 @@ func TestHandleGIFFrame_Success(t *testing.T) {
 	}
 }
 
+func TestHandlePNGFrame_Success(t *testing.T) {
+	fixtures.TestInRootDir()
+	server := NewServer()
+	t.Setenv("APP_VERSION", "1.2.3")
+
+	engineServer := fixtures.StubEngineServer(func(res http.ResponseWriter, req *http.Request) {
+		if strings.HasSuffix(req.URL.Path, "/frames") {
+			_, _ = res.Write([]byte(fixtures.ExampleGameFramesResponse))
+		} else {
+			_, _ = res.Write([]byte(fixtures.ExampleGameResponse))
+		}
+	})
+	defer engineServer.Close()
+	engineURL, err := url.Parse(engineServer.URL)
+	require.NoError(t, err)
+
+	for path, size := range map[string]int{"png": 224, "334x334.png": 334} {
+		req, res := fixtures.TestRequest(t, "GET", "http://localhost/games/GAME_ID/frames/0/"+path+"?engine_url="+engineServer.URL, nil)
+		server.router.ServeHTTP(res, req)
+		require.Equal(t, http.StatusOK, res.Code, path)
+		require.Equal(t, "image/png", res.Header().Get("Content-Type"))
+
+		img, err := png.Decode(bytes.NewReader(res.Body.Bytes()))
+		require.NoError(t, err)
+		require.Equal(t, size, img.Bounds().Dx())
+
+		m, err := render.ReadMetadata(res.Body)
+		require.NoError(t, err)
+		require.Equal(t, "1f26bc6e-2b96-4f54-ba01-f610e68e2d45", m.GameID)
+		require.Equal(t, "1.2.3", m.Version)
+		require.Equal(t, engineURL.Host, m.EngineHost)
+	}
+
+	req, res := fixtures.TestRequest(t, "GET", "http://localhost/games/GAME_ID/frames/0/510x510.png?engine_url="+engineServer.URL, nil)
+	server.router.ServeHTTP(res, req)
+	require.Equal(t, http.StatusBadRequest, res.Code)
+}
+
+func TestHandleGIFGame_Metadata(t *testing.T) {
+	fixtures.TestInRootDir()
+	server := NewServer()
+	t.Setenv("APP_VERSION", "1.2.3")
+
+	engineServer := fixtures.StubEngineServerFromASCII(t, "GAME_ID", exampleASCIIBoard, exampleASCIIBoard, exampleASCIIBoard)
+	defer engineServer.Close()
+	engineURL, err := url.Parse(engineServer.URL)
+	require.NoError(t, err)
+
+	req, res := fixtures.TestRequest(t, "GET", "http://localhost/games/GAME_ID/gif?playback=reverse&engine_url="+engineServer.URL, nil)
+	server.router.ServeHTTP(res, req)
+	require.Equal(t, http.StatusOK, res.Code)
+
+	m, err := render.ReadMetadata(res.Body)
+	require.NoError(t, err)
+	require.Equal(t, &render.Metadata{GameID: "GAME_ID", FirstTurn: 0, LastTurn: 2, Version: "1.2.3", EngineHost: engineURL.Host}, m)
+}
+
+func TestHandleGIF_Focus(t *testing.T) {
+	fixtures.TestInRootDir()
+	server := NewServer()
+
+	engineServer := fixtures.StubEngineServer(func(res http.ResponseWriter, req *http.Request) {
+		if strings.HasSuffix(req.URL.Path, "/frames") {
+			_, _ = res.Write([]byte(fixtures.ExampleGameFramesResponse))
+		} else {
+			_, _ = res.Write([]byte(fixtures.ExampleGameResponse))
+		}
+	})
+	defer engineServer.Close()
+
+	for _, test := range []struct {
+		path   string
+		focus  string
+		status int
+	}{
+		{"/games/GAME_ID/gif", "snake1", http.StatusOK},
+		{"/games/GAME_ID/frames/0/gif", "snake1", http.StatusOK},
+		{"/games/GAME_ID/gif", "notasnake", http.StatusBadRequest},
+		{"/games/GAME_ID/frames/0/gif", "notasnake", http.StatusBadRequest},
+		{"/games/GAME_ID/frames/0/gif", "<script>", http.StatusBadRequest},
+	} {
+		req, res := fixtures.TestRequest(t, "GET", "http://localhost"+test.path, nil)
+		query := req.URL.Query()
+		query.Set("engine_url", engineServer.URL)
+		query.Set("focus", test.focus)
+		req.URL.RawQuery = query.Encode()
+
+		server.router.ServeHTTP(res, req)
+		require.Equal(t, test.status, res.Code, test.path+"?focus="+test.focus)
+	}
+}
+
+func TestHandleGIF_Viewport(t *testing.T) {
+	fixtures.TestInRootDir()
+	server := NewServer()
+
+	engineServer := fixtures.StubEngineServer(func(res http.ResponseWriter, req *http.Request) {
+		if strings.HasSuffix(req.URL.Path, "/frames") {
+			_, _ = res.Write([]byte(fixtures.ExampleGameFramesResponse))
+		} else {
+			_, _ = res.Write([]byte(fixtures.ExampleGameResponse))
+		}
+	})
+	defer engineServer.Close()
+
+	for _, test := range []struct {
+		path     string
+		viewport string
+		follow   string
+		status   int
+	}{
+		{"/games/GAME_ID/gif", "0,0,5,5", "", http.StatusOK},
+		{"/games/GAME_ID/204x204.gif", "6,6,5,5", "", http.StatusOK},
+		{"/games/GAME_ID/gif", "0,0,5,5", "snake1", http.StatusOK},
+		{"/games/GAME_ID/frames/0/gif", "0,0,5,5", "", http.StatusOK},
+		{"/games/GAME_ID/frames/0/204x204.gif", "0,0,5,5", "snake1", http.StatusOK},
+
+		{"/games/GAME_ID/444x444.gif", "0,0,5,5", "", http.StatusBadRequest},           // size is for the whole board
+		{"/games/GAME_ID/gif", "0,0,12,5", "", http.StatusBadRequest},                  // too wide
+		{"/games/GAME_ID/gif", "8,0,5,5", "", http.StatusBadRequest},                   // off the board
+		{"/games/GAME_ID/gif", "0,0,5", "", http.StatusBadRequest},                     // invalid format
+		{"/games/GAME_ID/gif", "", "snake1", http.StatusBadRequest},                    // follow requires viewport
+		{"/games/GAME_ID/frames/0/gif", "0,0,5,5", "notasnake", http.StatusBadRequest}, // unknown snake
+	} {
+		req, res := fixtures.TestRequest(t, "GET", "http://localhost"+test.path, nil)
+		query := req.URL.Query()
+		query.Set("engine_url", engineServer.URL)
+		query.Set("viewport", test.viewport)
+		query.Set("follow", test.follow)
+		req.URL.RawQuery = query.Encode()
+
+		server.router.ServeHTTP(res, req)
+		require.Equal(t, test.status, res.Code, req.URL.String())
+	}
+}
+
+func TestHandleGIF_Watermark(t *testing.T) {
+	fixtures.TestInRootDir()
+	server := NewServer()
+
+	engineServer := fixtures.StubEngineServer(func(res http.ResponseWriter, req *http.Request) {
+		if strings.HasSuffix(req.URL.Path, "/frames") {
+			_, _ = res.Write([]byte(fixtures.ExampleGameFramesResponse))
+		} else {
+			_, _ = res.Write([]byte(fixtures.ExampleGameResponse))
+		}
+	})
+	defer engineServer.Close()
+
+	for _, test := range []struct {
+		query  string
+		status int
+	}{
+		{"watermark=none", http.StatusOK},
+		{"watermark=default&watermarkOpacity=0.25&watermarkPosition=bottom-right", http.StatusOK},
+		{"watermarkPosition=top-left", http.StatusOK},
+
+		{"watermark=notanasset", http.StatusBadRequest},
+		{"watermark=../../watermark", http.StatusBadRequest},
+		{"watermarkOpacity=2", http.StatusBadRequest},
+		{"watermarkPosition=middle", http.StatusBadRequest},
+	} {
+		req, res := fixtures.TestRequest(t, "GET", "http://localhost/games/GAME_ID/frames/0/gif?"+test.query, nil)
+		query := req.URL.Query()
+		query.Set("engine_url", engineServer.URL)
+		req.URL.RawQuery = query.Encode()
+
+		server.router.ServeHTTP(res, req)
+		require.Equal(t, test.status, res.Code, test.query)
+	}
+}
+
+func TestHandleGIF_Overlays(t *testing.T) {
+	fixtures.TestInRootDir()
+	server := NewServer()
+
+	engineServer := fixtures.StubEngineServer(func(res http.ResponseWriter, req *http.Request) {
+		if strings.HasSuffix(req.URL.Path, "/frames") {
+			_, _ = res.Write([]byte(fixtures.ExampleGameFramesResponse))
+		} else {
+			_, _ = res.Write([]byte(fixtures.ExampleGameResponse))
+		}
+	})
+	defer engineServer.Close()
+
+	for _, test := range []struct {
+		query  string
+		status int
+	}{
+		{"overlays=turn", http.StatusOK},
+
+		{"overlays=turn,notanoverlay", http.StatusBadRequest},
+	} {
+		req, res := fixtures.TestRequest(t, "GET", "http://localhost/games/GAME_ID/frames/0/gif?"+test.query, nil)
+		query := req.URL.Query()
+		query.Set("engine_url", engineServer.URL)
+		req.URL.RawQuery = query.Encode()
+
+		server.router.ServeHTTP(res, req)
+		require.Equal(t, test.status, res.Code, test.query)
+	}
+}
+
+const exampleASCIIBoard = `
+A: snake1 #ff0000 Snake One
+-------------
+|*          |
+|           |
+|           |
+|   aaaA    |
+|   a       |
+|   a     * |
+|           |
+|           |
+|           |
+|           |
+|           |
+-------------
+`
+
+func TestHandleRenderGIF(t *testing.T) {
+	fixtures.TestInRootDir()
+	server := NewServer()
+
+	for _, test := range []struct {
+		path   string
+		body   string
+		status int
+	}{
+		{"/render/gif", exampleASCIIBoard, http.StatusOK},
+		{"/render/gif?focus=snake1&overlays=turn", exampleASCIIBoard, http.StatusOK},
+		{"/render/224x224.gif", exampleASCIIBoard, http.StatusOK},
+		{"/render/gif", "-----\n| H |\n-----\n", http.StatusOK},
+
+		{"/render/gif", "not a board", http.StatusBadRequest},
+		{"/render/gif", strings.Repeat("-", maxASCIIBoardBytes+1), http.StatusBadRequest},
+		{"/render/gif?focus=snake2", exampleASCIIBoard, http.StatusBadRequest},
+		{"/render/200x200.gif", exampleASCIIBoard, http.StatusBadRequest},
+		{"/render/gif", "----\n" + strings.Repeat("|"+strings.Repeat(" ", 1000)+"|\n", 60) + "----\n", http.StatusBadRequest},
+	} {
+		req, res := fixtures.TestRequest(t, "POST", "http://localhost"+test.path, strings.NewReader(test.body))
+		server.router.ServeHTTP(res, req)
+		require.Equal(t, test.status, res.Code, test.path)
+		if test.status == http.StatusOK {
+			require.Equal(t, "image/gif", res.Header().Get("Content-Type"))
+			_, err := gif.Decode(res.Body)
+			require.NoError(t, err, test.body)
+		}
+	}
+}
+
+func TestHandleASCIIFrame_FromASCIIFixture(t *testing.T) {
+	server := NewServer()
+
+	engineServer := fixtures.StubEngineServerFromASCII(t, "GAME_ID", exampleASCIIBoard)
+	defer engineServer.Close()
+
+	req, res := fixtures.TestRequest(t, "GET", "http://localhost/games/GAME_ID/frames/0.txt?engine_url="+engineServer.URL, nil)
+	server.router.ServeHTTP(res, req)
+	require.Equal(t, http.StatusOK, res.Code)
+	require.Equal(t, `-------------
+|*          |
+|           |
+|           |
+|   OOOH    |
+|   O       |
+|   T     * |
+|           |
+|           |
+|           |
+|           |
+|           |
+-------------
+`, res.Body.String())
+}
+
+func TestHandleASCIIGame(t *testing.T) {
+	server := NewServer()
+
+	engineServer := fixtures.StubEngineServerFromASCII(t, "GAME_ID", "----\n|H |\n|T*|\n----\n", "----\n|TH|\n|  |\n----\n")
+	defer engineServer.Close()
+
+	req, res := fixtures.TestRequest(t, "GET", "http://localhost/games/GAME_ID/ascii?engine_url="+engineServer.URL, nil)
+	server.router.ServeHTTP(res, req)
+	require.Equal(t, http.StatusOK, res.Code)
+	require.Equal(t, "text/plain; charset=utf-8", res.Header().Get("Content-Type"))
+	require.Equal(t, "turn: 0\n----\n|H |\n|T*|\n----\n\nturn: 1\n----\n|TH|\n|  |\n----\n", res.Body.String())
+
+	// streaming clears the screen before each frame
+	req, res = fixtures.TestRequest(t, "GET", "http://localhost/games/GAME_ID/ascii?stream=1&frameDelay=0&engine_url="+engineServer.URL, nil)
+	server.router.ServeHTTP(res, req)
+	require.Equal(t, http.StatusOK, res.Code)
+	require.True(t, res.Flushed)
+	require.Equal(t, render.ANSIClearScreen+"turn: 0\n----\n|H |\n|T*|\n----\n"+render.ANSIClearScreen+"turn: 1\n----\n|TH|\n|  |\n----\n", res.Body.String())
+
+	req, res = fixtures.TestRequest(t, "GET", "http://localhost/games/GAME_ID/ascii?ansi=1&engine_url="+engineServer.URL, nil)
+	server.router.ServeHTTP(res, req)
+	require.Equal(t, http.StatusOK, res.Code)
+	require.Contains(t, res.Body.String(), "\x1b[")
+
+	for _, frameDelay := range []string{"-1", "501", "fast"} {
+		req, res = fixtures.TestRequest(t, "GET", "http://localhost/games/GAME_ID/ascii?stream=1&frameDelay="+frameDelay+"&engine_url="+engineServer.URL, nil)
+		server.router.ServeHTTP(res, req)
+		require.Equal(t, http.StatusBadRequest, res.Code, frameDelay)
+	}
+
+	req, res = fixtures.TestRequest(t, "GET", "http://localhost/games/NOT_A_GAME/ascii?engine_url="+engineServer.URL, nil)
+	server.router.ServeHTTP(res, req)
+	require.Equal(t, http.StatusNotFound, res.Code)
+}
+
+func TestHandleASCIIGame_StreamLimits(t *testing.T) {
+	server := NewServer()
+
+	engineServer := fixtures.StubEngineServerFromASCII(t, "GAME_ID", "----\n|H |\n|T*|\n----\n", "----\n|TH|\n|  |\n----\n")
+	defer engineServer.Close()
+
+	// streams are rejected when there are too many
+	streamSlots = make(chan struct{})
+	req, res := fixtures.TestRequest(t, "GET", "http://localhost/games/GAME_ID/ascii?stream=1&frameDelay=0&engine_url="+engineServer.URL, nil)
+	server.router.ServeHTTP(res, req)
+	require.Equal(t, http.StatusTooManyRequests, res.Code)
+
+	// but exporting a game without streaming isn't limited
+	req, res = fixtures.TestRequest(t, "GET", "http://localhost/games/GAME_ID/ascii?engine_url="+engineServer.URL, nil)
+	server.router.ServeHTTP(res, req)
+	require.Equal(t, http.StatusOK, res.Code)
+
+	// streams end straight away when the server shuts down
+	server = NewServer()
+	require.NoError(t, server.Shutdown(time.Second))
+	start := time.Now()
+	req, res = fixtures.TestRequest(t, "GET", "http://localhost/games/GAME_ID/ascii?stream=1&frameDelay=500&engine_url="+engineServer.URL, nil)
+	server.router.ServeHTTP(res, req)
+	require.Equal(t, http.StatusOK, res.Code)
+	require.Less(t, time.Since(start), 5*time.Second)
+	require.Equal(t, render.ANSIClearScreen+"turn: 0\n----\n|H |\n|T*|\n----\n", res.Body.String())
+}
+
+func TestHandleGIFGame_MaxBytes(t *testing.T) {
+	fixtures.TestInRootDir()
+	server := NewServer()
+
+	engineServer := fixtures.StubEngineServer(func(res http.ResponseWriter, req *http.Request) {
+		if strings.HasSuffix(req.URL.Path, "/frames") {
+			_, _ = res.Write([]byte(fixtures.ExampleGameFramesResponse))
+		} else {
+			_, _ = res.Write([]byte(fixtures.ExampleGameResponse))
+		}
+	})
+	defer engineServer.Close()
+
+	// a large budget keeps the requested size
+	req, res := fixtures.TestRequest(t, "GET", "http://localhost/games/GAME_ID/444x444.gif?maxBytes=10000000&engine_url="+engineServer.URL, nil)
+	server.router.ServeHTTP(res, req)
+	require.Equal(t, http.StatusOK, res.Code)
+	require.Equal(t, "444x444", res.Header().Get("X-GIF-Size"))
+	require.Equal(t, "1", res.Header().Get("X-GIF-Frame-Step"))
+	require.NotEmpty(t, res.Header().Get("X-GIF-Estimated-Bytes"))
+	fullSize := res.Body.Len()
+
+	// a smaller budget picks a smaller size
+	req, res = fixtures.TestRequest(t, "GET", fmt.Sprintf("http://localhost/games/GAME_ID/444x444.gif?maxBytes=%d&engine_url=%s", fullSize/2, engineServer.URL), nil)
+	server.router.ServeHTTP(res, req)
+	require.Equal(t, http.StatusOK, res.Code)
+	require.NotEqual(t, "444x444", res.Header().Get("X-GIF-Size"))
+
+	// the legacy endpoint reports the default size
+	req, res = fixtures.TestRequest(t, "GET", "http://localhost/games/GAME_ID/gif?maxBytes=10000000&engine_url="+engineServer.URL, nil)
+	server.router.ServeHTTP(res, req)
+	require.Equal(t, http.StatusOK, res.Code)
+	require.Equal(t, "224x224", res.Header().Get("X-GIF-Size"))
+
+	for _, maxBytes := range []string{"0", "-5", "lots"} {
+		req, res = fixtures.TestRequest(t, "GET", "http://localhost/games/GAME_ID/gif?maxBytes="+maxBytes+"&engine_url="+engineServer.URL, nil)
+		server.router.ServeHTTP(res, req)
+		require.Equal(t, http.StatusBadRequest, res.Code, maxBytes)
+	}
+
+	// budgets that can't be met are valid requests, but can't be rendered
+	req, res = fixtures.TestRequest(t, "GET", "http://localhost/games/GAME_ID/gif?maxBytes=10&engine_url="+engineServer.URL, nil)
+	server.router.ServeHTTP(res, req)
+	require.Equal(t, http.StatusUnprocessableEntity, res.Code)
+}
+
+func TestHandleGIFGame_Playback(t *testing.T) {
+	fixtures.TestInRootDir()
+	server := NewServer()
+
+	engineServer := fixtures.StubEngineServer(func(res http.ResponseWriter, req *http.Request) {
+		if strings.HasSuffix(req.URL.Path, "/frames") {
+			_, _ = res.Write([]byte(fixtures.ExampleGameFramesResponse))
+		} else {
+			_, _ = res.Write([]byte(fixtures.ExampleGameResponse))
+		}
+	})
+	defer engineServer.Close()
+
+	for _, query := range []string{"", "frames=0-0", "step=2", "speed=fast", "speed=slow&loopDelay=0", "frameDelay=0&loopDelay=1000", "timelapse=1&step=3", "timelapse=false", "slowEvents=1&speed=fastest", "slowEvents=false", "playback=reverse", "playback=boomerang&step=2"} {
+		req, res := fixtures.TestRequest(t, "GET", "http://localhost/games/GAME_ID/gif?"+query+"&engine_url="+engineServer.URL, nil)
+		server.router.ServeHTTP(res, req)
+		require.Equal(t, http.StatusOK, res.Code, query)
+		_, err := gif.DecodeAll(res.Body)
+		require.NoError(t, err, query)
+	}
+
+	for _, query := range []string{"frames=5", "frames=3-1", "frames=a-b", "step=0", "step=101", "step=x", "speed=warp", "speed=fast&frameDelay=2", "frameDelay=-1", "frameDelay=1001", "loopDelay=soon", "playback=sideways", "playback=forward", "timelapse=yes", "slowEvents=yes"} {
+		req, res := fixtures.TestRequest(t, "GET", "http://localhost/games/GAME_ID/gif?"+query+"&engine_url="+engineServer.URL, nil)
+		server.router.ServeHTTP(res, req)
+		require.Equal(t, http.StatusBadRequest, res.Code, query)
+		require.NotContains(t, res.Header().Get("Content-Type"), "image/gif", query)
+	}
+}
+
+func TestParseBoolParam(t *testing.T) {
+	for param, expected := range map[string]bool{"": false, "1": true, "true": true, "0": false, "false": false} {
+		v, err := parseBoolParam(url.Values{"timelapse": {param}}, "timelapse")
+		require.NoError(t, err, param)
+		require.Equal(t, expected, v, param)
+	}
+
+	_, err := parseBoolParam(url.Values{"timelapse": {"off"}}, "timelapse")
+	require.Error(t, err)
+}
+
+func TestParseFramesParam(t *testing.T) {
+	offset, limit, err := parseFramesParam("")
+	require.NoError(t, err)
+	require.Equal(t, 0, offset)
+	require.Equal(t, math.MaxInt32, limit)
+
+	offset, limit, err = parseFramesParam("3-7")
+	require.NoError(t, err)
+	require.Equal(t, 3, offset)
+	require.Equal(t, 5, limit)
+
+	for _, param := range []string{"3", "-3", "3-", "7-3", "1-2-3", "99999999999999999999-99999999999999999999"} {
+		_, _, err = parseFramesParam(param)
+		require.Error(t, err, param)
+	}
+}
+
+func TestBudgetSizes(t *testing.T) {
+	require.Equal(t, []image.Point{{X: 0, Y: 0}, {X: 114, Y: 114}}, budgetSizes(11, 11, 0, 0))
+	require.Equal(t, []image.Point{{X: 444, Y: 444}, {X: 334, Y: 334}, {X: 224, Y: 224}, {X: 114, Y: 114}}, budgetSizes(11, 11, 444, 444))
+	require.Equal(t, []image.Point{{X: 114, Y: 114}}, budgetSizes(11, 11, 114, 114))
+}
+
+func TestParseWatermark(t *testing.T) {
+	base := render.DefaultWatermark
+
+	// no options leaves the base unchanged
+	wm, err := parseWatermark(base, "", "", "")
+	require.NoError(t, err)
+	require.Equal(t, base, wm)
+
+	wm, err = parseWatermark(base, "none", "", "")
+	require.NoError(t, err)
+	require.Equal(t, "", wm.Asset)
+
+	wm, err = parseWatermark(base, "", "0.5", "top-right")
+	require.NoError(t, err)
+	require.Equal(t, render.Watermark{Asset: base.Asset, Opacity: 0.5, Position: render.WatermarkTopRight}, wm)
+
+	for _, opacity := range []string{"-0.1", "1.1", "NaN", "half"} {
+		_, err = parseWatermark(base, "", opacity, "")
+		require.Error(t, err, opacity)
+	}
+}
+
+func TestConfigureDefaultWatermark(t *testing.T) {
+	defer configureDefaultWatermark()
+
+	os.Setenv("WATERMARK", "none")
+	defer os.Unsetenv("WATERMARK")
+	configureDefaultWatermark()
+	require.Equal(t, "", defaultWatermark.Asset)
+
+	// invalid configuration falls back to the built-in watermark
+	os.Setenv("WATERMARK_POSITION", "nowhere")
+	defer os.Unsetenv("WATERMARK_POSITION")
+	configureDefaultWatermark()
+	require.Equal(t, render.DefaultWatermark, defaultWatermark)
+}
+
+func TestParseViewportParam(t *testing.T) {
+	v, err := parseViewportParam("1,2,3,4")
+	require.NoError(t, err)
+	require.Equal(t, render.Viewport{X: 1, Y: 2, Width: 3, Height: 4}, v)
+
+	for _, param := range []string{"", "1,2,3", "1,2,3,4,5", "-1,2,3,4", "a,b,c,d", "1, 2, 3, 4"} {
+		_, err := parseViewportParam(param)
+		require.Error(t, err, param)
+	}
+}
+
 func TestHandleASCIIFrame_NotFound(t *testing.T) {
 	server := NewServer()
 
@@ -351,6 +1068,21 @@ func TestHandleASCIIFrame_Success(t *testing.T) {
 		require.Equal(t, http.StatusOK, res.Code)
 		require.Equal(t, "text/plain; charset=utf-8", res.Result().Header.Get("Content-Type"))
 	}
+
+	{
+		req, res := fixtures.TestRequest(t, "GET", "http://localhost/games/GAME_ID/frames/0.txt", nil)
+		query := req.URL.Query()
+		query.Set("engine_url", engineServer.URL)
+		query.Set("ansi", "1")
+		req.URL.RawQuery = query.Encode()
+
+		server.router.ServeHTTP(res, req)
+
+		require.Equal(t, http.StatusOK, res.Code)
+		require.Equal(t, "text/plain; charset=utf-8", res.Result().Header.Get("Content-Type"))
+		require.Contains(t, res.Body.String(), "\x1b[38;2;", "should contain ANSI colours")
+		require.Contains(t, res.Body.String(), "┌", "should contain box-drawing characters")
+	}
 }
 
 func TestValidateGIFSize(t *testing.T) {
@@ -8,10 +8,13 @@ import (
 	"os"
 	"os/signal"
 	"runtime"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
+	"github.com/BattlesnakeOfficial/exporter/media"
+	"github.com/BattlesnakeOfficial/exporter/render"
 	"github.com/alitto/pond"
 	log "github.com/sirupsen/logrus"
 	"goji.io/v3"
@@ -28,6 +31,12 @@ type Server struct {
 }
 
 func NewServer() *Server {
+	configureDefaultWatermark()
+	configureFrameWorkers()
+	configureRasterizer()
+	configureMediaSource()
+	configureStreams()
+
 	log.WithField("size", runtime.NumCPU()).Info("Starting GIF render pool")
 	renderPool := pond.New(runtime.NumCPU(), DEFAULT_RENDER_BACKLOG)
 
@@ -46,22 +55,163 @@ func NewServer() *Server {
 	// Export routes
 	mux.HandleFunc(pat.Get("/avatars/*"), withCaching(handleAvatar))
 
+	mux.HandleFunc(pat.Get("/customizations"), withCaching(handleCustomizationCatalog))
+	mux.HandleFunc(pat.Get("/customizations/:type"), withCaching(handleCustomizationTypeCatalog))
+	// the gallery is registered first, so that it isn't handled as a customization called "gallery"
+	mux.HandleFunc(pat.Get("/customizations/:type/gallery.:ext"), withConcurrencyLimit(renderPool, withCaching(handleCustomizationGallery)))
 	mux.HandleFunc(pat.Get("/customizations/:type/:name.:ext"), withCaching(handleCustomization))
 
 	mux.HandleFunc(pat.Get("/games/:game/:size.gif"), withConcurrencyLimit(renderPool, withCaching(handleGIFGameDimensions)))
 	mux.HandleFunc(pat.Get("/games/:game/gif"), withConcurrencyLimit(renderPool, withCaching(handleGIFGame)))
 	mux.HandleFunc(pat.Get("/games/:game/frames/:frame/:size.gif"), withConcurrencyLimit(renderPool, withCaching(handleGIFFrameDimensions)))
 	mux.HandleFunc(pat.Get("/games/:game/frames/:frame/gif"), withConcurrencyLimit(renderPool, withCaching(handleGIFFrame)))
+	mux.HandleFunc(pat.Get("/games/:game/frames/:frame/:size.png"), withConcurrencyLimit(renderPool, withCaching(handlePNGFrameDimensions)))
+	mux.HandleFunc(pat.Get("/games/:game/frames/:frame/png"), withConcurrencyLimit(renderPool, withCaching(handlePNGFrame)))
+
+	mux.HandleFunc(pat.Post("/render/:size.gif"), withConcurrencyLimit(renderPool, handleRenderGIFDimensions))
+	mux.HandleFunc(pat.Post("/render/gif"), withConcurrencyLimit(renderPool, handleRenderGIF))
 
+	mux.HandleFunc(pat.Get("/games/:game/ascii"), handleASCIIGame)
 	mux.HandleFunc(pat.Get("/games/:game/frames/:frame.txt"), withCaching(handleASCIIFrame))
 	mux.HandleFunc(pat.Get("/games/:game/frames/:frame/ascii"), withCaching(handleASCIIFrame))
 
+	httpServer := &http.Server{
+		Handler: mux,
+	}
+	// streams can run for minutes, so they're ended when shutting down rather than waited for
+	httpServer.RegisterOnShutdown(stopStreams)
+
 	return &Server{
-		router: mux,
-		httpServer: &http.Server{
-			Handler: mux,
-		},
+		router:     mux,
+		httpServer: httpServer,
+	}
+}
+
+// configureDefaultWatermark sets the server-wide default watermark from the environment.
+// Invalid configuration is logged and the built-in watermark is used instead.
+func configureDefaultWatermark() {
+	// the branding assets are read once here, rather than on the first request that uses them
+	log.WithField("assets", media.BrandingAssets()).Info("Loaded branding assets")
+
+	wm, err := parseWatermark(render.DefaultWatermark, os.Getenv("WATERMARK"), os.Getenv("WATERMARK_OPACITY"), os.Getenv("WATERMARK_POSITION"))
+	if err != nil {
+		log.WithError(err).Error("Invalid watermark configuration - using the default watermark")
+		wm = render.DefaultWatermark
 	}
+	defaultWatermark = wm
+}
+
+// configureFrameWorkers sets how many GIF frames can be rendered at the same time across all requests.
+// This defaults to the number of CPUs, so that a single large GIF can use every core
+// while many concurrent GIFs still share the same CPU budget.
+func configureFrameWorkers() {
+	workers := runtime.NumCPU()
+	if env := os.Getenv("RENDER_FRAME_WORKERS"); env != "" {
+		n, err := strconv.Atoi(env)
+		if err != nil || n < 1 {
+			log.WithField("RENDER_FRAME_WORKERS", env).Error("Invalid frame worker configuration - using the number of CPUs")
+		} else {
+			workers = n
+		}
+	}
+
+	log.WithField("size", workers).Info("Configuring GIF frame workers")
+	render.SetFrameWorkers(workers)
+}
+
+// configureRasterizer chooses how SVG customizations are rasterized.
+// This defaults to inkscape when it's installed, and the in-process rasterizer otherwise.
+func configureRasterizer() {
+	name := media.RasterizerAuto
+	if env := os.Getenv("SVG_RASTERIZER"); env != "" {
+		name = env
+	}
+	if env := os.Getenv("INKSCAPE_SHELL_WORKERS"); env != "" {
+		n, err := strconv.Atoi(env)
+		if err != nil || n < 1 {
+			log.WithField("INKSCAPE_SHELL_WORKERS", env).Error("Invalid inkscape worker configuration - using the default")
+		} else {
+			media.SetInkscapeShellWorkers(n)
+		}
+	}
+
+	timeout := 30 * time.Second
+	if env := os.Getenv("SVG_RASTERIZE_TIMEOUT_SECONDS"); env != "" {
+		n, err := strconv.Atoi(env)
+		if err != nil || n < 1 {
+			log.WithField("SVG_RASTERIZE_TIMEOUT_SECONDS", env).Error("Invalid SVG timeout configuration - using the default")
+		} else {
+			timeout = time.Duration(n) * time.Second
+		}
+	}
+	concurrency := runtime.NumCPU()
+	if env := os.Getenv("SVG_RASTERIZE_CONCURRENCY"); env != "" {
+		n, err := strconv.Atoi(env)
+		if err != nil || n < 1 {
+			log.WithField("SVG_RASTERIZE_CONCURRENCY", env).Error("Invalid SVG concurrency configuration - using the number of CPUs")
+		} else {
+			concurrency = n
+		}
+	}
+	media.SetRasterizeLimits(timeout, concurrency)
+
+	if err := media.SetRasterizer(name); err != nil {
+		log.WithField("SVG_RASTERIZER", name).WithError(err).Error("Invalid SVG rasterizer configuration - using auto")
+		name = media.RasterizerAuto
+		_ = media.SetRasterizer(name)
+	}
+	log.WithFields(log.Fields{
+		"rasterizer":  name,
+		"timeout":     timeout,
+		"concurrency": concurrency,
+	}).Info("Configuring SVG rasterizer")
+}
+
+// configureMediaSource chooses where customizations come from.
+// Media servers and asset bundle directories can be used instead of the Battlesnake media server,
+// so that private customizations can be served, or custom snakes rendered offline.
+func configureMediaSource() {
+	if spec := os.Getenv("MEDIA_SOURCES"); spec != "" {
+		if os.Getenv("MEDIA_BUNDLE_DIR") != "" {
+			log.Warn("Both MEDIA_SOURCES and MEDIA_BUNDLE_DIR are set - ignoring MEDIA_BUNDLE_DIR")
+		}
+		sources, err := media.ParseSources(spec)
+		if err != nil {
+			log.WithField("MEDIA_SOURCES", spec).WithError(err).Error("Invalid media source configuration - using the default media source")
+			return
+		}
+		log.WithField("sources", spec).Info("Using configured media sources for customizations")
+		media.SetSource(sources)
+		return
+	}
+
+	dir := os.Getenv("MEDIA_BUNDLE_DIR")
+	if dir == "" {
+		return
+	}
+
+	bundle, err := media.NewBundleSource(dir)
+	if err != nil {
+		log.WithField("MEDIA_BUNDLE_DIR", dir).WithError(err).Error("Invalid asset bundle configuration - using the default media source")
+		return
+	}
+	log.WithField("dir", dir).Info("Using asset bundle for customizations")
+	media.SetSource(bundle)
+}
+
+// configureStreams sets how many games can be streamed as text at the same time.
+func configureStreams() {
+	concurrency := defaultStreamConcurrency
+	if env := os.Getenv("STREAM_CONCURRENCY"); env != "" {
+		n, err := strconv.Atoi(env)
+		if err != nil || n < 1 {
+			log.WithField("STREAM_CONCURRENCY", env).Error("Invalid stream concurrency configuration - using the default")
+		} else {
+			concurrency = n
+		}
+	}
+	streamSlots = make(chan struct{}, concurrency)
+	streamsStopped, stopStreams = context.WithCancel(context.Background())
 }
 
 func withConcurrencyLimit(pool *pond.WorkerPool, wrappedHandler http.HandlerFunc) http.HandlerFunc {
@@ -71,6 +221,11 @@ func withConcurrencyLimit(pool *pond.WorkerPool, wrappedHandler http.HandlerFunc
 		// Try to submit a job to the pool asynchronously, and if it fails, reject the request
 		submitted := pool.TrySubmit(func() {
 			defer close(done)
+			// the client may have gone away while the request was waiting for a worker
+			if err := r.Context().Err(); err != nil {
+				log.WithField("url", r.URL.String()).Info("request cancelled before a worker was available")
+				return
+			}
 			wrappedHandler(w, r)
 		})
 
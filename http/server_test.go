@@ -1,10 +1,17 @@
 package http
 
 import (
+	"context"
 	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
 	"testing"
 
 	"github.com/BattlesnakeOfficial/exporter/fixtures"
+	"github.com/BattlesnakeOfficial/exporter/media"
+	"github.com/BattlesnakeOfficial/exporter/render"
+	"github.com/alitto/pond"
 	"github.com/stretchr/testify/require"
 	"goji.io/v3/pat"
 )
@@ -20,3 +27,78 @@ func TestHandlesPanic(t *testing.T) {
 	server.router.ServeHTTP(res, req)
 	require.Equal(t, http.StatusInternalServerError, res.Code)
 }
+
+func TestConfigureFrameWorkers(t *testing.T) {
+	defer configureFrameWorkers()
+
+	os.Setenv("RENDER_FRAME_WORKERS", "3")
+	defer os.Unsetenv("RENDER_FRAME_WORKERS")
+	configureFrameWorkers()
+	require.Equal(t, 3, render.FrameWorkers())
+
+	// invalid configuration falls back to the number of CPUs
+	os.Setenv("RENDER_FRAME_WORKERS", "lots")
+	configureFrameWorkers()
+	require.Equal(t, runtime.NumCPU(), render.FrameWorkers())
+}
+
+func TestWithConcurrencyLimit_Cancelled(t *testing.T) {
+	pool := pond.New(1, 10)
+	defer pool.StopAndWait()
+
+	called := false
+	handler := withConcurrencyLimit(pool, func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	})
+
+	// requests that are cancelled before they get a worker aren't handled
+	req, res := fixtures.TestRequest(t, "GET", "http://localhost/games/GAME_ID/gif", nil)
+	ctx, cancel := context.WithCancel(req.Context())
+	cancel()
+	handler(res, req.WithContext(ctx))
+	require.False(t, called)
+
+	handler(res, req)
+	require.True(t, called)
+}
+
+func TestHandleGIFGame_Cancelled(t *testing.T) {
+	fixtures.TestInRootDir()
+	server := NewServer()
+
+	engineServer := fixtures.StubEngineServerFromASCII(t, "GAME_ID", exampleASCIIBoard, exampleASCIIBoard)
+	defer engineServer.Close()
+
+	req, res := fixtures.TestRequest(t, "GET", "http://localhost/games/GAME_ID/gif?engine_url="+engineServer.URL, nil)
+	ctx, cancel := context.WithCancel(req.Context())
+	cancel()
+	server.router.ServeHTTP(res, req.WithContext(ctx))
+
+	// nothing is rendered, and there's nobody to send an error to
+	require.Empty(t, res.Body.String())
+}
+
+func TestConfigureMediaSource(t *testing.T) {
+	defer media.SetSource(media.ServerSource{})
+
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "snakes/heads"), os.ModePerm))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "snakes/heads/secret.svg"), []byte(`<svg viewBox="0 0 100 100"><path d="M0 0h100v100z"/></svg>`), 0o600))
+
+	os.Setenv("MEDIA_SOURCES", "private="+dir)
+	defer os.Unsetenv("MEDIA_SOURCES")
+	server := NewServer()
+
+	for _, path := range []string{
+		"/avatars/head:private:secret/500x100.svg",
+		"/customizations/head/private:secret.svg",
+	} {
+		req, res := fixtures.TestRequest(t, "GET", "http://localhost"+path, nil)
+		server.router.ServeHTTP(res, req)
+		require.Equal(t, http.StatusOK, res.Code, path)
+	}
+
+	req, res := fixtures.TestRequest(t, "GET", "http://localhost/customizations/head/private:missing.svg", nil)
+	server.router.ServeHTTP(res, req)
+	require.Equal(t, http.StatusNotFound, res.Code)
+}
@@ -0,0 +1,348 @@
+package inkscape
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrProcessExited is returned when an inkscape process exits while it's being used.
+	ErrProcessExited = errors.New("inkscape process exited")
+	// ErrPoolClosed is returned when a pool is used after it's been closed.
+	ErrPoolClosed = errors.New("inkscape pool is closed")
+)
+
+// Pool rasterizes SVGs with long-lived inkscape processes running in shell mode.
+// This avoids starting a new inkscape process for every image, which is slow and uses a lot of memory.
+//
+// Processes are started when they're first needed, and restarted if they crash, time out, or fail a health check.
+// Jobs that take longer than JobTimeout return ErrTimeout, and their process is restarted.
+type Pool struct {
+	// JobTimeout limits how long a single image can take. The process is restarted when it's exceeded.
+	JobTimeout time.Duration
+	// StartTimeout limits how long a new process can take to be ready, and how long a health check can take.
+	StartTimeout time.Duration
+	// HealthCheckInterval is how long a process can be idle before it's checked again before being used.
+	HealthCheckInterval time.Duration
+
+	client Client
+	// slots holds one entry per process, which is nil when the process needs to be started
+	slots chan *shellProcess
+
+	mu     sync.Mutex
+	closed bool
+	done   chan struct{}
+}
+
+// NewPool creates a pool of up to size inkscape processes, using the command from the client.
+// The timeouts can be changed before the pool is first used.
+func NewPool(client Client, size int) *Pool {
+	if size < 1 {
+		size = 1
+	}
+	p := &Pool{
+		JobTimeout:          30 * time.Second,
+		StartTimeout:        30 * time.Second,
+		HealthCheckInterval: time.Minute,
+		client:              client,
+		slots:               make(chan *shellProcess, size),
+		done:                make(chan struct{}),
+	}
+	for i := 0; i < size; i++ {
+		p.slots <- nil
+	}
+	return p
+}
+
+// IsAvailable checks whether the inkscape command is locally available.
+func (p *Pool) IsAvailable() bool {
+	return p.client.IsAvailable()
+}
+
+// SVGToPNG rasterizes the SVG at the specified path to PNG format.
+// If the context is done before the image is finished, its process is restarted.
+func (p *Pool) SVGToPNG(ctx context.Context, path string, width, height int) (image.Image, error) {
+	if height < 1 {
+		return nil, errors.New("invalid height")
+	}
+	if width < 1 {
+		return nil, errors.New("invalid width")
+	}
+
+	_, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	// the process may not have the same working directory, so it needs an absolute path
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	if strings.ContainsAny(path, ";\r\n") {
+		return nil, fmt.Errorf("unsupported SVG path: %q", path)
+	}
+
+	outDir, err := os.MkdirTemp("", "inkscape")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(outDir)
+	outPath := filepath.Join(outDir, "out.png")
+
+	proc, err := p.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	command := fmt.Sprintf("file-open:%s;export-type:png;export-filename:%s;export-width:%d;export-height:%d;export-do;file-close", path, outPath, width, height)
+	err = proc.run(ctx, command, p.JobTimeout)
+	p.release(proc)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(outPath)
+	if errors.Is(err, os.ErrNotExist) {
+		// inkscape reports problems with the SVG by not exporting anything
+		return nil, ErrInvalidSVG
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	// the output is limited the same way as when inkscape writes it to stdout
+	data := &limitedBuffer{max: p.client.maxOutputBytes()}
+	if _, err := io.Copy(data, f); err != nil {
+		return nil, err
+	}
+	if data.overflowed {
+		return nil, ErrOutputTooLarge
+	}
+	return png.Decode(&data.buf)
+}
+
+// SVGStringToPNG rasterizes the provided SVG text to PNG format.
+func (p *Pool) SVGStringToPNG(ctx context.Context, svgText string, width, height int) (image.Image, error) {
+	if height < 1 {
+		return nil, errors.New("invalid height")
+	}
+	if width < 1 {
+		return nil, errors.New("invalid width")
+	}
+
+	// shell mode can only open files
+	f, err := os.CreateTemp("", "inkscape-*.svg")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(svgText)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return p.SVGToPNG(ctx, f.Name(), width, height)
+}
+
+// Close stops all of the processes. Processes that are in use are stopped once they're finished.
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil
+	}
+	p.closed = true
+	close(p.done)
+
+	for {
+		select {
+		case proc := <-p.slots:
+			proc.kill()
+		default:
+			return nil
+		}
+	}
+}
+
+// acquire waits for a process to be free, and makes sure it's running and healthy.
+func (p *Pool) acquire(ctx context.Context) (*shellProcess, error) {
+	var proc *shellProcess
+	select {
+	case proc = <-p.slots:
+	case <-p.done:
+		return nil, ErrPoolClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	if proc != nil && time.Since(proc.lastUsed) >= p.HealthCheckInterval {
+		// shell mode prints a prompt for empty commands too, so this checks that the process is responding
+		if err := proc.run(context.Background(), "", p.StartTimeout); err != nil {
+			proc.kill()
+			proc = nil
+		}
+	}
+	if proc == nil || proc.hasExited() {
+		var err error
+		proc, err = startShellProcess(p.client.cmd(), p.StartTimeout)
+		if err != nil {
+			// keep the slot, so the process can be started again later
+			p.release(nil)
+			return nil, err
+		}
+	}
+	return proc, nil
+}
+
+// release makes the process available again, or frees its slot if it has stopped.
+func (p *Pool) release(proc *shellProcess) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if proc != nil && proc.hasExited() {
+		proc = nil
+	}
+	if p.closed {
+		proc.kill()
+		return
+	}
+	p.slots <- proc
+}
+
+// shellProcess is an inkscape process running in shell mode, which runs one command per line of input.
+type shellProcess struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	// prompts receives a value each time inkscape is ready for the next command
+	prompts chan struct{}
+	// exited is closed once the process has exited
+	exited   chan struct{}
+	lastUsed time.Time
+}
+
+// startShellProcess starts inkscape in shell mode, and waits for it to be ready.
+func startShellProcess(command string, timeout time.Duration) (*shellProcess, error) {
+	cmd := exec.Command(command, "--shell")
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	proc := &shellProcess{
+		cmd:     cmd,
+		stdin:   stdin,
+		prompts: make(chan struct{}, 1),
+		exited:  make(chan struct{}),
+	}
+	go proc.readPrompts(stdout)
+	go func() {
+		// this also closes stdout, so readPrompts finishes even if a child process is still holding it open
+		_ = cmd.Wait()
+		close(proc.exited)
+	}()
+
+	if err := proc.waitForPrompt(context.Background(), timeout); err != nil {
+		proc.kill()
+		return nil, err
+	}
+	proc.lastUsed = time.Now()
+	return proc, nil
+}
+
+// readPrompts watches the process output for the "> " prompt at the start of a line.
+func (sp *shellProcess) readPrompts(stdout io.Reader) {
+	r := bufio.NewReader(stdout)
+	var line []byte
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return
+		}
+		if b == '\n' {
+			line = line[:0]
+			continue
+		}
+		line = append(line, b)
+		if string(line) == "> " {
+			line = line[:0]
+			select {
+			case sp.prompts <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// run sends a single command, and waits for inkscape to finish it.
+// If it takes too long, or the context is done first, the process is killed.
+func (sp *shellProcess) run(ctx context.Context, command string, timeout time.Duration) error {
+	sp.lastUsed = time.Now()
+	if _, err := io.WriteString(sp.stdin, command+"\n"); err != nil {
+		sp.kill()
+		return ErrProcessExited
+	}
+	if err := sp.waitForPrompt(ctx, timeout); err != nil {
+		sp.kill()
+		return err
+	}
+	return nil
+}
+
+func (sp *shellProcess) waitForPrompt(ctx context.Context, timeout time.Duration) error {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-sp.prompts:
+		return nil
+	case <-sp.exited:
+		return ErrProcessExited
+	case <-timer.C:
+		return ErrTimeout
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("%w: %w", ErrTimeout, ctx.Err())
+		}
+		return ctx.Err()
+	}
+}
+
+func (sp *shellProcess) hasExited() bool {
+	select {
+	case <-sp.exited:
+		return true
+	default:
+		return false
+	}
+}
+
+// kill stops the process, if it's running. It's safe to call on a nil process.
+func (sp *shellProcess) kill() {
+	if sp == nil || sp.hasExited() {
+		return
+	}
+	_ = sp.stdin.Close()
+	_ = sp.cmd.Process.Kill()
+	<-sp.exited
+}
@@ -0,0 +1,131 @@
+package inkscape_test
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/BattlesnakeOfficial/exporter/imagetest"
+	"github.com/BattlesnakeOfficial/exporter/inkscape"
+	"github.com/stretchr/testify/require"
+)
+
+// newFakePool creates a pool that uses a script which emulates inkscape's shell mode.
+func newFakePool(t *testing.T, size int) *inkscape.Pool {
+	pool := inkscape.NewPool(inkscape.Client{Command: "testdata/fake-inkscape.sh"}, size)
+	pool.JobTimeout = 500 * time.Millisecond
+	pool.StartTimeout = 5 * time.Second
+	t.Cleanup(func() { _ = pool.Close() })
+	return pool
+}
+
+// copySVG copies the example SVG to a path containing the given name, which controls how the fake inkscape behaves.
+func copySVG(t *testing.T, name string) string {
+	b, err := os.ReadFile("testdata/example.svg")
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), name+".svg")
+	require.NoError(t, os.WriteFile(path, b, 0o600))
+	return path
+}
+
+func TestPoolSVGToPNG(t *testing.T) {
+	pool := newFakePool(t, 2)
+	require.True(t, pool.IsAvailable())
+
+	got, err := pool.SVGToPNG(context.Background(), "testdata/example.svg", 100, 100)
+	require.NoError(t, err)
+	imagetest.Equal(t, loadTestImage(t), got)
+
+	got, err = pool.SVGStringToPNG(context.Background(), `<svg height="100" width="100"></svg>`, 100, 100)
+	require.NoError(t, err)
+	imagetest.Equal(t, loadTestImage(t), got)
+
+	// more jobs than processes at the same time
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := pool.SVGToPNG(context.Background(), "testdata/example.svg", 100, 100)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	// the pool should validate its inputs, like the client does
+	_, err = pool.SVGToPNG(context.Background(), "testdata/example.svg", 0, 100)
+	require.Equal(t, errors.New("invalid width"), err)
+	_, err = pool.SVGStringToPNG(context.Background(), "<svg></svg>", 100, -1)
+	require.Equal(t, errors.New("invalid height"), err)
+	_, err = pool.SVGToPNG(context.Background(), "testdata/filedoesntexist.svg", 100, 100)
+	require.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func TestPoolRestartsProcesses(t *testing.T) {
+	pool := newFakePool(t, 1)
+
+	// a crash fails the job, but the next one gets a new process
+	_, err := pool.SVGToPNG(context.Background(), copySVG(t, "crash"), 100, 100)
+	require.ErrorIs(t, err, inkscape.ErrProcessExited)
+	_, err = pool.SVGToPNG(context.Background(), "testdata/example.svg", 100, 100)
+	require.NoError(t, err)
+
+	// same for a process that stops responding
+	start := time.Now()
+	_, err = pool.SVGToPNG(context.Background(), copySVG(t, "hang"), 100, 100)
+	require.ErrorIs(t, err, inkscape.ErrTimeout)
+	require.Less(t, time.Since(start), 5*time.Second)
+	_, err = pool.SVGToPNG(context.Background(), "testdata/example.svg", 100, 100)
+	require.NoError(t, err)
+
+	// and for a job that's still running when its context is done
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	pool.JobTimeout = time.Minute
+	_, err = pool.SVGToPNG(ctx, copySVG(t, "hang"), 100, 100)
+	require.ErrorIs(t, err, inkscape.ErrTimeout)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	_, err = pool.SVGToPNG(context.Background(), "testdata/example.svg", 100, 100)
+	require.NoError(t, err)
+
+	// idle processes are health checked before being used
+	pool.HealthCheckInterval = 0
+	_, err = pool.SVGToPNG(context.Background(), "testdata/example.svg", 100, 100)
+	require.NoError(t, err)
+}
+
+func TestPoolErrors(t *testing.T) {
+	pool := inkscape.NewPool(inkscape.Client{Command: "invalidinkscape"}, 1)
+	require.False(t, pool.IsAvailable())
+	_, err := pool.SVGToPNG(context.Background(), "testdata/example.svg", 100, 100)
+	require.Error(t, err)
+	// the slot is still usable after a process fails to start
+	_, err = pool.SVGToPNG(context.Background(), "testdata/example.svg", 100, 100)
+	require.Error(t, err)
+	require.NotErrorIs(t, err, inkscape.ErrPoolClosed)
+
+	// exported images are limited the same way as the client's output
+	pool = inkscape.NewPool(inkscape.Client{Command: "testdata/fake-inkscape.sh", MaxOutputBytes: 1 << 20}, 1)
+	pool.StartTimeout = 5 * time.Second
+	_, err = pool.SVGToPNG(context.Background(), copySVG(t, "huge"), 100, 100)
+	require.ErrorIs(t, err, inkscape.ErrOutputTooLarge)
+	require.NoError(t, pool.Close())
+
+	pool = newFakePool(t, 1)
+	_, err = pool.SVGToPNG(context.Background(), "testdata/example.svg", 100, 100)
+	require.NoError(t, err)
+	require.NoError(t, pool.Close())
+	require.NoError(t, pool.Close())
+	_, err = pool.SVGToPNG(context.Background(), "testdata/example.svg", 100, 100)
+	require.ErrorIs(t, err, inkscape.ErrPoolClosed)
+}
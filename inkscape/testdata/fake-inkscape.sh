@@ -0,0 +1,37 @@
+#!/bin/sh
+# Emulates inkscape for testing, in both shell mode and when exporting a single SVG to stdout.
+# Every export writes example.png, and SVGs with these words in their path (or their text, when piped) behave badly:
+#   crash - exits with an error
+#   hang  - stops responding
+#   huge  - outputs far too much data
+testdata=$(dirname "$0")
+
+if [ "$1" != "--shell" ]; then
+	if [ "$1" = "--pipe" ]; then
+		input=$(cat)
+	else
+		input=$1
+	fi
+	case "$input" in
+		*crash*) echo "fake crash" >&2; exit 1 ;;
+		*hang*) exec sleep 60 ;;
+		*huge*) exec cat /dev/zero ;;
+	esac
+	exec cat "$testdata/example.png"
+fi
+
+printf '> '
+while IFS= read -r line; do
+	case "$line" in
+		*crash*) exit 1 ;;
+		*hang*) exec sleep 60 ;;
+	esac
+	out=$(printf '%s\n' "$line" | tr ';' '\n' | sed -n 's/^export-filename://p')
+	if [ -n "$out" ]; then
+		case "$line" in
+			*huge*) head -c 10000000 /dev/zero > "$out" ;;
+			*) cp "$testdata/example.png" "$out" ;;
+		esac
+	fi
+	printf '> '
+done
@@ -2,22 +2,45 @@ package inkscape
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"image"
 	"image/png"
+	"io"
 	"os"
 	"os/exec"
+	"time"
 )
 
 var defaultCommand = "inkscape"
 
+// defaultMaxOutputBytes is the largest PNG that inkscape can output by default.
+// It's much bigger than any image we render, so it only stops runaway output.
+const defaultMaxOutputBytes = 64 << 20
+
+// maxStderrBytes limits how much of inkscape's error output is kept for error messages.
+const maxStderrBytes = 4 << 10
+
+var (
+	// ErrTimeout is returned when inkscape takes too long.
+	ErrTimeout = errors.New("inkscape timed out")
+	// ErrInvalidSVG is returned when inkscape can't process the SVG.
+	ErrInvalidSVG = errors.New("error processing SVG")
+	// ErrOutputTooLarge is returned when inkscape outputs more than the maximum allowed.
+	ErrOutputTooLarge = errors.New("inkscape output is too large")
+)
+
 // Client is an implementation of an Inkscape CLI wrapper.
 // It assumes a locally available inkscape command can be run.
 type Client struct {
 	// the name/path for the inkscape CLI command
 	// If left empty, the default inkscape command will be used.
 	Command string
+
+	// MaxOutputBytes limits the size of the PNG that inkscape can output.
+	// If left empty, the default of 64 MiB will be used.
+	MaxOutputBytes int
 }
 
 // IsAvailable checks whether the inkscape command is locally available.
@@ -28,7 +51,8 @@ func (c Client) IsAvailable() bool {
 }
 
 // SVGToPNG rasterizes the SVG at the specified path to PNG format.
-func (c Client) SVGToPNG(path string, width, height int) (image.Image, error) {
+// The inkscape process is killed if the context is done before it finishes.
+func (c Client) SVGToPNG(ctx context.Context, path string, width, height int) (image.Image, error) {
 	if height < 1 {
 		return nil, errors.New("invalid height")
 	}
@@ -41,31 +65,12 @@ func (c Client) SVGToPNG(path string, width, height int) (image.Image, error) {
 		return nil, err
 	}
 
-	cmd := exec.Command(c.cmd(), path, "-w", fmt.Sprint(width), "-h", fmt.Sprint(height), "--export-type=png", "--export-filename=-")
-	stdoutData := bytes.NewBuffer(nil)
-	stderrData := bytes.NewBuffer(nil)
-	cmd.Stdout = stdoutData
-	cmd.Stderr = stderrData
-	err = cmd.Start()
-	if err != nil {
-		return nil, describeError(err, stderrData.String())
-	}
-	err = cmd.Wait()
-	if err != nil {
-		return nil, describeError(err, stderrData.String())
-	}
-
-	// if we get no bytes on stdout, that means something went wrong
-	if stdoutData.Len() == 0 {
-		return nil, errors.New("error processing SVG")
-	}
-
-	img, err := png.Decode(stdoutData)
-	return img, err
+	return c.export(ctx, nil, path, "-w", fmt.Sprint(width), "-h", fmt.Sprint(height), "--export-type=png", "--export-filename=-")
 }
 
 // SVGStringToPNG rasterizes the provided SVG text to PNG format.
-func (c Client) SVGStringToPNG(svgText string, width, height int) (image.Image, error) {
+// The inkscape process is killed if the context is done before it finishes.
+func (c Client) SVGStringToPNG(ctx context.Context, svgText string, width, height int) (image.Image, error) {
 	if height < 1 {
 		return nil, errors.New("invalid height")
 	}
@@ -73,27 +78,47 @@ func (c Client) SVGStringToPNG(svgText string, width, height int) (image.Image,
 		return nil, errors.New("invalid width")
 	}
 
-	cmd := exec.Command(c.cmd(), "--pipe", "-w", fmt.Sprint(width), "-h", fmt.Sprint(height), "--export-type=png", "--export-filename=-")
-	stdoutData := bytes.NewBuffer(nil)
-	stderrData := bytes.NewBuffer(nil)
+	return c.export(ctx, bytes.NewBufferString(svgText), "--pipe", "-w", fmt.Sprint(width), "-h", fmt.Sprint(height), "--export-type=png", "--export-filename=-")
+}
+
+// export runs inkscape with the given arguments, and decodes the PNG that it writes to stdout.
+func (c Client) export(ctx context.Context, stdin io.Reader, args ...string) (image.Image, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, c.cmd(), args...)
+	// don't wait forever for any child processes that are still holding the output open
+	cmd.WaitDelay = time.Second
+	stdoutData := &limitedBuffer{max: c.maxOutputBytes(), onOverflow: cancel}
+	stderrData := &limitedBuffer{max: maxStderrBytes}
 	cmd.Stdout = stdoutData
 	cmd.Stderr = stderrData
-	cmd.Stdin = bytes.NewBufferString(svgText)
-	err := cmd.Start()
-	if err != nil {
-		return nil, describeError(err, stderrData.String())
+	cmd.Stdin = stdin
+	err := cmd.Run()
+
+	switch {
+	case stdoutData.overflowed:
+		return nil, ErrOutputTooLarge
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return nil, fmt.Errorf("%w: %w", ErrTimeout, ctx.Err())
+	case ctx.Err() != nil:
+		return nil, ctx.Err()
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSVG, describeError(err, stderrData.String()))
 	}
-	err = cmd.Wait()
 	if err != nil {
 		return nil, describeError(err, stderrData.String())
 	}
 
 	// if we get no bytes on stdout, that means something went wrong
-	if stdoutData.Len() == 0 {
-		return nil, errors.New("error processing SVG")
+	if stdoutData.buf.Len() == 0 {
+		return nil, ErrInvalidSVG
 	}
 
-	img, err := png.Decode(stdoutData)
+	img, err := png.Decode(&stdoutData.buf)
 	return img, err
 }
 
@@ -104,6 +129,40 @@ func (c Client) cmd() string {
 	return c.Command
 }
 
+func (c Client) maxOutputBytes() int {
+	if c.MaxOutputBytes < 1 {
+		return defaultMaxOutputBytes
+	}
+	return c.MaxOutputBytes
+}
+
 func describeError(err error, info string) error {
 	return fmt.Errorf("%v: %s", err, info)
 }
+
+// limitedBuffer keeps up to max bytes of output, and discards the rest.
+// The output is still read after it's too large, so that the process isn't blocked on writing it.
+// The buffer isn't embedded, because io.Copy would use its ReadFrom method and skip the limit.
+type limitedBuffer struct {
+	buf        bytes.Buffer
+	max        int
+	overflowed bool
+	// onOverflow is called the first time the output is too large
+	onOverflow func()
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if remaining := b.max - b.buf.Len(); len(p) > remaining {
+		b.buf.Write(p[:max(remaining, 0)])
+		if !b.overflowed && b.onOverflow != nil {
+			b.onOverflow()
+		}
+		b.overflowed = true
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+func (b *limitedBuffer) String() string {
+	return b.buf.String()
+}
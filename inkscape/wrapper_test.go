@@ -1,11 +1,13 @@
 package inkscape_test
 
 import (
+	"context"
 	"errors"
 	"image"
 	"io/fs"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/BattlesnakeOfficial/exporter/imagetest"
 	"github.com/BattlesnakeOfficial/exporter/inkscape"
@@ -15,7 +17,7 @@ import (
 func TestSVGToPNG(t *testing.T) {
 	// happy path
 	client := inkscape.Client{}
-	got, err := client.SVGToPNG("testdata/example.svg", 100, 100)
+	got, err := client.SVGToPNG(context.Background(), "testdata/example.svg", 100, 100)
 	require.NoError(t, err)
 	require.Equal(t, 100, got.Bounds().Max.X)
 	require.Equal(t, 100, got.Bounds().Max.Y)
@@ -23,23 +25,57 @@ func TestSVGToPNG(t *testing.T) {
 	imagetest.Equal(t, want, got)
 
 	// client should validate width/height
-	_, err = client.SVGToPNG("testdata/example.svg", 0, 100)
+	_, err = client.SVGToPNG(context.Background(), "testdata/example.svg", 0, 100)
 	require.Equal(t, errors.New("invalid width"), err)
-	_, err = client.SVGToPNG("testdata/example.svg", 100, -1)
+	_, err = client.SVGToPNG(context.Background(), "testdata/example.svg", 100, -1)
 	require.Equal(t, errors.New("invalid height"), err)
 
 	// should get an error when file doesn't exist
-	_, err = client.SVGToPNG("testdata/filedoesntexist.svg", 100, 100)
+	_, err = client.SVGToPNG(context.Background(), "testdata/filedoesntexist.svg", 100, 100)
 	require.ErrorIs(t, err, fs.ErrNotExist)
 
 	// should get an error when the command is wrong
 	client = inkscape.Client{
 		Command: "invalidinkscape",
 	}
-	_, err = client.SVGToPNG("testdata/example.svg", 100, 100)
+	_, err = client.SVGToPNG(context.Background(), "testdata/example.svg", 100, 100)
 	require.Error(t, err)
 }
 
+func TestClientLimits(t *testing.T) {
+	client := inkscape.Client{Command: "testdata/fake-inkscape.sh"}
+	got, err := client.SVGToPNG(context.Background(), "testdata/example.svg", 100, 100)
+	require.NoError(t, err)
+	imagetest.Equal(t, loadTestImage(t), got)
+
+	// errors from inkscape mean the SVG couldn't be processed
+	_, err = client.SVGStringToPNG(context.Background(), "<svg>crash</svg>", 100, 100)
+	require.ErrorIs(t, err, inkscape.ErrInvalidSVG)
+	require.Contains(t, err.Error(), "fake crash")
+
+	// the process is killed once the deadline passes
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = client.SVGStringToPNG(ctx, "<svg>hang</svg>", 100, 100)
+	require.ErrorIs(t, err, inkscape.ErrTimeout)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Less(t, time.Since(start), 5*time.Second)
+
+	// or as soon as it's cancelled
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	_, err = client.SVGStringToPNG(ctx, "<svg></svg>", 100, 100)
+	require.ErrorIs(t, err, context.Canceled)
+
+	// and as soon as it outputs too much
+	client.MaxOutputBytes = 1 << 20
+	start = time.Now()
+	_, err = client.SVGStringToPNG(context.Background(), "<svg>huge</svg>", 100, 100)
+	require.ErrorIs(t, err, inkscape.ErrOutputTooLarge)
+	require.Less(t, time.Since(start), 5*time.Second)
+}
+
 func TestIsAvailable(t *testing.T) {
 	t.Log("Default command")
 	client := inkscape.Client{}
@@ -1,34 +1,48 @@
 package media
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"image"
 	"image/color"
-	"io/ioutil"
-	"net/http"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/patrickmn/go-cache"
-	log "github.com/sirupsen/logrus"
 )
 
 var ErrNotFound = errors.New("resource not found")
+
+// ErrPartialList is returned with the customizations that could be listed, when some media sources couldn't be listed.
+var ErrPartialList = errors.New("some media sources couldn't be listed")
 var mediaServerURL = "https://media.battlesnake.com"
 
 // Create an in-mem media cache (6 hours, evicting every 10 mins)
 var mediaCache = cache.New(6*60*time.Minute, 10*time.Minute)
 
-func getCachedMediaResource(path string) (string, error) {
-	var resource string
+// mediaFailureExpiration is how long failures to get a resource are cached for,
+// so that a missing customization or an unavailable source isn't tried again for every frame.
+const mediaFailureExpiration = time.Minute
+
+// failedResource is cached in place of a resource that couldn't be got.
+type failedResource struct {
+	err error
+}
 
+func getCachedMediaResource(path string) (string, error) {
 	obj, found := mediaCache.Get(path)
 	if found {
+		if failed, ok := obj.(failedResource); ok {
+			return "", failed.err
+		}
 		return obj.(string), nil
 	}
 
 	resource, err := getMediaResource(path)
 	if err != nil {
+		mediaCache.Set(path, failedResource{err}, mediaFailureExpiration)
 		return "", err
 	}
 
@@ -37,27 +51,16 @@ func getCachedMediaResource(path string) (string, error) {
 }
 
 func getMediaResource(path string) (string, error) {
-	log.WithField("path", path).Info("fetching media resource")
-	url := fmt.Sprintf("%s/%s", mediaServerURL, path)
-
-	client := http.Client{}
-	response, err := client.Get(url)
+	resource, err := mediaSource.Get(path)
 	if err != nil {
 		return "", err
 	}
-	if response.StatusCode == http.StatusNotFound || response.StatusCode == http.StatusForbidden {
-		return "", ErrNotFound
-	}
-	if response.StatusCode != 200 {
-		return "", fmt.Errorf("got non 200 from media '%s': %d", path, response.StatusCode)
-	}
 
-	body, err := ioutil.ReadAll(response.Body)
-	if err != nil {
-		return "", err
+	// SVGs come from third parties, so anything unsafe is removed before they're served or rasterized
+	if strings.HasSuffix(path, ".svg") {
+		return SanitizeSVG(resource)
 	}
-
-	return string(body), nil
+	return resource, nil
 }
 
 func GetHeadSVG(id string) (string, error) {
@@ -68,12 +71,69 @@ func GetTailSVG(id string) (string, error) {
 	return getCachedMediaResource(tailSVGPath(id))
 }
 
-func GetHeadPNG(id string, w, h int, c color.Color) (image.Image, error) {
-	return getSnakeSVGImage(headSVGPath(id), fallbackHead, w, h, c)
+// GetHeadPNG rasterizes the head in the colour, stopping if the context is done first.
+func GetHeadPNG(ctx context.Context, id string, w, h int, c color.Color) (image.Image, error) {
+	return getSnakeSVGImage(ctx, headSVGPath(id), fallbackHead, w, h, c)
 }
 
-func GetTailPNG(id string, w, h int, c color.Color) (image.Image, error) {
-	return getSnakeSVGImage(tailSVGPath(id), fallbackTail, w, h, c)
+// GetTailPNG rasterizes the tail in the colour, stopping if the context is done first.
+func GetTailPNG(ctx context.Context, id string, w, h int, c color.Color) (image.Image, error) {
+	return getSnakeSVGImage(ctx, tailSVGPath(id), fallbackTail, w, h, c)
+}
+
+// CustomizationList is the heads or tails that the media source has.
+type CustomizationList struct {
+	Names []string
+	// Complete is false when the media source, or some of its sources, couldn't be listed,
+	// so there may be customizations that aren't in Names.
+	Complete bool
+}
+
+// ListHeads lists the heads that the media source has, as far as it can be listed.
+// The default head is always included.
+func ListHeads() (CustomizationList, error) {
+	return listCustomizations("snakes/heads")
+}
+
+// ListTails lists the tails that the media source has, as far as it can be listed.
+// The default tail is always included.
+func ListTails() (CustomizationList, error) {
+	return listCustomizations("snakes/tails")
+}
+
+func listCustomizations(dir string) (CustomizationList, error) {
+	cacheKey := "list:" + dir
+	if obj, found := mediaCache.Get(cacheKey); found {
+		if failed, ok := obj.(failedResource); ok {
+			return CustomizationList{}, failed.err
+		}
+		return obj.(CustomizationList), nil
+	}
+
+	list := CustomizationList{Names: []string{"default"}}
+	lister, ok := mediaSource.(Lister)
+	if ok {
+		listed, err := lister.List(dir)
+		if err != nil && !errors.Is(err, ErrNotFound) && !errors.Is(err, ErrPartialList) {
+			mediaCache.Set(cacheKey, failedResource{err}, mediaFailureExpiration)
+			return CustomizationList{}, err
+		}
+		list.Complete = err == nil
+		for _, name := range listed {
+			if name != "default" {
+				list.Names = append(list.Names, name)
+			}
+		}
+	}
+	sort.Strings(list.Names)
+
+	// incomplete lists are tried again sooner, in case the sources that failed can be listed by then
+	expiration := cache.DefaultExpiration
+	if !list.Complete {
+		expiration = mediaFailureExpiration
+	}
+	mediaCache.Set(cacheKey, list, expiration)
+	return list, nil
 }
 
 func headSVGPath(id string) string {
@@ -0,0 +1,180 @@
+package media
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+)
+
+var reCustomizationName = regexp.MustCompile(`^[A-Za-z0-9-]{1,32}$`)
+
+// ManifestFile is the name of the manifest in an asset bundle.
+const ManifestFile = "manifest.json"
+
+// Manifest records what's in an asset bundle, and where it came from.
+type Manifest struct {
+	// Server is the media server that the bundle was synced from.
+	Server string `json:"server"`
+	// Synced is when the bundle was last synced.
+	Synced time.Time `json:"synced"`
+	// Files maps each media path in the bundle to the SHA-256 hash of its contents.
+	Files map[string]string `json:"files"`
+}
+
+// ReadManifest reads the manifest of the asset bundle in the given directory.
+// A bundle without a manifest has an empty one.
+func ReadManifest(dir string) (*Manifest, error) {
+	m := &Manifest{Files: map[string]string{}}
+	data, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("invalid asset bundle manifest: %w", err)
+	}
+	if m.Files == nil {
+		m.Files = map[string]string{}
+	}
+	return m, nil
+}
+
+func (m *Manifest) write(dir string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(dir, ManifestFile), append(data, '\n'))
+}
+
+// SyncResult lists the media paths that were changed by a sync.
+type SyncResult struct {
+	Added     []string
+	Updated   []string
+	Unchanged []string
+	// Removed are the paths that no longer exist on the media server, so were removed from the bundle.
+	Removed []string
+	// Failed are the paths that couldn't be fetched, which are left as they were.
+	Failed map[string]error
+}
+
+// SyncBundle mirrors customizations from a media server into the asset bundle in the given directory, for later offline use.
+// Everything already in the bundle's manifest is synced again, along with the given heads and tails, and the default head and tail.
+func SyncBundle(server ServerSource, dir string, heads, tails []string) (SyncResult, error) {
+	result := SyncResult{Failed: map[string]error{}}
+
+	paths := map[string]bool{headSVGPath("default"): true, tailSVGPath("default"): true}
+	for _, name := range append(append([]string{}, heads...), tails...) {
+		if !reCustomizationName.MatchString(name) {
+			return result, fmt.Errorf("invalid customization name: %q", name)
+		}
+	}
+	for _, name := range heads {
+		paths[headSVGPath(name)] = true
+	}
+	for _, name := range tails {
+		paths[tailSVGPath(name)] = true
+	}
+
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return result, err
+	}
+	manifest, err := ReadManifest(dir)
+	if err != nil {
+		return result, err
+	}
+	for p := range manifest.Files {
+		// the paths are joined to the bundle directory, so they can't be allowed to escape it
+		if !fs.ValidPath(p) {
+			return result, fmt.Errorf("invalid path in asset bundle manifest: %q", p)
+		}
+		paths[p] = true
+	}
+
+	sorted := make([]string, 0, len(paths))
+	for p := range paths {
+		sorted = append(sorted, p)
+	}
+	sort.Strings(sorted)
+
+	for _, p := range sorted {
+		oldHash, existed := manifest.Files[p]
+		fullPath := filepath.Join(dir, filepath.FromSlash(p))
+
+		resource, err := server.Get(p)
+		if errors.Is(err, ErrNotFound) {
+			if existed {
+				delete(manifest.Files, p)
+				if err := os.Remove(fullPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+					return result, err
+				}
+				result.Removed = append(result.Removed, p)
+			} else {
+				result.Failed[p] = err
+			}
+			continue
+		}
+		if err != nil {
+			result.Failed[p] = err
+			continue
+		}
+
+		sum := sha256.Sum256([]byte(resource))
+		hash := hex.EncodeToString(sum[:])
+		if existed && hash == oldHash {
+			if _, err := os.Stat(fullPath); err == nil {
+				result.Unchanged = append(result.Unchanged, p)
+				continue
+			}
+		}
+
+		if err := os.MkdirAll(filepath.Dir(fullPath), os.ModePerm); err != nil {
+			return result, err
+		}
+		if err := writeFileAtomic(fullPath, []byte(resource)); err != nil {
+			return result, err
+		}
+		manifest.Files[p] = hash
+		if existed {
+			result.Updated = append(result.Updated, p)
+		} else {
+			result.Added = append(result.Added, p)
+		}
+	}
+
+	manifest.Server = server.baseURL()
+	manifest.Synced = time.Now().UTC()
+	return result, manifest.write(dir)
+}
+
+// writeFileAtomic writes the file via a temporary file, so that it's never left half written.
+func writeFileAtomic(path string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), ".sync-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(f.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
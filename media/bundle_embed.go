@@ -0,0 +1,22 @@
+//go:build mediabundle
+
+package media
+
+import (
+	"embed"
+	"io/fs"
+)
+
+// bundleFiles is the asset bundle in the bundle directory, which is embedded when building with the mediabundle tag.
+// Use "exporter media sync -dir media/bundle" to fill it before building.
+//
+//go:embed bundle
+var bundleFiles embed.FS
+
+func embeddedBundle() (fs.FS, bool) {
+	bundle, err := fs.Sub(bundleFiles, "bundle")
+	if err != nil {
+		panic(err) // the directory is always embedded, so this can't happen
+	}
+	return bundle, true
+}
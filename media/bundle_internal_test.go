@@ -0,0 +1,325 @@
+package media
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBundleSource(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "snakes/heads"), os.ModePerm))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "snakes/heads/default.svg"), []byte(headSVG), 0o600))
+
+	bundle, err := NewBundleSource(dir)
+	require.NoError(t, err)
+
+	svg, err := bundle.Get("snakes/heads/default.svg")
+	require.NoError(t, err)
+	require.Equal(t, headSVG, svg)
+
+	_, err = bundle.Get("snakes/tails/default.svg")
+	require.ErrorIs(t, err, ErrNotFound)
+	_, err = bundle.Get("../../etc/passwd")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	_, err = NewBundleSource(filepath.Join(dir, "missing"))
+	require.Error(t, err)
+	_, err = NewBundleSource(filepath.Join(dir, "snakes/heads/default.svg"))
+	require.Error(t, err)
+}
+
+func TestSetSource(t *testing.T) {
+	defer SetSource(mediaSource)
+
+	SetSource(BundleSource{FS: fstest.MapFS{
+		"snakes/heads/bundled.svg": {Data: []byte(headSVG)},
+	}})
+
+	svg, err := GetHeadSVG("bundled")
+	require.NoError(t, err)
+	require.Equal(t, headSVG, svg)
+
+	// the bundle is the source of truth, so the media server isn't used
+	_, err = GetTailSVG("default")
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+// countingSource counts how many resources are got from the source it wraps.
+type countingSource struct {
+	Source
+	gets int
+}
+
+func (s *countingSource) Get(path string) (string, error) {
+	s.gets++
+	return s.Source.Get(path)
+}
+
+func TestGetCachedMediaResource_Failures(t *testing.T) {
+	defer SetSource(mediaSource)
+	source := &countingSource{Source: BundleSource{FS: fstest.MapFS{}}}
+	SetSource(source)
+
+	// failures are cached briefly, so that they aren't tried again for every frame
+	for i := 0; i < 3; i++ {
+		_, err := GetHeadSVG("missing")
+		require.ErrorIs(t, err, ErrNotFound)
+	}
+	require.Equal(t, 1, source.gets)
+
+	_, expiration, found := mediaCache.GetWithExpiration(headSVGPath("missing"))
+	require.True(t, found)
+	require.WithinDuration(t, time.Now().Add(mediaFailureExpiration), expiration, 5*time.Second)
+}
+
+func TestSyncBundle(t *testing.T) {
+	resources := map[string]string{
+		"/snakes/heads/default.svg": headSVG,
+		"/snakes/tails/default.svg": tailSVG,
+		"/snakes/heads/beluga.svg":  headSVG,
+		"/snakes/tails/bolt.svg":    tailSVG,
+	}
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "broken") {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		resource, ok := resources[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, resource)
+	}))
+	defer svr.Close()
+	server := ServerSource{URL: svr.URL}
+	dir := t.TempDir()
+
+	result, err := SyncBundle(server, dir, []string{"beluga", "missing"}, []string{"bolt", "broken"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"snakes/heads/beluga.svg", "snakes/heads/default.svg", "snakes/tails/bolt.svg", "snakes/tails/default.svg"}, result.Added)
+	assert.ErrorIs(t, result.Failed["snakes/heads/missing.svg"], ErrNotFound)
+	assert.Error(t, result.Failed["snakes/tails/broken.svg"])
+	assert.Len(t, result.Failed, 2)
+
+	data, err := os.ReadFile(filepath.Join(dir, "snakes/tails/bolt.svg"))
+	require.NoError(t, err)
+	require.Equal(t, tailSVG, string(data))
+
+	manifest, err := ReadManifest(dir)
+	require.NoError(t, err)
+	assert.Equal(t, svr.URL, manifest.Server)
+	assert.Len(t, manifest.Files, 4)
+	assert.False(t, manifest.Synced.IsZero())
+
+	// syncing again picks up changes to everything already in the manifest
+	resources["/snakes/heads/beluga.svg"] = tailSVG
+	delete(resources, "/snakes/tails/bolt.svg")
+	result, err = SyncBundle(server, dir, nil, nil)
+	require.NoError(t, err)
+	assert.Empty(t, result.Added)
+	assert.Equal(t, []string{"snakes/heads/beluga.svg"}, result.Updated)
+	assert.Equal(t, []string{"snakes/tails/bolt.svg"}, result.Removed)
+	assert.Len(t, result.Unchanged, 2)
+	assert.NoFileExists(t, filepath.Join(dir, "snakes/tails/bolt.svg"))
+
+	// the synced bundle works offline
+	bundle, err := NewBundleSource(dir)
+	require.NoError(t, err)
+	svg, err := bundle.Get("snakes/heads/beluga.svg")
+	require.NoError(t, err)
+	require.Equal(t, tailSVG, svg)
+
+	_, err = SyncBundle(server, dir, []string{"../../escape"}, nil)
+	require.Error(t, err)
+}
+
+func TestSources(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer svr.Close()
+
+	sources := Sources{
+		{Namespace: "private", Source: BundleSource{FS: fstest.MapFS{
+			"snakes/heads/secret.svg":  {Data: []byte(headSVG)},
+			"snakes/heads/default.svg": {Data: []byte(tailSVG)},
+		}}},
+		{Source: ServerSource{URL: svr.URL}},
+		{Namespace: "public", Source: BundleSource{FS: fstest.MapFS{
+			"snakes/heads/default.svg": {Data: []byte(headSVG)},
+			"snakes/heads/beluga.svg":  {Data: []byte(headSVG)},
+		}}},
+	}
+
+	// sources are tried in order, and ones that fail are skipped
+	svg, err := sources.Get("snakes/heads/default.svg")
+	require.NoError(t, err)
+	require.Equal(t, tailSVG, svg)
+	svg, err = sources.Get("snakes/heads/beluga.svg")
+	require.NoError(t, err)
+	require.Equal(t, headSVG, svg)
+	svg, err = sources.Get("snakes/heads/secret.svg")
+	require.NoError(t, err)
+	require.Equal(t, headSVG, svg)
+
+	// a failed source's error is returned when no source has the resource
+	_, err = sources.Get("snakes/heads/missing.svg")
+	require.Error(t, err)
+	require.NotErrorIs(t, err, ErrNotFound)
+
+	// namespaced IDs only use their own source
+	svg, err = sources.Get("snakes/heads/public:default.svg")
+	require.NoError(t, err)
+	require.Equal(t, headSVG, svg)
+	_, err = sources.Get("snakes/heads/public:secret.svg")
+	require.ErrorIs(t, err, ErrNotFound)
+	_, err = sources.Get("snakes/heads/other:default.svg")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	defer SetSource(mediaSource)
+	SetSource(sources)
+	svg, err = GetHeadSVG("private:default")
+	require.NoError(t, err)
+	require.Equal(t, tailSVG, svg)
+}
+
+func TestParseSources(t *testing.T) {
+	dir := t.TempDir()
+
+	sources, err := ParseSources(fmt.Sprintf(" private=https://media.example.com/ , %s,https://media.battlesnake.com", dir))
+	require.NoError(t, err)
+	require.Len(t, sources, 3)
+	assert.Equal(t, NamedSource{Namespace: "private", Source: ServerSource{URL: "https://media.example.com"}}, sources[0])
+	assert.Equal(t, "", sources[1].Namespace)
+	assert.IsType(t, BundleSource{}, sources[1].Source)
+	assert.Equal(t, NamedSource{Source: ServerSource{URL: "https://media.battlesnake.com"}}, sources[2])
+
+	// a bundle directory can be namespaced, and contain "="
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "a=b"), os.ModePerm))
+	sources, err = ParseSources(fmt.Sprintf("local=%s/a=b", dir))
+	require.NoError(t, err)
+	require.Len(t, sources, 1)
+	assert.Equal(t, "local", sources[0].Namespace)
+
+	for _, spec := range []string{
+		"",
+		" , ",
+		filepath.Join(dir, "missing"),
+		"a=https://media.example.com,a=https://media.battlesnake.com",
+	} {
+		_, err := ParseSources(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestListCustomizations(t *testing.T) {
+	bundle := BundleSource{FS: fstest.MapFS{
+		"snakes/heads/beluga.svg":     {Data: []byte(headSVG)},
+		"snakes/heads/default.svg":    {Data: []byte(headSVG)},
+		"snakes/heads/notes.txt":      {Data: []byte("not a head")},
+		"snakes/heads/nested/bad.svg": {Data: []byte(headSVG)},
+		"snakes/tails/bolt.svg":       {Data: []byte(tailSVG)},
+	}}
+	names, err := bundle.List("snakes/heads")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"beluga", "default"}, names)
+	names, err = bundle.List("snakes/bodies")
+	require.NoError(t, err)
+	assert.Empty(t, names)
+
+	manifest := `{"files": {"snakes/heads/secret.svg": "", "snakes/tails/secret.svg": "", "snakes/heads/../escape.svg": ""}}`
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/"+ManifestFile {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, manifest)
+	}))
+	defer svr.Close()
+	server := ServerSource{URL: svr.URL}
+	names, err = server.List("snakes/heads")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"secret"}, names)
+
+	// a media server without a manifest can't be listed
+	_, err = ServerSource{URL: svr.URL + "/other"}.List("snakes/heads")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	sources := Sources{
+		{Source: bundle},
+		{Namespace: "private", Source: server},
+		{Source: ServerSource{URL: svr.URL + "/other"}},
+		{Source: BundleSource{FS: fstest.MapFS{"snakes/heads/beluga.svg": {Data: []byte(headSVG)}}}},
+	}
+	// the sources that can be listed are still listed, but the list is partial
+	names, err = sources.List("snakes/heads")
+	require.ErrorIs(t, err, ErrPartialList)
+	assert.Equal(t, []string{"beluga", "default", "private:secret"}, names)
+	names, err = sources[:2].List("snakes/heads")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"beluga", "default", "private:secret"}, names)
+
+	defer SetSource(mediaSource)
+	SetSource(sources[:2])
+	heads, err := ListHeads()
+	require.NoError(t, err)
+	assert.Equal(t, CustomizationList{Names: []string{"beluga", "default", "private:secret"}, Complete: true}, heads)
+	tails, err := ListTails()
+	require.NoError(t, err)
+	assert.Equal(t, CustomizationList{Names: []string{"bolt", "default", "private:secret"}, Complete: true}, tails)
+
+	SetSource(sources)
+	heads, err = ListHeads()
+	require.NoError(t, err)
+	assert.Equal(t, CustomizationList{Names: []string{"beluga", "default", "private:secret"}}, heads)
+
+	// sources that can't be listed still have the default head and tail
+	SetSource(ServerSource{URL: svr.URL + "/other"})
+	heads, err = ListHeads()
+	require.NoError(t, err)
+	assert.Equal(t, CustomizationList{Names: []string{"default"}}, heads)
+	SetSource(&countingSource{Source: ServerSource{URL: svr.URL}})
+	heads, err = ListHeads()
+	require.NoError(t, err)
+	assert.Equal(t, CustomizationList{Names: []string{"default"}}, heads)
+}
+
+// failingLister is a source that can never be listed.
+type failingLister struct {
+	Source
+	lists int
+}
+
+func (s *failingLister) List(dir string) ([]string, error) {
+	s.lists++
+	return nil, errors.New("listing failed")
+}
+
+func TestListCustomizations_Failures(t *testing.T) {
+	defer SetSource(mediaSource)
+	source := &failingLister{}
+	SetSource(source)
+
+	// failures are cached briefly, so that the source isn't listed again for every request
+	for i := 0; i < 3; i++ {
+		_, err := ListHeads()
+		require.EqualError(t, err, "listing failed")
+	}
+	require.Equal(t, 1, source.lists)
+
+	_, expiration, found := mediaCache.GetWithExpiration("list:snakes/heads")
+	require.True(t, found)
+	require.WithinDuration(t, time.Now().Add(mediaFailureExpiration), expiration, 5*time.Second)
+}
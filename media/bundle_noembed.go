@@ -0,0 +1,10 @@
+//go:build !mediabundle
+
+package media
+
+import "io/fs"
+
+// embeddedBundle reports that there's no embedded asset bundle, because the exporter wasn't built with the mediabundle tag.
+func embeddedBundle() (fs.FS, bool) {
+	return nil, false
+}
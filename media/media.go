@@ -2,6 +2,7 @@ package media
 
 import (
 	"bytes"
+	"context"
 	"encoding/xml"
 	"errors"
 	"fmt"
@@ -13,10 +14,15 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/BattlesnakeOfficial/exporter/inkscape"
+	"github.com/BattlesnakeOfficial/exporter/svg"
 	"github.com/disintegration/imaging"
 	"github.com/patrickmn/go-cache"
 	log "github.com/sirupsen/logrus"
@@ -25,22 +31,215 @@ import (
 const (
 	fallbackHead = "heads/default.png" // relative to base path
 	fallbackTail = "tails/default.png" // relative to base path
+	watermark    = "watermark.png"     // relative to base path
+	brandingDir  = "branding"          // relative to base path
 )
 
+// DefaultBranding is the name of the built-in branding asset, which is the Battlesnake watermark.
+const DefaultBranding = "default"
+
+var reBrandingName = regexp.MustCompile(`^[a-z0-9-]{1,32}$`)
+
 // imageCache is a cache that contains image.Image values
 var imageCache = cache.New(time.Hour, 10*time.Minute)
 
+// Rasterizer converts SVG images to raster images.
+// It's implemented by both the inkscape CLI wrapper and the in-process svg package.
+type Rasterizer interface {
+	// IsAvailable checks whether the rasterizer can be used.
+	IsAvailable() bool
+	// SVGToPNG rasterizes the SVG at the specified path, stopping if the context is done first.
+	SVGToPNG(ctx context.Context, path string, width, height int) (image.Image, error)
+	// SVGStringToPNG rasterizes the provided SVG text, stopping if the context is done first.
+	SVGStringToPNG(ctx context.Context, svgText string, width, height int) (image.Image, error)
+}
+
+var (
+	// ErrRasterizeTimeout is returned when an SVG takes too long to rasterize.
+	ErrRasterizeTimeout = errors.New("timed out rasterizing SVG")
+	// ErrInvalidSVG is returned when an SVG can't be sanitized or rasterized because of a problem with the SVG itself.
+	ErrInvalidSVG = errors.New("unable to rasterize SVG")
+)
+
+// rasterizeTimeout limits how long a single SVG can take to rasterize.
+var rasterizeTimeout = 30 * time.Second
+
+// rasterizeSlots limits how many SVGs can be rasterized at the same time, across all requests.
+var rasterizeSlots = make(chan struct{}, runtime.NumCPU())
+
+// The names of the available rasterizers, for SetRasterizer.
+const (
+	// RasterizerAuto uses inkscape when it's installed, and the in-process rasterizer otherwise.
+	RasterizerAuto     = "auto"
+	RasterizerInkscape = "inkscape"
+	// RasterizerInkscapeShell keeps a pool of inkscape processes running, instead of starting one per image.
+	RasterizerInkscapeShell = "inkscape-shell"
+	RasterizerGo            = "go"
+)
+
 var inkscapeClient = &inkscape.Client{}
 
+// inkscapeShellWorkers is how many inkscape processes are kept running by the inkscape-shell rasterizer.
+var inkscapeShellWorkers = 2
+
+var rasterizer = autoRasterizer()
+
 var baseDir = "media/assets"
+
+// downloadsVersion is changed whenever downloaded SVGs are saved differently, such as when they started being sanitized,
+// so that SVGs saved by older versions are downloaded again rather than rasterized.
+const downloadsVersion = "v2"
+
 var svgMgr = &svgManager{
-	baseDir:  filepath.Join(baseDir, "downloads"),
-	inkscape: inkscapeClient,
+	baseDir:    filepath.Join(baseDir, "downloads", downloadsVersion),
+	rasterizer: rasterizer,
+}
+
+// SetRasterizer chooses how SVG images are rasterized, by name.
+// It should be called before any images are loaded.
+func SetRasterizer(name string) error {
+	var r Rasterizer
+	switch name {
+	case RasterizerAuto:
+		r = autoRasterizer()
+	case RasterizerInkscape:
+		r = inkscapeClient
+	case RasterizerInkscapeShell:
+		r = inkscape.NewPool(*inkscapeClient, inkscapeShellWorkers)
+	case RasterizerGo:
+		r = svg.Rasterizer{}
+	default:
+		return fmt.Errorf("unknown SVG rasterizer: %q", name)
+	}
+
+	// stop any processes the previous rasterizer was running
+	if c, ok := rasterizer.(io.Closer); ok {
+		_ = c.Close()
+	}
+	rasterizer = r
+	svgMgr.rasterizer = r
+	return nil
+}
+
+// SetDownloadDir changes where SVGs are saved before they're rasterized, such as to a temporary directory in tests.
+// It should be called before any images are loaded.
+func SetDownloadDir(dir string) {
+	svgMgr.baseDir = filepath.Join(dir, downloadsVersion)
+}
+
+// SetRasterizeLimits sets how long a single SVG can take to rasterize, and how many can be rasterized at the same time.
+// It should be called before any images are loaded.
+func SetRasterizeLimits(timeout time.Duration, concurrency int) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	rasterizeTimeout = timeout
+	rasterizeSlots = make(chan struct{}, concurrency)
+}
+
+// rasterize runs a rasterizer call within the time and concurrency limits.
+// Errors caused by the time limit or the SVG are converted to ErrRasterizeTimeout or ErrInvalidSVG,
+// so they can be told apart from other errors regardless of which rasterizer is used.
+func rasterize(ctx context.Context, rasterizeFunc func(ctx context.Context) (image.Image, error)) (image.Image, error) {
+	ctx, cancel := context.WithTimeout(ctx, rasterizeTimeout)
+	defer cancel()
+
+	select {
+	case rasterizeSlots <- struct{}{}:
+		defer func() { <-rasterizeSlots }()
+	case <-ctx.Done():
+		return nil, rasterizeError(ctx.Err())
+	}
+
+	img, err := rasterizeFunc(ctx)
+	return img, rasterizeError(err)
+}
+
+func rasterizeError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, inkscape.ErrTimeout):
+		return fmt.Errorf("%w: %w", ErrRasterizeTimeout, err)
+	case errors.Is(err, inkscape.ErrInvalidSVG), errors.Is(err, inkscape.ErrOutputTooLarge), errors.Is(err, svg.ErrInvalidSVG):
+		return fmt.Errorf("%w: %w", ErrInvalidSVG, err)
+	}
+	return err
+}
+
+// SetInkscapeShellWorkers sets how many inkscape processes the inkscape-shell rasterizer keeps running.
+// It should be called before SetRasterizer.
+func SetInkscapeShellWorkers(n int) {
+	if n < 1 {
+		n = 1
+	}
+	inkscapeShellWorkers = n
+}
+
+// autoRasterizer prefers inkscape, because it supports all of SVG.
+func autoRasterizer() Rasterizer {
+	if inkscapeClient.IsAvailable() {
+		return inkscapeClient
+	}
+	return svg.Rasterizer{}
 }
 
 // GetWatermarkPNG gets the watermark asset, scaled to the requested width/height
 func GetWatermarkPNG(w, h int) (image.Image, error) {
-	return loadLocalImageAsset("watermark.png", w, h)
+	return loadLocalImageAsset(watermark, w, h)
+}
+
+// brandingAssets are the names of the available branding assets, which are only read from the branding asset directory once.
+var brandingAssets []string
+var brandingAssetsOnce sync.Once
+
+// BrandingAssets lists the names of the available branding assets.
+// These are the built-in default, plus any PNG files in the branding asset directory when it's first read.
+// Only these names are allowed to be loaded with GetBrandingPNG.
+func BrandingAssets() []string {
+	return slices.Clone(loadBrandingAssets())
+}
+
+func loadBrandingAssets() []string {
+	brandingAssetsOnce.Do(func() {
+		brandingAssets = readBrandingAssets()
+	})
+	return brandingAssets
+}
+
+func readBrandingAssets() []string {
+	names := []string{DefaultBranding}
+
+	entries, err := os.ReadDir(filepath.Join(baseDir, brandingDir))
+	if err != nil {
+		// it's fine for there to be no custom branding
+		return names
+	}
+
+	for _, e := range entries {
+		name := strings.TrimSuffix(e.Name(), ".png")
+		if e.IsDir() || name == e.Name() || name == DefaultBranding || !reBrandingName.MatchString(name) {
+			continue
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// IsBrandingAsset checks whether the given name is one of the available branding assets.
+func IsBrandingAsset(name string) bool {
+	return slices.Contains(loadBrandingAssets(), name)
+}
+
+// GetBrandingPNG gets the named branding asset, scaled to the requested width/height.
+func GetBrandingPNG(name string, w, h int) (image.Image, error) {
+	if name == DefaultBranding {
+		return GetWatermarkPNG(w, h)
+	}
+	if !IsBrandingAsset(name) {
+		return nil, ErrNotFound
+	}
+	return loadLocalImageAsset(path.Join(brandingDir, name+".png"), w, h)
 }
 
 func loadImageFile(path string) (image.Image, error) {
@@ -82,9 +281,9 @@ func loadLocalImageAsset(mediaPath string, w, h int) (image.Image, error) {
 	return img, nil
 }
 
-func getSnakeSVGImage(path, fallbackPath string, w, h int, c color.Color) (image.Image, error) {
+func getSnakeSVGImage(ctx context.Context, path, fallbackPath string, w, h int, c color.Color) (image.Image, error) {
 	// first we try to load from the media server SVG's
-	img, err := svgMgr.loadSnakeSVGImage(path, w, h, c)
+	img, err := svgMgr.loadSnakeSVGImage(ctx, path, w, h, c)
 	if err != nil {
 		// log at info, because this could error just for people specifying snake types that don't exist
 		log.WithFields(log.Fields{
@@ -107,13 +306,16 @@ func getSnakeSVGImage(path, fallbackPath string, w, h int, c color.Color) (image
 	return img, err
 }
 
-func ConvertSVGStringToPNG(svg string, w, h int) (image.Image, error) {
-	// make sure inkscape is available, otherwise we can't create an image from an SVG
-	if !inkscapeClient.IsAvailable() {
-		return nil, errors.New("inkscape is not available - unable to convert SVG")
+// ConvertSVGStringToPNG rasterizes the SVG text, stopping if the context is done first.
+func ConvertSVGStringToPNG(ctx context.Context, svgText string, w, h int) (image.Image, error) {
+	// make sure the rasterizer is available, otherwise we can't create an image from an SVG
+	if !rasterizer.IsAvailable() {
+		return nil, errors.New("SVG rasterizer is not available - unable to convert SVG")
 	}
 
-	img, err := inkscapeClient.SVGStringToPNG(svg, w, h)
+	img, err := rasterize(ctx, func(ctx context.Context) (image.Image, error) {
+		return rasterizer.SVGStringToPNG(ctx, svgText, w, h)
+	})
 	if err != nil {
 		log.WithError(err).Info("unable to rasterize SVG")
 		return nil, err
@@ -122,20 +324,20 @@ func ConvertSVGStringToPNG(svg string, w, h int) (image.Image, error) {
 }
 
 type svgManager struct {
-	baseDir  string
-	inkscape *inkscape.Client
+	baseDir    string
+	rasterizer Rasterizer
 }
 
-func (sm svgManager) loadSnakeSVGImage(mediaPath string, w, h int, c color.Color) (image.Image, error) {
+func (sm svgManager) loadSnakeSVGImage(ctx context.Context, mediaPath string, w, h int, c color.Color) (image.Image, error) {
 	key := imageCacheKey(mediaPath, w, h, c)
 	cachedImage, ok := imageCache.Get(key)
 	if ok {
 		return cachedImage.(image.Image), nil
 	}
 
-	// make sure inkscape is available, otherwise we can't create an image from an SVG
-	if !sm.inkscape.IsAvailable() {
-		return nil, errors.New("inkscape is not available - unable to load SVG")
+	// make sure the rasterizer is available, otherwise we can't create an image from an SVG
+	if !sm.rasterizer.IsAvailable() {
+		return nil, errors.New("SVG rasterizer is not available - unable to load SVG")
 	}
 
 	mediaPath, err := sm.ensureDownloaded(mediaPath, c)
@@ -146,7 +348,9 @@ func (sm svgManager) loadSnakeSVGImage(mediaPath string, w, h int, c color.Color
 	path := sm.getFullPath(mediaPath)
 
 	// rasterize the SVG
-	img, err := sm.inkscape.SVGToPNG(path, w, h)
+	img, err := rasterize(ctx, func(ctx context.Context) (image.Image, error) {
+		return sm.rasterizer.SVGToPNG(ctx, path, w, h)
+	})
 	if err != nil {
 		log.WithField("path", path).WithError(err).Info("unable to rasterize SVG")
 		return nil, err
@@ -1,6 +1,7 @@
 package media
 
 import (
+	"context"
 	"fmt"
 	"image"
 	"image/color"
@@ -8,12 +9,16 @@ import (
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/BattlesnakeOfficial/exporter/imagetest"
 	"github.com/BattlesnakeOfficial/exporter/inkscape"
 	"github.com/BattlesnakeOfficial/exporter/parse"
+	"github.com/BattlesnakeOfficial/exporter/svg"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -35,6 +40,8 @@ func TestMain(m *testing.M) {
 		}
 	}))
 	mediaServerURL = svr.URL
+	// the tests use the mock media server, even when built with an embedded asset bundle
+	mediaSource = ServerSource{}
 	defer svr.Close()
 
 	// need to override these directories because the paths aren't right when run by unit tests
@@ -60,13 +67,13 @@ func TestGetTailSVG(t *testing.T) {
 }
 
 func TestGetTailPNG(t *testing.T) {
-	img, err := GetTailPNG("default", 20, 20, parse.HexColor("#cc00aa"))
+	img, err := GetTailPNG(context.Background(), "default", 20, 20, parse.HexColor("#cc00aa"))
 	require.NoError(t, err)
 	assertImg(t, img, 20, 20)
 }
 
 func TestGetHeadPNG(t *testing.T) {
-	img, err := GetHeadPNG("default", 20, 20, parse.HexColor("#cc00aa"))
+	img, err := GetHeadPNG(context.Background(), "default", 20, 20, parse.HexColor("#cc00aa"))
 	require.NoError(t, err)
 	assertImg(t, img, 20, 20)
 }
@@ -75,8 +82,8 @@ func TestSVGManager(t *testing.T) {
 	baseDir, err := os.MkdirTemp("", "")
 	require.NoError(t, err)
 	mgr := svgManager{
-		baseDir:  baseDir,
-		inkscape: &inkscape.Client{},
+		baseDir:    baseDir,
+		rasterizer: &inkscape.Client{},
 	}
 
 	require.Equal(t, mgr.getFullPath("things/foo.svg"), filepath.Join(baseDir, "things/foo.svg"))
@@ -93,35 +100,139 @@ func TestSVGManager(t *testing.T) {
 	require.NoError(t, mgr.ensureSubdirExists("some/subdir"))
 	require.DirExists(t, mgr.getFullPath("some/subdir"))
 
-	img, err := mgr.loadSnakeSVGImage(headSVGPath("default"), 20, 20, parse.HexColor("#cc00aa"))
+	img, err := mgr.loadSnakeSVGImage(context.Background(), headSVGPath("default"), 20, 20, parse.HexColor("#cc00aa"))
 	require.NoError(t, err)
 	assertImg(t, img, 20, 20)
 }
 
+func TestSVGManager_GoRasterizer(t *testing.T) {
+	mgr := svgManager{
+		baseDir:    t.TempDir(),
+		rasterizer: svg.Rasterizer{},
+	}
+
+	img, err := mgr.loadSnakeSVGImage(context.Background(), tailSVGPath("go-rasterizer"), 20, 20, parse.HexColor("#cc00aa"))
+	require.NoError(t, err)
+	assertImg(t, img, 20, 20)
+	r, g, b, a := img.At(5, 10).RGBA()
+	require.Equal(t, [4]uint8{0xcc, 0x00, 0xaa, 0xff}, [4]uint8{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8), uint8(a >> 8)})
+}
+
+func TestSetRasterizer(t *testing.T) {
+	defer func(r Rasterizer) {
+		rasterizer = r
+		svgMgr.rasterizer = r
+	}(rasterizer)
+
+	require.NoError(t, SetRasterizer(RasterizerGo))
+	require.Equal(t, svg.Rasterizer{}, rasterizer)
+	require.Equal(t, svg.Rasterizer{}, svgMgr.rasterizer)
+
+	img, err := ConvertSVGStringToPNG(context.Background(), tailSVG, 20, 20)
+	require.NoError(t, err)
+	assertImg(t, img, 20, 20)
+
+	require.NoError(t, SetRasterizer(RasterizerInkscape))
+	require.Equal(t, inkscapeClient, rasterizer)
+
+	SetInkscapeShellWorkers(3)
+	defer SetInkscapeShellWorkers(2)
+	require.NoError(t, SetRasterizer(RasterizerInkscapeShell))
+	require.IsType(t, &inkscape.Pool{}, rasterizer)
+
+	require.NoError(t, SetRasterizer(RasterizerAuto))
+	require.True(t, rasterizer.IsAvailable())
+
+	require.Error(t, SetRasterizer("cairo"))
+	require.True(t, rasterizer.IsAvailable(), "an unknown rasterizer shouldn't change the current one")
+}
+
+// slowRasterizer takes a long time to rasterize, unless the context is done first.
+type slowRasterizer struct{}
+
+func (slowRasterizer) IsAvailable() bool { return true }
+
+func (r slowRasterizer) SVGToPNG(ctx context.Context, path string, width, height int) (image.Image, error) {
+	return r.SVGStringToPNG(ctx, "", width, height)
+}
+
+func (slowRasterizer) SVGStringToPNG(ctx context.Context, svgText string, width, height int) (image.Image, error) {
+	select {
+	case <-time.After(time.Minute):
+		return image.NewRGBA(image.Rect(0, 0, width, height)), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func TestRasterizeLimits(t *testing.T) {
+	defer func(r Rasterizer) {
+		rasterizer = r
+		SetRasterizeLimits(30*time.Second, runtime.NumCPU())
+	}(rasterizer)
+
+	// invalid SVGs are reported the same way, whichever rasterizer is used
+	rasterizer = svg.Rasterizer{}
+	_, err := ConvertSVGStringToPNG(context.Background(), "<html></html>", 20, 20)
+	require.ErrorIs(t, err, ErrInvalidSVG)
+
+	rasterizer = slowRasterizer{}
+	SetRasterizeLimits(100*time.Millisecond, 1)
+	_, err = ConvertSVGStringToPNG(context.Background(), tailSVG, 20, 20)
+	require.ErrorIs(t, err, ErrRasterizeTimeout)
+
+	// the Go rasterizer stops drawing at the time limit, so its slot is free for the next SVG
+	rasterizer = svg.Rasterizer{}
+	SetRasterizeLimits(100*time.Millisecond, 1)
+	heavySVG := `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 100 100">` +
+		strings.Repeat(`<circle cx="50" cy="50" r="50" fill="red" stroke="blue" stroke-width="10"/>`, 20000) + `</svg>`
+	start := time.Now()
+	_, err = ConvertSVGStringToPNG(context.Background(), heavySVG, 1000, 1000)
+	require.ErrorIs(t, err, ErrRasterizeTimeout)
+	require.Less(t, time.Since(start), 5*time.Second)
+	require.Empty(t, rasterizeSlots)
+
+	// waiting for another SVG to finish counts towards the time limit
+	SetRasterizeLimits(time.Minute, 1)
+	rasterizeSlots <- struct{}{}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = ConvertSVGStringToPNG(ctx, tailSVG, 20, 20)
+	require.ErrorIs(t, err, ErrRasterizeTimeout)
+	<-rasterizeSlots
+
+	// cancellation isn't a timeout
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	_, err = ConvertSVGStringToPNG(ctx, tailSVG, 20, 20)
+	require.ErrorIs(t, err, context.Canceled)
+	require.NotErrorIs(t, err, ErrRasterizeTimeout)
+}
+
 func TestGetSnakeSVGImage(t *testing.T) {
 
 	// these shouldn't require a fallback
-	img, err := getSnakeSVGImage(tailSVGPath("default"), "nofallback.png", 20, 20, parse.HexColor("#cc00aa"))
+	img, err := getSnakeSVGImage(context.Background(), tailSVGPath("default"), "nofallback.png", 20, 20, parse.HexColor("#cc00aa"))
 	require.NoError(t, err)
 	require.NotNil(t, img)
 	assertImg(t, img, 20, 20)
-	img, err = getSnakeSVGImage(headSVGPath("default"), "nofallback.png", 20, 20, parse.HexColor("#cc00aa"))
+	img, err = getSnakeSVGImage(context.Background(), headSVGPath("default"), "nofallback.png", 20, 20, parse.HexColor("#cc00aa"))
 	require.NoError(t, err)
 	require.NotNil(t, img)
 	assertImg(t, img, 20, 20)
 
 	// test head/tail fallbacks
-	img, err = getSnakeSVGImage(tailSVGPath("notfound"), fallbackTail, 20, 20, parse.HexColor("#cc00aa"))
+	img, err = getSnakeSVGImage(context.Background(), tailSVGPath("notfound"), fallbackTail, 20, 20, parse.HexColor("#cc00aa"))
 	require.NoError(t, err)
 	require.NotNil(t, img)
 	assertImg(t, img, 20, 20)
-	img, err = getSnakeSVGImage(headSVGPath("notfound"), fallbackHead, 20, 20, parse.HexColor("#cc00aa"))
+	img, err = getSnakeSVGImage(context.Background(), headSVGPath("notfound"), fallbackHead, 20, 20, parse.HexColor("#cc00aa"))
 	require.NoError(t, err)
 	require.NotNil(t, img)
 	assertImg(t, img, 20, 20)
 
 	// this should just error
-	img, err = getSnakeSVGImage(tailSVGPath("notfound"), "404/notfound.png", 20, 20, parse.HexColor("#cc00aa"))
+	img, err = getSnakeSVGImage(context.Background(), tailSVGPath("notfound"), "404/notfound.png", 20, 20, parse.HexColor("#cc00aa"))
 	require.Error(t, err)
 	require.Nil(t, img)
 }
@@ -132,6 +243,50 @@ func TestGetWatermarkPNG(t *testing.T) {
 	assertImg(t, img, 100, 100)
 }
 
+func TestBrandingAssets(t *testing.T) {
+	// only the default branding is available out of the box
+	require.Equal(t, []string{DefaultBranding}, BrandingAssets())
+	require.True(t, IsBrandingAsset(DefaultBranding))
+	require.False(t, IsBrandingAsset("league"))
+
+	img, err := GetBrandingPNG(DefaultBranding, 100, 100)
+	require.NoError(t, err)
+	assertImg(t, img, 100, 100)
+
+	// set up a custom branding directory
+	tmpDir, err := os.MkdirTemp("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+	require.NoError(t, os.Mkdir(filepath.Join(tmpDir, brandingDir), os.ModePerm))
+	watermarkData, err := os.ReadFile("assets/watermark.png")
+	require.NoError(t, err)
+	for _, name := range []string{"league.png", "Invalid Name.png", "notes.txt", "default.png"} {
+		require.NoError(t, os.WriteFile(filepath.Join(tmpDir, brandingDir, name), watermarkData, os.ModePerm))
+	}
+
+	// the branding asset directory is only read once, so it has to be read again after changing it
+	originalBaseDir := baseDir
+	baseDir = tmpDir
+	brandingAssetsOnce = sync.Once{}
+	defer func() {
+		baseDir = originalBaseDir
+		brandingAssetsOnce = sync.Once{}
+	}()
+
+	require.Equal(t, []string{DefaultBranding, "league"}, BrandingAssets())
+	require.True(t, IsBrandingAsset("league"))
+	require.False(t, IsBrandingAsset("notes"))
+
+	img, err = GetBrandingPNG("league", 50, 50)
+	require.NoError(t, err)
+	assertImg(t, img, 50, 50)
+
+	_, err = GetBrandingPNG("../watermark", 50, 50)
+	require.ErrorIs(t, err, ErrNotFound)
+	_, err = GetBrandingPNG("Invalid Name", 50, 50)
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
 func assertImg(t *testing.T, img image.Image, w, h int) {
 	require.NotNil(t, img)
 	assert.Equal(t, w, img.Bounds().Max.X)
@@ -0,0 +1,279 @@
+package media
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// unsafeElements are removed from SVGs, along with everything inside them.
+var unsafeElements = map[string]bool{
+	"script":        true,
+	"foreignobject": true,
+	"handler":       true,
+	"listener":      true,
+}
+
+var reCSSURL = regexp.MustCompile(`(?i)url\(\s*['"]?\s*([^'")\s]*)`)
+
+// reCSSEscape matches CSS escapes: up to 6 hex digits and an optional space, an escaped newline, or any other escaped character.
+var reCSSEscape = regexp.MustCompile(`\\(?:([0-9a-fA-F]{1,6})(?:\r\n|[ \t\r\n\f])?|(\r\n|[\r\n\f])|(.))`)
+
+var textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+var attrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+
+// SanitizeSVG removes anything from an SVG that could run scripts or load other resources when it's displayed,
+// like scripts, event handlers, external references and foreignObject.
+// Only the root svg element is kept, without any XML declaration or comments around it, so the result can be embedded in other SVGs.
+func SanitizeSVG(svgText string) (string, error) {
+	// there's nothing unsafe in an empty resource, and it's left to the rasterizer to decide what to do with it
+	if strings.TrimSpace(svgText) == "" {
+		return "", nil
+	}
+
+	decoder := xml.NewDecoder(strings.NewReader(svgText))
+	var buf strings.Builder
+
+	// the names of the open elements, which RawToken doesn't check are closed in the right order
+	var open []string
+	// whether the last start tag still needs closing, so that empty elements can be self-closed like they were
+	startPending := false
+	rootClosed := false
+
+	for {
+		// RawToken keeps namespace prefixes as they were, rather than replacing them with the namespace URL
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("%w: %w", ErrInvalidSVG, err)
+		}
+		if rootClosed {
+			continue
+		}
+		if _, ok := token.(xml.EndElement); !ok && startPending {
+			buf.WriteString(">")
+			startPending = false
+		}
+
+		switch v := token.(type) {
+		case xml.StartElement:
+			if len(open) == 0 && v.Name.Local != "svg" {
+				return "", fmt.Errorf("%w: root element is %q, not svg", ErrInvalidSVG, v.Name.Local)
+			}
+
+			if isUnsafeElement(v) {
+				if err := skipElement(decoder); err != nil {
+					return "", err
+				}
+				continue
+			}
+
+			if strings.EqualFold(v.Name.Local, "style") {
+				css, ok, err := readStyle(decoder)
+				if err != nil {
+					return "", err
+				}
+				if !ok {
+					continue
+				}
+				writeStartElement(&buf, v)
+				buf.WriteString(">")
+				buf.WriteString(textEscaper.Replace(css))
+				fmt.Fprintf(&buf, "</%s>", qualifiedName(v.Name))
+				continue
+			}
+
+			writeStartElement(&buf, v)
+			startPending = true
+			open = append(open, qualifiedName(v.Name))
+
+		case xml.EndElement:
+			name := qualifiedName(v.Name)
+			if len(open) == 0 || open[len(open)-1] != name {
+				return "", fmt.Errorf("%w: unexpected end element </%s>", ErrInvalidSVG, name)
+			}
+			open = open[:len(open)-1]
+
+			if startPending {
+				buf.WriteString("/>")
+				startPending = false
+			} else {
+				fmt.Fprintf(&buf, "</%s>", name)
+			}
+			rootClosed = len(open) == 0
+
+		case xml.CharData:
+			if len(open) == 0 {
+				continue
+			}
+			buf.WriteString(textEscaper.Replace(string(v)))
+		}
+		// comments, processing instructions and directives (like DOCTYPE) are all dropped
+	}
+
+	if !rootClosed {
+		return "", fmt.Errorf("%w: no complete svg element", ErrInvalidSVG)
+	}
+	return buf.String(), nil
+}
+
+func qualifiedName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
+
+func writeStartElement(buf *strings.Builder, start xml.StartElement) {
+	buf.WriteString("<")
+	buf.WriteString(qualifiedName(start.Name))
+	for _, attr := range start.Attr {
+		if !isSafeAttr(attr) {
+			continue
+		}
+		fmt.Fprintf(buf, ` %s="%s"`, qualifiedName(attr.Name), attrEscaper.Replace(attr.Value))
+	}
+}
+
+// isUnsafeElement checks for elements that can run scripts, or embed other documents.
+func isUnsafeElement(start xml.StartElement) bool {
+	if unsafeElements[strings.ToLower(start.Name.Local)] {
+		return true
+	}
+
+	// animations can change links and event handlers, which would get around removing them
+	for _, attr := range start.Attr {
+		if attr.Name.Local != "attributeName" {
+			continue
+		}
+		target := strings.ToLower(strings.TrimSpace(attr.Value))
+		if i := strings.LastIndex(target, ":"); i >= 0 {
+			target = target[i+1:]
+		}
+		if target == "href" || strings.HasPrefix(target, "on") {
+			return true
+		}
+	}
+	return false
+}
+
+// isSafeAttr checks for attributes that are event handlers, or refer to anything outside of the SVG.
+func isSafeAttr(attr xml.Attr) bool {
+	local := strings.ToLower(attr.Name.Local)
+	switch {
+	case strings.HasPrefix(local, "on"):
+		return false
+	case local == "href":
+		// only links to other elements in the same SVG are allowed
+		return strings.HasPrefix(strings.TrimSpace(attr.Value), "#")
+	case attr.Name.Space == "xml" && local == "base":
+		return false
+	}
+	return !hasExternalReference(attr.Value)
+}
+
+// hasExternalReference checks CSS, or an attribute value, for anything that refers to outside of the SVG.
+// Comments and escapes are decoded first, so that they can't hide a reference.
+func hasExternalReference(value string) bool {
+	value = decodeCSS(value)
+	lower := strings.ToLower(value)
+	if strings.Contains(lower, "javascript:") || strings.Contains(lower, "@import") || strings.Contains(lower, "expression(") {
+		return true
+	}
+	for _, match := range reCSSURL.FindAllStringSubmatch(value, -1) {
+		if !strings.HasPrefix(match[1], "#") {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeCSS removes comments from CSS and decodes its escapes, such as "\75rl(" for "url(".
+// An unclosed comment runs to the end of the CSS.
+func decodeCSS(css string) string {
+	var decoded strings.Builder
+	for {
+		start := strings.Index(css, "/*")
+		if start < 0 {
+			decoded.WriteString(css)
+			break
+		}
+		decoded.WriteString(css[:start])
+		end := strings.Index(css[start+2:], "*/")
+		if end < 0 {
+			break
+		}
+		css = css[start+2+end+2:]
+	}
+
+	return reCSSEscape.ReplaceAllStringFunc(decoded.String(), func(escape string) string {
+		match := reCSSEscape.FindStringSubmatch(escape)
+		switch {
+		case match[1] != "":
+			r, _ := strconv.ParseUint(match[1], 16, 32)
+			if r == 0 || r > utf8.MaxRune {
+				return string(utf8.RuneError)
+			}
+			return string(rune(r))
+		case match[2] != "":
+			// escaped newlines are removed
+			return ""
+		}
+		return match[3]
+	})
+}
+
+// skipElement skips the rest of the element that was just started.
+func skipElement(decoder *xml.Decoder) error {
+	depth := 1
+	for depth > 0 {
+		token, err := decoder.RawToken()
+		if errors.Is(err, io.EOF) {
+			return fmt.Errorf("%w: unclosed element", ErrInvalidSVG)
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidSVG, err)
+		}
+		switch token.(type) {
+		case xml.StartElement:
+			depth++
+		case xml.EndElement:
+			depth--
+		}
+	}
+	return nil
+}
+
+// readStyle reads the CSS in the style element that was just started.
+// It isn't ok to keep the style if it refers to anything outside of the SVG, or isn't only CSS.
+func readStyle(decoder *xml.Decoder) (string, bool, error) {
+	var css strings.Builder
+	ok := true
+	depth := 1
+	for depth > 0 {
+		token, err := decoder.RawToken()
+		if errors.Is(err, io.EOF) {
+			return "", false, fmt.Errorf("%w: unclosed style element", ErrInvalidSVG)
+		}
+		if err != nil {
+			return "", false, fmt.Errorf("%w: %w", ErrInvalidSVG, err)
+		}
+		switch v := token.(type) {
+		case xml.StartElement:
+			depth++
+			ok = false
+		case xml.EndElement:
+			depth--
+		case xml.CharData:
+			css.Write(v)
+		}
+	}
+	return css.String(), ok && !hasExternalReference(css.String()), nil
+}
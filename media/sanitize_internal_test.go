@@ -0,0 +1,107 @@
+package media
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSanitizeSVG(t *testing.T) {
+	// safe SVGs are left as they are
+	for _, svg := range []string{headSVG, tailSVG, ""} {
+		sanitized, err := SanitizeSVG(svg)
+		require.NoError(t, err)
+		require.Equal(t, svg, sanitized)
+	}
+
+	for _, test := range []struct {
+		name     string
+		svg      string
+		expected string
+	}{
+		{
+			"script",
+			`<svg><script>alert(1)</script><path d="M0 0"/><SCRIPT type="text/javascript"><![CDATA[alert(2)]]></SCRIPT></svg>`,
+			`<svg><path d="M0 0"/></svg>`,
+		},
+		{
+			"foreignObject",
+			`<svg><foreignObject><html:iframe xmlns:html="http://www.w3.org/1999/xhtml" src="https://example.com"/></foreignObject></svg>`,
+			`<svg></svg>`,
+		},
+		{
+			"event handlers",
+			`<svg onload="alert(1)"><rect width="10" onClick="alert(2)" onmouseover="alert(3)"/></svg>`,
+			`<svg><rect width="10"/></svg>`,
+		},
+		{
+			"external references",
+			`<svg xmlns:xlink="http://www.w3.org/1999/xlink" xml:base="https://example.com/"><image href="https://example.com/a.png"/><use xlink:href="#shape"/><use href="javascript:alert(1)"/><a xlink:href="data:text/html,hi">link</a></svg>`,
+			`<svg xmlns:xlink="http://www.w3.org/1999/xlink"><image/><use xlink:href="#shape"/><use/><a>link</a></svg>`,
+		},
+		{
+			"external urls in attributes",
+			`<svg><rect fill="url(#gradient)" stroke="url( 'https://example.com/x.svg#g' )" style="background: url(https://example.com/track.png)"/></svg>`,
+			`<svg><rect fill="url(#gradient)"/></svg>`,
+		},
+		{
+			"styles",
+			`<svg><style>.a { fill: url(#g); }</style><style>@import url(https://example.com/a.css);</style><style><b>bold</b></style></svg>`,
+			`<svg><style>.a { fill: url(#g); }</style></svg>`,
+		},
+		{
+			"escaped external references",
+			`<svg><rect style="fill: \75rl(https://evil.example/x)" stroke="u\rl(https://evil.example/y)"/><style>@\69mport "https://evil.example/a.css"</style><style>@im/**/port "https://evil.example/b.css"</style><style>.a { fill: \75 rl(#g); }</style></svg>`,
+			`<svg><rect/><style>.a { fill: \75 rl(#g); }</style></svg>`,
+		},
+		{
+			"animations of links and event handlers",
+			`<svg><a><set attributeName="href" to="javascript:alert(1)"/><animate attributeName="xlink:href" values="javascript:alert(1)"/><animate attributeName="opacity" from="0" to="1"/></a></svg>`,
+			`<svg><a><animate attributeName="opacity" from="0" to="1"/></a></svg>`,
+		},
+		{
+			"everything outside of the root element",
+			"<?xml version=\"1.0\"?>\n<!DOCTYPE svg>\n<!-- a comment -->\n<svg id=\"root\"><!-- inside --><g>&lt;text&gt; &amp; &quot;more&quot;</g></svg>\n<!-- after -->",
+			`<svg id="root"><g>&lt;text&gt; &amp; "more"</g></svg>`,
+		},
+		{
+			"escaped attribute values",
+			`<svg><text font-family="&quot;A&gt;B&quot;">text</text></svg>`,
+			`<svg><text font-family="&quot;A&gt;B&quot;">text</text></svg>`,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			sanitized, err := SanitizeSVG(test.svg)
+			require.NoError(t, err)
+			require.Equal(t, test.expected, sanitized)
+		})
+	}
+
+	for _, svg := range []string{
+		"not an svg",
+		`<html><svg></svg></html>`,
+		`<svg><g></svg>`,
+		`<svg><script>`,
+		`<svg>`,
+		`<!DOCTYPE svg [<!ENTITY lol "lol">]><svg>&lol;</svg>`,
+	} {
+		_, err := SanitizeSVG(svg)
+		require.ErrorIs(t, err, ErrInvalidSVG, svg)
+	}
+}
+
+func TestGetSanitizedSVG(t *testing.T) {
+	defer SetSource(mediaSource)
+	SetSource(BundleSource{FS: fstest.MapFS{
+		"snakes/heads/unsafe.svg":  {Data: []byte(`<svg viewBox="0 0 100 100" onload="alert(1)"><script>alert(2)</script><path d="M0 0h100v100z"/></svg>`)},
+		"snakes/heads/invalid.svg": {Data: []byte(`<svg><path></svg>`)},
+	}})
+
+	svg, err := GetHeadSVG("unsafe")
+	require.NoError(t, err)
+	require.Equal(t, `<svg viewBox="0 0 100 100"><path d="M0 0h100v100z"/></svg>`, svg)
+
+	_, err = GetHeadSVG("invalid")
+	require.ErrorIs(t, err, ErrInvalidSVG)
+}
@@ -0,0 +1,289 @@
+package media
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Source is where customization SVGs come from, such as the Battlesnake media server or an offline asset bundle.
+type Source interface {
+	// Get gets the resource at the media path, like "snakes/heads/default.svg".
+	// It returns ErrNotFound when the source doesn't have the resource.
+	Get(path string) (string, error)
+}
+
+// Lister is implemented by sources that can list the customizations they have.
+type Lister interface {
+	// List lists the names of the SVGs in a media directory, like "snakes/heads".
+	List(dir string) ([]string, error)
+}
+
+// mediaSource is the source for all customizations.
+var mediaSource = defaultSource()
+
+// SetSource changes where customizations come from.
+// It should be called before any customizations are loaded.
+func SetSource(s Source) {
+	mediaSource = s
+	mediaCache.Flush()
+}
+
+// defaultSource uses the embedded asset bundle when the exporter is built with one, and the media server otherwise.
+func defaultSource() Source {
+	if bundle, ok := embeddedBundle(); ok {
+		return BundleSource{FS: bundle}
+	}
+	return ServerSource{}
+}
+
+// mediaClient is used for all requests to media servers.
+// The timeout stops renders waiting too long on a slow media server, when the image could fall back to the default.
+var mediaClient = &http.Client{Timeout: 10 * time.Second}
+
+// ServerSource gets resources from a media server.
+type ServerSource struct {
+	// URL is the base URL of the media server.
+	// If left empty, the Battlesnake media server will be used.
+	URL string
+}
+
+func (s ServerSource) Get(path string) (string, error) {
+	log.WithField("path", path).Info("fetching media resource")
+	url := fmt.Sprintf("%s/%s", s.baseURL(), path)
+
+	response, err := mediaClient.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	if response.StatusCode == http.StatusNotFound || response.StatusCode == http.StatusForbidden {
+		return "", ErrNotFound
+	}
+	if response.StatusCode != 200 {
+		return "", fmt.Errorf("got non 200 from media '%s': %d", path, response.StatusCode)
+	}
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return "", err
+	}
+
+	return string(body), nil
+}
+
+func (s ServerSource) baseURL() string {
+	if s.URL == "" {
+		return mediaServerURL
+	}
+	return s.URL
+}
+
+// List lists customizations using the media server's asset bundle manifest,
+// so a media server that serves a synced asset bundle can be listed.
+// It returns ErrNotFound when the server doesn't have a manifest.
+func (s ServerSource) List(dir string) ([]string, error) {
+	resource, err := s.Get(ManifestFile)
+	if err != nil {
+		return nil, err
+	}
+	var manifest Manifest
+	if err := json.Unmarshal([]byte(resource), &manifest); err != nil {
+		return nil, fmt.Errorf("invalid asset bundle manifest: %w", err)
+	}
+
+	var names []string
+	for p := range manifest.Files {
+		if name, ok := svgName(dir, p); ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// BundleSource gets resources from an asset bundle, which has the same layout as the media server.
+// On its own, the bundle is the source of truth, so resources that aren't in it are never fetched from anywhere else.
+type BundleSource struct {
+	FS fs.FS
+}
+
+// NewBundleSource creates a source for the asset bundle in the given directory.
+func NewBundleSource(dir string) (BundleSource, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return BundleSource{}, err
+	}
+	if !info.IsDir() {
+		return BundleSource{}, fmt.Errorf("asset bundle is not a directory: %s", dir)
+	}
+	return BundleSource{FS: os.DirFS(dir)}, nil
+}
+
+func (b BundleSource) List(dir string) ([]string, error) {
+	entries, err := fs.ReadDir(b.FS, dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if name, ok := svgName(dir, path.Join(dir, entry.Name())); ok && !entry.IsDir() {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// svgName returns the customization name of an SVG media path, if it's directly in the media directory.
+func svgName(dir, p string) (string, bool) {
+	if path.Dir(p) != dir || path.Ext(p) != ".svg" {
+		return "", false
+	}
+	name := strings.TrimSuffix(path.Base(p), ".svg")
+	return name, reCustomizationName.MatchString(name)
+}
+
+func (b BundleSource) Get(path string) (string, error) {
+	// fs.FS rejects paths that could escape the bundle, like ones containing ".."
+	data, err := fs.ReadFile(b.FS, path)
+	if errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrInvalid) {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+var reNamespace = regexp.MustCompile(`^[a-z0-9-]{1,32}$`)
+
+// NamedSource is a source that customization IDs can be namespaced to.
+type NamedSource struct {
+	// Namespace is the prefix of the IDs that only use this source, like "private" for "private:my-head".
+	// It's optional.
+	Namespace string
+	Source
+}
+
+// Sources looks up resources in each source in turn, falling back to the next one when a source doesn't have a resource.
+// Namespaced customization IDs, like "private:my-head", are only looked up in the source with that namespace.
+type Sources []NamedSource
+
+// ParseSources parses a comma-separated list of sources in fallback order.
+// Each source is a media server URL or an asset bundle directory, optionally preceded by a namespace and "=",
+// like "private=https://media.example.com,https://media.battlesnake.com".
+func ParseSources(spec string) (Sources, error) {
+	var sources Sources
+	namespaces := map[string]bool{}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		var namespace string
+		if ns, location, ok := strings.Cut(entry, "="); ok && reNamespace.MatchString(ns) {
+			if namespaces[ns] {
+				return nil, fmt.Errorf("duplicate media source namespace: %q", ns)
+			}
+			namespaces[ns] = true
+			namespace, entry = ns, location
+		}
+
+		var source Source
+		if strings.HasPrefix(entry, "http://") || strings.HasPrefix(entry, "https://") {
+			source = ServerSource{URL: strings.TrimSuffix(entry, "/")}
+		} else {
+			bundle, err := NewBundleSource(entry)
+			if err != nil {
+				return nil, err
+			}
+			source = bundle
+		}
+		sources = append(sources, NamedSource{Namespace: namespace, Source: source})
+	}
+
+	if len(sources) == 0 {
+		return nil, errors.New("no media sources")
+	}
+	return sources, nil
+}
+
+func (s Sources) Get(p string) (string, error) {
+	dir, file := path.Split(p)
+	if namespace, name, ok := strings.Cut(file, ":"); ok {
+		for _, source := range s {
+			if source.Namespace == namespace {
+				return source.Get(dir + name)
+			}
+		}
+		return "", ErrNotFound
+	}
+
+	// a source that fails doesn't stop the others from being tried, but its error is returned if none of them have the resource
+	var firstErr error
+	for _, source := range s {
+		resource, err := source.Get(p)
+		if err == nil {
+			return resource, nil
+		}
+		if !errors.Is(err, ErrNotFound) {
+			log.WithField("path", p).WithField("namespace", source.Namespace).WithError(err).Warn("media source failed")
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	if firstErr != nil {
+		return "", firstErr
+	}
+	return "", ErrNotFound
+}
+
+// List lists the customizations in every source that can be listed, with the ones in namespaced sources namespaced.
+// Sources that can't be listed are skipped, and ErrPartialList is returned along with the customizations from the rest.
+func (s Sources) List(dir string) ([]string, error) {
+	seen := map[string]bool{}
+	var names []string
+	var err error
+	for _, source := range s {
+		lister, ok := source.Source.(Lister)
+		if !ok {
+			err = ErrPartialList
+			continue
+		}
+		sourceNames, listErr := lister.List(dir)
+		if listErr != nil {
+			if !errors.Is(listErr, ErrNotFound) {
+				log.WithField("dir", dir).WithField("namespace", source.Namespace).WithError(listErr).Warn("unable to list media source")
+			}
+			err = ErrPartialList
+			continue
+		}
+		for _, name := range sourceNames {
+			if source.Namespace != "" {
+				name = source.Namespace + ":" + name
+			}
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	return names, err
+}
@@ -0,0 +1,146 @@
+package render
+
+import (
+	"fmt"
+	"image/color"
+	"io"
+	"strings"
+	"unicode"
+
+	"github.com/BattlesnakeOfficial/exporter/engine"
+	"github.com/BattlesnakeOfficial/exporter/parse"
+)
+
+const (
+	ANSIEmpty      = " "
+	ANSIFood       = "●"
+	ANSIHazard     = "░"
+	ANSISnakeTail  = "•"
+	ANSILegendMark = "██"
+
+	// ANSIClearScreen clears the terminal and moves the cursor to the top-left corner.
+	ANSIClearScreen = "\x1b[2J\x1b[H"
+
+	ansiReset = "\x1b[0m"
+)
+
+// ansiHazardColor is used for hazards instead of ColorHazard, which relies on transparency.
+var ansiHazardColor = color.RGBA{0x80, 0x80, 0x80, 0xff}
+
+// ansiSnakeHeads are arrows pointing in the direction the snake is moving.
+var ansiSnakeHeads = map[snakeDirection]string{
+	movingUp:    "▲",
+	movingDown:  "▼",
+	movingLeft:  "◀",
+	movingRight: "▶",
+}
+
+// ansiSnakeCorners are the box-drawing characters for corner body segments.
+var ansiSnakeCorners = map[snakeCorner]string{
+	cornerBottomLeft:  "╚",
+	cornerBottomRight: "╝",
+	cornerTopLeft:     "╔",
+	cornerTopRight:    "╗",
+}
+
+// ansiForeground creates the escape sequence for a 24-bit foreground colour.
+func ansiForeground(c color.Color) string {
+	r, g, b, _ := c.RGBA()
+	return fmt.Sprintf("\x1b[38;2;%d;%d;%dm", uint8(r>>8), uint8(g>>8), uint8(b>>8))
+}
+
+// ansiBackground creates the escape sequence for a 24-bit background colour.
+func ansiBackground(c color.Color) string {
+	r, g, b, _ := c.RGBA()
+	return fmt.Sprintf("\x1b[48;2;%d;%d;%dm", uint8(r>>8), uint8(g>>8), uint8(b>>8))
+}
+
+// ansiSnakeBody gets the box-drawing character for a body segment.
+func ansiSnakeBody(c BoardSquareContent) string {
+	if corner, ok := ansiSnakeCorners[c.Corner]; ok {
+		return corner
+	}
+	if c.Direction == movingUp || c.Direction == movingDown {
+		return "║"
+	}
+	return "═"
+}
+
+// ansiSquare renders a single board square, including colour escape sequences.
+func ansiSquare(contents []BoardSquareContent) string {
+	if len(contents) == 0 {
+		return ANSIEmpty
+	}
+
+	// hazards are shown as a background colour when they overlap other things
+	background := ""
+	last := contents[len(contents)-1]
+	if last.Type == BoardSquareHazard {
+		if len(contents) == 1 {
+			return ansiForeground(ansiHazardColor) + ANSIHazard + ansiReset
+		}
+		background = ansiBackground(ansiHazardColor)
+		last = contents[len(contents)-2]
+	}
+
+	var symbol string
+	var c color.Color
+	switch last.Type {
+	case BoardSquareSnakeHead:
+		symbol, c = ansiSnakeHeads[last.Direction], last.Color
+	case BoardSquareSnakeBody:
+		symbol, c = ansiSnakeBody(last), last.Color
+	case BoardSquareSnakeTail:
+		symbol, c = ANSISnakeTail, last.Color
+	case BoardSquareFood:
+		symbol, c = ANSIFood, parse.HexColor(ColorFood)
+	default:
+		return ANSIEmpty
+	}
+
+	return background + ansiForeground(c) + symbol + ansiReset
+}
+
+// GameFrameToANSI renders the game frame as text for display in a terminal.
+// Snakes are drawn in their own colours using ANSI 24-bit colour escape sequences,
+// with Unicode box-drawing characters for their bodies.
+// A legend mapping colours to snake names is written underneath the board.
+func GameFrameToANSI(w io.Writer, g *engine.Game, gf *engine.GameFrame) error {
+	board := GameFrameToBoard(g, gf)
+
+	var sb strings.Builder
+	sb.WriteString("┌" + strings.Repeat("─", board.Width) + "┐\n")
+	for y := board.Height - 1; y >= 0; y-- {
+		sb.WriteString("│")
+		for x := 0; x < board.Width; x++ {
+			sb.WriteString(ansiSquare(board.Contents(x, y)))
+		}
+		sb.WriteString("│\n")
+	}
+	sb.WriteString("└" + strings.Repeat("─", board.Width) + "┘\n")
+
+	// legend
+	for _, snake := range gf.Snakes {
+		c := parse.HexColor(snake.Color)
+		status := ""
+		if snake.Death != nil {
+			c = parse.HexColor(ColorDeadSnake)
+			status = fmt.Sprintf(" (eliminated on turn %d)", snake.Death.Turn)
+		}
+		sb.WriteString(fmt.Sprintf("%s%s%s %s%s\n", ansiForeground(c), ANSILegendMark, ansiReset, stripControlCharacters(snake.Name), status))
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// stripControlCharacters removes any characters that could be interpreted by the terminal,
+// such as escape sequences embedded in snake names.
+func stripControlCharacters(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,71 @@
+package render
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/BattlesnakeOfficial/exporter/engine"
+	"github.com/BattlesnakeOfficial/exporter/parse"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var regexpANSI = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+func TestANSISquare(t *testing.T) {
+	red := parse.HexColor("#ff0000")
+
+	assert.Equal(t, ANSIEmpty, ansiSquare(nil))
+	assert.Equal(t, "\x1b[38;2;255;92;117m●\x1b[0m", ansiSquare([]BoardSquareContent{{Type: BoardSquareFood}}))
+	assert.Equal(t, "\x1b[38;2;128;128;128m░\x1b[0m", ansiSquare([]BoardSquareContent{{Type: BoardSquareHazard}}))
+
+	// heads point in the direction of movement
+	assert.Equal(t, "\x1b[38;2;255;0;0m▲\x1b[0m", ansiSquare([]BoardSquareContent{{Type: BoardSquareSnakeHead, Color: red, Direction: movingUp}}))
+	assert.Equal(t, "\x1b[38;2;255;0;0m◀\x1b[0m", ansiSquare([]BoardSquareContent{{Type: BoardSquareSnakeHead, Color: red, Direction: movingLeft}}))
+
+	// hazards become the background when they overlap something
+	assert.Equal(t, "\x1b[48;2;128;128;128m\x1b[38;2;255;0;0m•\x1b[0m", ansiSquare([]BoardSquareContent{
+		{Type: BoardSquareSnakeTail, Color: red},
+		{Type: BoardSquareHazard},
+	}))
+}
+
+func TestANSISnakeBody(t *testing.T) {
+	assert.Equal(t, "║", ansiSnakeBody(BoardSquareContent{Direction: movingUp, Corner: cornerNone}))
+	assert.Equal(t, "║", ansiSnakeBody(BoardSquareContent{Direction: movingDown, Corner: cornerNone}))
+	assert.Equal(t, "═", ansiSnakeBody(BoardSquareContent{Direction: movingLeft, Corner: cornerNone}))
+	assert.Equal(t, "═", ansiSnakeBody(BoardSquareContent{Direction: movingRight, Corner: cornerNone}))
+	assert.Equal(t, "╔", ansiSnakeBody(BoardSquareContent{Direction: movingRight, Corner: cornerTopLeft}))
+	assert.Equal(t, "╝", ansiSnakeBody(BoardSquareContent{Direction: movingUp, Corner: cornerBottomRight}))
+}
+
+func TestGameFrameToANSI(t *testing.T) {
+	g := &engine.Game{Width: 3, Height: 3}
+	gf := &engine.GameFrame{
+		Food: []engine.Point{{X: 2, Y: 2}},
+		Snakes: []engine.Snake{
+			{
+				Name:  "Snake \x1b[2JOne",
+				Color: "#00ff00",
+				Body:  []engine.Point{{X: 1, Y: 1}, {X: 1, Y: 0}, {X: 0, Y: 0}},
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, GameFrameToANSI(&buf, g, gf))
+
+	// remove colours to check the layout
+	plain := regexpANSI.ReplaceAllString(buf.String(), "")
+	assert.Equal(t, strings.Join([]string{
+		"┌───┐",
+		"│  ●│",
+		"│ ▲ │",
+		"│•╝ │",
+		"└───┘",
+		"██ Snake [2JOne", // the escape character is stripped
+		"",
+	}, "\n"), plain)
+}
@@ -17,6 +17,28 @@ const (
 	ASCIIHazard    = "."
 )
 
+// TextFrameRenderer renders a single game frame as text, such as GameFrameToASCII or GameFrameToANSI.
+type TextFrameRenderer func(w io.Writer, g *engine.Game, gf *engine.GameFrame) error
+
+// GameFramesToText renders each of the game frames as text, one after the other.
+// Every frame is preceded by a turn header, so that each ASCII frame can be read back with ASCIIToGameFrame.
+func GameFramesToText(w io.Writer, g *engine.Game, gameFrames []*engine.GameFrame, renderFrame TextFrameRenderer) error {
+	for i, gf := range gameFrames {
+		if i > 0 {
+			if _, err := fmt.Fprint(w, "\n"); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(w, "turn: %d\n", gf.Turn); err != nil {
+			return err
+		}
+		if err := renderFrame(w, g, gf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func GameFrameToASCII(w io.Writer, g *engine.Game, gf *engine.GameFrame) error {
 	board := GameFrameToBoard(g, gf)
 
@@ -39,7 +61,7 @@ func GameFrameToASCII(w io.Writer, g *engine.Game, gf *engine.GameFrame) error {
 				continue
 			}
 
-			contents := board.getContents(x, y)
+			contents := board.Contents(x, y)
 
 			// since ascii can't have overlapping items, we take the last thing on the square
 			last := contents[len(contents)-1]
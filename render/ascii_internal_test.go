@@ -0,0 +1,41 @@
+package render
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/BattlesnakeOfficial/exporter/engine"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGameFramesToText(t *testing.T) {
+	g := &engine.Game{Width: 3, Height: 2}
+	gameFrames := []*engine.GameFrame{
+		{Turn: 0, Food: []engine.Point{{X: 0, Y: 0}}},
+		{Turn: 1, Snakes: []engine.Snake{{Body: []engine.Point{{X: 1, Y: 1}, {X: 1, Y: 0}}}}},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, GameFramesToText(&buf, g, gameFrames, GameFrameToASCII))
+	assert.Equal(t, `turn: 0
+-----
+|   |
+|*  |
+-----
+
+turn: 1
+-----
+| H |
+| T |
+-----
+`, buf.String())
+
+	// each section can be parsed back into a frame
+	for i, section := range strings.Split(buf.String(), "\n\n") {
+		_, gf, err := ASCIIToGameFrame(strings.NewReader(section))
+		require.NoError(t, err)
+		assert.Equal(t, i, gf.Turn)
+	}
+}
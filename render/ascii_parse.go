@@ -0,0 +1,277 @@
+package render
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/BattlesnakeOfficial/exporter/engine"
+)
+
+var ErrInvalidASCIIBoard = errors.New("invalid ASCII board")
+
+// ASCIIDefaultSnakeColors are the colours given to snakes that don't have one in the ASCII board header.
+var ASCIIDefaultSnakeColors = []string{"#e91e63", "#2196f3", "#4caf50", "#ff9800", "#9c27b0", "#00bcd4", "#795548", "#607d8b"}
+
+var reASCIITurnHeader = regexp.MustCompile(`^turn:\s*(\d+)$`)
+var reASCIISnakeHeader = regexp.MustCompile(`^([A-Z]):\s*([A-Za-z0-9_-]+)(?:\s+(#[0-9A-Fa-f]{6}))?(?:\s+(.+))?$`)
+
+// asciiSnake holds the details of a snake while parsing an ASCII board.
+type asciiSnake struct {
+	snake engine.Snake
+	head  *engine.Point
+	// isSegment checks whether a board symbol is part of this snake's body
+	isSegment func(symbol rune) bool
+	// isTail checks whether a board symbol is explicitly this snake's tail
+	isTail func(symbol rune) bool
+}
+
+// ASCIIToGameFrame parses an ASCII board into a game and game frame.
+// It is the inverse of GameFrameToASCII, and accepts the same board format.
+//
+// The board can be preceded by an optional header:
+//
+//	turn: 12
+//	A: gs_snake1 #ff0000 Snake One
+//	B: gs_snake2
+//
+// The turn line sets the turn number. Each snake line assigns an uppercase letter to a snake,
+// followed by the snake ID and an optional colour and name.
+// When snakes are declared in the header, the uppercase letter marks the snake's head and
+// the lowercase letter marks the rest of its body. Without a header, the symbols written by
+// GameFrameToASCII are used (H for heads, O for bodies and T for tails).
+//
+// Snake bodies are ordered by following adjacent segments from the head.
+// When more than one segment is adjacent, the segment straight ahead is preferred,
+// followed by up, down, left and right. Explicit tails (T) are only followed once there
+// are no other body segments left.
+func ASCIIToGameFrame(r io.Reader) (*engine.Game, *engine.GameFrame, error) {
+	game := &engine.Game{}
+	gameFrame := &engine.GameFrame{}
+	var snakes []*asciiSnake
+	var rows []string
+
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	inBoard := false
+	boardDone := false
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimRight(scanner.Text(), "\r")
+
+		switch {
+		case boardDone:
+			if strings.TrimSpace(line) != "" {
+				return nil, nil, fmt.Errorf("%w: unexpected content after board on line %d", ErrInvalidASCIIBoard, lineNum)
+			}
+		case strings.HasPrefix(line, "-"):
+			if inBoard {
+				boardDone = true
+			}
+			inBoard = true
+		case inBoard:
+			if len(line) < 2 || !strings.HasPrefix(line, "|") || !strings.HasSuffix(line, "|") {
+				return nil, nil, fmt.Errorf("%w: line %d should be a board row surrounded by |", ErrInvalidASCIIBoard, lineNum)
+			}
+			rows = append(rows, line[1:len(line)-1])
+		default:
+			line = strings.TrimSpace(line)
+			if line == "" {
+				continue
+			}
+			if m := reASCIITurnHeader.FindStringSubmatch(line); m != nil {
+				gameFrame.Turn, _ = strconv.Atoi(m[1])
+				continue
+			}
+			m := reASCIISnakeHeader.FindStringSubmatch(line)
+			if m == nil {
+				return nil, nil, fmt.Errorf("%w: unrecognized header on line %d", ErrInvalidASCIIBoard, lineNum)
+			}
+			letter := rune(m[1][0])
+			for _, s := range snakes {
+				if s.isSegment(unicode.ToLower(letter)) {
+					return nil, nil, fmt.Errorf("%w: snake %c is declared more than once", ErrInvalidASCIIBoard, letter)
+				}
+			}
+			snakes = append(snakes, newASCIIHeaderSnake(letter, m[2], m[3], m[4]))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	if !boardDone {
+		return nil, nil, fmt.Errorf("%w: board should be surrounded by a border", ErrInvalidASCIIBoard)
+	}
+	if len(rows) == 0 {
+		return nil, nil, fmt.Errorf("%w: board has no rows", ErrInvalidASCIIBoard)
+	}
+
+	game.Height = len(rows)
+	game.Width = len([]rune(rows[0]))
+	if game.Width > engine.MaxBoardSize || game.Height > engine.MaxBoardSize {
+		return nil, nil, fmt.Errorf("%w: board is %dx%d, but can't be bigger than %dx%d", ErrInvalidASCIIBoard, game.Width, game.Height, engine.MaxBoardSize, engine.MaxBoardSize)
+	}
+	symbols := make(map[engine.Point]rune, game.Width*game.Height)
+	for i, row := range rows {
+		runes := []rune(row)
+		if len(runes) != game.Width {
+			return nil, nil, fmt.Errorf("%w: board rows should all be %d squares wide", ErrInvalidASCIIBoard, game.Width)
+		}
+		y := game.Height - 1 - i
+		for x, symbol := range runes {
+			p := engine.Point{X: x, Y: y}
+			symbols[p] = symbol
+		}
+	}
+
+	// legacy boards don't have a header, so each H is the head of a different snake
+	legacy := len(snakes) == 0
+
+	for y := game.Height - 1; y >= 0; y-- {
+		for x := 0; x < game.Width; x++ {
+			p := engine.Point{X: x, Y: y}
+			symbol := symbols[p]
+			switch {
+			case string(symbol) == ASCIIEmpty:
+			case string(symbol) == ASCIIFood:
+				gameFrame.Food = append(gameFrame.Food, p)
+			case string(symbol) == ASCIIHazard:
+				gameFrame.Hazards = append(gameFrame.Hazards, p)
+			case legacy && string(symbol) == ASCIISnakeHead:
+				s := newASCIILegacySnake(len(snakes))
+				s.head = &engine.Point{X: x, Y: y}
+				snakes = append(snakes, s)
+			case legacy && (string(symbol) == ASCIISnakeBody || string(symbol) == ASCIISnakeTail):
+				// these get traced from the head
+			case !legacy && unicode.IsUpper(symbol):
+				s := findASCIIHeaderSnake(snakes, symbol)
+				if s == nil {
+					return nil, nil, fmt.Errorf("%w: snake %c at (%d,%d) is not declared in the header", ErrInvalidASCIIBoard, symbol, x, y)
+				}
+				if s.head != nil {
+					return nil, nil, fmt.Errorf("%w: snake %c has more than one head", ErrInvalidASCIIBoard, symbol)
+				}
+				s.head = &engine.Point{X: x, Y: y}
+			case !legacy && unicode.IsLower(symbol):
+				if findASCIIHeaderSnake(snakes, unicode.ToUpper(symbol)) == nil {
+					return nil, nil, fmt.Errorf("%w: snake %c at (%d,%d) is not declared in the header", ErrInvalidASCIIBoard, unicode.ToUpper(symbol), x, y)
+				}
+			default:
+				return nil, nil, fmt.Errorf("%w: unrecognized symbol %q at (%d,%d)", ErrInvalidASCIIBoard, symbol, x, y)
+			}
+		}
+	}
+
+	visited := make(map[engine.Point]bool)
+	for _, s := range snakes {
+		if s.head == nil {
+			return nil, nil, fmt.Errorf("%w: snake %s has no head", ErrInvalidASCIIBoard, s.snake.ID)
+		}
+		s.snake.Body = traceASCIISnake(*s.head, symbols, visited, s.isSegment, s.isTail)
+		// a snake that's only a head is stacked on itself, like snakes are at the start of a game
+		if len(s.snake.Body) == 1 {
+			s.snake.Body = []engine.Point{*s.head, *s.head, *s.head}
+		}
+		gameFrame.Snakes = append(gameFrame.Snakes, s.snake)
+	}
+
+	// every body segment should belong to a snake
+	for p, symbol := range symbols {
+		if visited[p] {
+			continue
+		}
+		if (legacy && (string(symbol) == ASCIISnakeBody || string(symbol) == ASCIISnakeTail)) || (!legacy && unicode.IsLower(symbol)) {
+			return nil, nil, fmt.Errorf("%w: body segment at (%d,%d) is not connected to a head", ErrInvalidASCIIBoard, p.X, p.Y)
+		}
+	}
+
+	return game, gameFrame, nil
+}
+
+func newASCIIHeaderSnake(letter rune, id, color, name string) *asciiSnake {
+	if color == "" {
+		color = ASCIIDefaultSnakeColors[int(letter-'A')%len(ASCIIDefaultSnakeColors)]
+	}
+	if name == "" {
+		name = id
+	}
+	lower := unicode.ToLower(letter)
+	return &asciiSnake{
+		snake:     engine.Snake{ID: id, Name: name, Color: color, Health: 100},
+		isSegment: func(symbol rune) bool { return symbol == lower },
+		isTail:    func(symbol rune) bool { return false },
+	}
+}
+
+func newASCIILegacySnake(i int) *asciiSnake {
+	id := fmt.Sprintf("snake%d", i+1)
+	return &asciiSnake{
+		snake: engine.Snake{ID: id, Name: id, Color: ASCIIDefaultSnakeColors[i%len(ASCIIDefaultSnakeColors)], Health: 100},
+		isSegment: func(symbol rune) bool {
+			return string(symbol) == ASCIISnakeBody || string(symbol) == ASCIISnakeTail
+		},
+		isTail: func(symbol rune) bool { return string(symbol) == ASCIISnakeTail },
+	}
+}
+
+func findASCIIHeaderSnake(snakes []*asciiSnake, letter rune) *asciiSnake {
+	for _, s := range snakes {
+		if s.isSegment(unicode.ToLower(letter)) {
+			return s
+		}
+	}
+	return nil
+}
+
+// traceASCIISnake builds the body of a snake by following adjacent segments from the head.
+// Visited points are recorded so that segments can't be shared by multiple snakes.
+func traceASCIISnake(head engine.Point, symbols map[engine.Point]rune, visited map[engine.Point]bool, isSegment, isTail func(rune) bool) []engine.Point {
+	body := []engine.Point{head}
+	visited[head] = true
+
+	// up, down, left, right
+	steps := []engine.Point{{X: 0, Y: 1}, {X: 0, Y: -1}, {X: -1, Y: 0}, {X: 1, Y: 0}}
+
+	current := head
+	var direction *engine.Point
+	for {
+		candidates := steps
+		if direction != nil {
+			candidates = append([]engine.Point{*direction}, steps...)
+		}
+
+		var next *engine.Point
+		var nextStep engine.Point
+		for _, tailPass := range []bool{false, true} {
+			for _, step := range candidates {
+				p := engine.Point{X: current.X + step.X, Y: current.Y + step.Y}
+				symbol, ok := symbols[p]
+				if !ok || visited[p] || !isSegment(symbol) || isTail(symbol) != tailPass {
+					continue
+				}
+				next, nextStep = &p, step
+				break
+			}
+			if next != nil {
+				break
+			}
+		}
+
+		if next == nil {
+			return body
+		}
+
+		visited[*next] = true
+		body = append(body, *next)
+		if isTail(symbols[*next]) {
+			return body
+		}
+		current = *next
+		direction = &nextStep
+	}
+}
@@ -0,0 +1,130 @@
+package render
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/BattlesnakeOfficial/exporter/engine"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestASCIIToGameFrame(t *testing.T) {
+	board := `
+turn: 7
+A: gs_one #ff0000 Snake One
+B: gs_two
+
+-------
+|*    |
+| aaA |
+| a  .|
+| a bB|
+|   b |
+-------
+`
+	g, gf, err := ASCIIToGameFrame(strings.NewReader(board))
+	require.NoError(t, err)
+
+	assert.Equal(t, 5, g.Width)
+	assert.Equal(t, 5, g.Height)
+	assert.Equal(t, 7, gf.Turn)
+	assert.Equal(t, []engine.Point{{X: 0, Y: 4}}, gf.Food)
+	assert.Equal(t, []engine.Point{{X: 4, Y: 2}}, gf.Hazards)
+
+	require.Len(t, gf.Snakes, 2)
+	assert.Equal(t, "gs_one", gf.Snakes[0].ID)
+	assert.Equal(t, "Snake One", gf.Snakes[0].Name)
+	assert.Equal(t, "#ff0000", gf.Snakes[0].Color)
+	assert.Equal(t, 100, gf.Snakes[0].Health)
+	assert.Equal(t, []engine.Point{{X: 3, Y: 3}, {X: 2, Y: 3}, {X: 1, Y: 3}, {X: 1, Y: 2}, {X: 1, Y: 1}}, gf.Snakes[0].Body)
+
+	// name and colour default when they aren't in the header
+	assert.Equal(t, "gs_two", gf.Snakes[1].ID)
+	assert.Equal(t, "gs_two", gf.Snakes[1].Name)
+	assert.Equal(t, ASCIIDefaultSnakeColors[1], gf.Snakes[1].Color)
+	assert.Equal(t, []engine.Point{{X: 4, Y: 1}, {X: 3, Y: 1}, {X: 3, Y: 0}}, gf.Snakes[1].Body)
+}
+
+func TestASCIIToGameFrame_HeadOnly(t *testing.T) {
+	// snakes that are only a head are stacked, like at the start of a game
+	_, gf, err := ASCIIToGameFrame(strings.NewReader("-----\n| H |\n-----\n"))
+	require.NoError(t, err)
+	require.Len(t, gf.Snakes, 1)
+	assert.Equal(t, []engine.Point{{X: 1, Y: 0}, {X: 1, Y: 0}, {X: 1, Y: 0}}, gf.Snakes[0].Body)
+}
+
+func TestASCIIToGameFrame_Ordering(t *testing.T) {
+	// the segment straight ahead is preferred over turning
+	g, gf, err := ASCIIToGameFrame(strings.NewReader(`
+A: snake
+-----
+|aa |
+|aaa|
+|  A|
+-----
+`))
+	require.NoError(t, err)
+	require.Equal(t, 3, g.Width)
+	assert.Equal(t, []engine.Point{{X: 2, Y: 0}, {X: 2, Y: 1}, {X: 1, Y: 1}, {X: 0, Y: 1}, {X: 0, Y: 2}, {X: 1, Y: 2}}, gf.Snakes[0].Body)
+}
+
+func TestASCIIToGameFrame_RoundTrip(t *testing.T) {
+	g := &engine.Game{Width: 5, Height: 4}
+	gf := &engine.GameFrame{
+		Food:    []engine.Point{{X: 0, Y: 0}, {X: 4, Y: 3}},
+		Hazards: []engine.Point{{X: 2, Y: 3}},
+		Snakes: []engine.Snake{
+			{Body: []engine.Point{{X: 1, Y: 1}, {X: 1, Y: 2}, {X: 2, Y: 2}, {X: 3, Y: 2}, {X: 3, Y: 1}}},
+			{Body: []engine.Point{{X: 4, Y: 0}, {X: 3, Y: 0}, {X: 2, Y: 0}}},
+		},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, GameFrameToASCII(&buf, g, gf))
+
+	parsedGame, parsedFrame, err := ASCIIToGameFrame(strings.NewReader(buf.String()))
+	require.NoError(t, err)
+	assert.Equal(t, g.Width, parsedGame.Width)
+	assert.Equal(t, g.Height, parsedGame.Height)
+	assert.ElementsMatch(t, gf.Food, parsedFrame.Food)
+	assert.Equal(t, gf.Hazards, parsedFrame.Hazards)
+
+	// snakes are found from top to bottom
+	require.Len(t, parsedFrame.Snakes, 2)
+	assert.Equal(t, "snake1", parsedFrame.Snakes[0].ID)
+	assert.Equal(t, gf.Snakes[0].Body, parsedFrame.Snakes[0].Body)
+	assert.Equal(t, "snake2", parsedFrame.Snakes[1].ID)
+	assert.Equal(t, gf.Snakes[1].Body, parsedFrame.Snakes[1].Body)
+
+	// writing the parsed board should give the same output
+	var roundTrip bytes.Buffer
+	require.NoError(t, GameFrameToASCII(&roundTrip, parsedGame, parsedFrame))
+	assert.Equal(t, buf.String(), roundTrip.String())
+}
+
+func TestASCIIToGameFrame_Invalid(t *testing.T) {
+	for name, board := range map[string]string{
+		"no border":          "|  |\n|  |\n",
+		"no rows":            "----\n----\n",
+		"uneven rows":        "----\n|  |\n|   |\n----\n",
+		"missing pipe":       "----\n|  |\n   |\n----\n",
+		"unknown symbol":     "----\n| ? |\n----\n",
+		"unknown header":     "hello\n----\n|  |\n----\n",
+		"undeclared snake":   "A: one\n----\n|Bb|\n----\n",
+		"duplicate snake":    "A: one\nA: two\n----\n|Aa|\n----\n",
+		"two heads":          "A: one\n----\n|AA|\n----\n",
+		"no head":            "A: one\n----\n|aa|\n----\n",
+		"disconnected body":  "A: one\n-----\n|Aa a|\n-----\n",
+		"content after":      "----\n|  |\n----\nextra\n",
+		"legacy no head":     "----\n|OT|\n----\n",
+		"legacy header mix":  "A: one\n----\n|HO|\n----\n",
+		"uppercase only gap": "A: one\nB: two\n----\n|A B|\n|b  |\n----\n",
+		"too wide":           "----\n|" + strings.Repeat(" ", engine.MaxBoardSize+1) + "|\n----\n",
+		"too tall":           "----\n" + strings.Repeat("| |\n", engine.MaxBoardSize+1) + "----\n",
+	} {
+		_, _, err := ASCIIToGameFrame(strings.NewReader(board))
+		assert.ErrorIs(t, err, ErrInvalidASCIIBoard, name)
+	}
+}
@@ -68,7 +68,8 @@ func AvatarSVG(settings AvatarSettings) (string, error) {
 
 	t := template.Must(template.New("avatar").Parse(avatarTemplate))
 
-	re := regexp.MustCompile(`^<svg ([^>]*)>\s*`)
+	// the SVGs are sanitized when they're fetched, so the root svg element is always first, and ">" is escaped in its attributes
+	re := regexp.MustCompile(`^<svg(?:\s[^>]*)?>\s*`)
 	settings.HeadSVG = re.ReplaceAllString(settings.HeadSVG, "")
 	settings.TailSVG = re.ReplaceAllString(settings.TailSVG, "")
 
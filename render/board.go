@@ -57,11 +57,17 @@ type BoardSquareContentType int
 type BoardSquareContent struct {
 	Type      BoardSquareContentType
 	Color     color.Color
+	SnakeID   string
 	SnakeType string
 	Direction snakeDirection
 	Corner    snakeCorner
 }
 
+// isSnake checks whether the content is part of a snake.
+func (c BoardSquareContent) isSnake() bool {
+	return c.Type == BoardSquareSnakeHead || c.Type == BoardSquareSnakeBody || c.Type == BoardSquareSnakeTail
+}
+
 // BoardSquare represents a unique location on the game board.
 type BoardSquare struct {
 	Contents []BoardSquareContent
@@ -71,7 +77,10 @@ type BoardSquare struct {
 type Board struct {
 	Width   int
 	Height  int
+	Turn    int
 	squares map[engine.Point]*BoardSquare
+	// lastHeads are where the snakes in the frame last had their heads, including dead snakes that are no longer drawn.
+	lastHeads map[string]engine.Point
 }
 
 // getSquare gets the BoardSquare at the given coordinates.
@@ -92,10 +101,10 @@ func (b *Board) addContent(p *engine.Point, c BoardSquareContent) {
 	s.Contents = append(s.Contents, c)
 }
 
-// getContents gets the contents of the board at the specified position.
+// Contents gets the contents of the board at the specified position.
 // It is safe to call for any position.
 // Empty squares will have an empty list.
-func (b Board) getContents(x, y int) []BoardSquareContent {
+func (b Board) Contents(x, y int) []BoardSquareContent {
 	s := b.getSquare(x, y)
 	if s == nil {
 		return nil
@@ -143,7 +152,7 @@ func (b *Board) addFood(p *engine.Point) {
 	})
 }
 
-func (b *Board) addSnakeTail(p *engine.Point, c color.Color, snakeType string, direction snakeDirection) {
+func (b *Board) addSnakeTail(p *engine.Point, c color.Color, snakeID, snakeType string, direction snakeDirection) {
 	// when a snake eats and grows, the tail is placed on the same square as a body
 	// this makes sure we remove the body segment if that condition is hit
 	b.removeIfExists(p.X, p.Y, BoardSquareSnakeBody)
@@ -151,24 +160,27 @@ func (b *Board) addSnakeTail(p *engine.Point, c color.Color, snakeType string, d
 	b.addContent(p, BoardSquareContent{
 		Type:      BoardSquareSnakeTail,
 		Color:     c,
+		SnakeID:   snakeID,
 		SnakeType: snakeType,
 		Direction: direction,
 	})
 }
 
-func (b *Board) addSnakeHead(p *engine.Point, c color.Color, snakeType string, dir snakeDirection) {
+func (b *Board) addSnakeHead(p *engine.Point, c color.Color, snakeID, snakeType string, dir snakeDirection) {
 	b.addContent(p, BoardSquareContent{
 		Type:      BoardSquareSnakeHead,
 		Color:     c,
+		SnakeID:   snakeID,
 		SnakeType: snakeType,
 		Direction: dir,
 	})
 }
 
-func (b *Board) addSnakeBody(p *engine.Point, c color.Color, dir snakeDirection, corner snakeCorner) {
+func (b *Board) addSnakeBody(p *engine.Point, c color.Color, snakeID string, dir snakeDirection, corner snakeCorner) {
 	b.addContent(p, BoardSquareContent{
 		Type:      BoardSquareSnakeBody,
 		Color:     c,
+		SnakeID:   snakeID,
 		Direction: dir,
 		Corner:    corner,
 	})
@@ -312,7 +324,12 @@ func (b *Board) placeSnake(snake engine.Snake) {
 				continue
 			}
 
-			b.addSnakeHead(&point, color, head, getDirection(snake.Body[i+1], point))
+			// snakes from other sources may only have a head, which faces right
+			direction := movingRight
+			if len(snake.Body) > 1 {
+				direction = getDirection(snake.Body[i+1], point)
+			}
+			b.addSnakeHead(&point, color, snake.ID, head, direction)
 			continue
 		}
 
@@ -327,22 +344,29 @@ func (b *Board) placeSnake(snake engine.Snake) {
 			if prev.X == point.X && prev.Y == point.Y {
 				direction = getDirection(snake.Body[i-2], point)
 			}
-			b.addSnakeTail(&point, color, tail, direction)
+			b.addSnakeTail(&point, color, snake.ID, tail, direction)
 		} else {
 			direction := getDirection(snake.Body[i+1], point)
 			corner := getCorner(snake.Body[i-1], point, snake.Body[i+1])
-			b.addSnakeBody(&point, color, direction, corner)
+			b.addSnakeBody(&point, color, snake.ID, direction, corner)
 		}
 	}
 }
 
 func NewBoard(w int, h int) *Board {
-	b := Board{Width: w, Height: h, squares: make(map[engine.Point]*BoardSquare)}
+	b := Board{Width: w, Height: h, squares: make(map[engine.Point]*BoardSquare), lastHeads: make(map[string]engine.Point)}
 	return &b
 }
 
 func GameFrameToBoard(g *engine.Game, gf *engine.GameFrame) *Board {
 	board := NewBoard(g.Width, g.Height)
+	board.Turn = gf.Turn
+
+	for _, snake := range gf.Snakes {
+		if len(snake.Body) > 0 {
+			board.lastHeads[snake.ID] = snake.Body[0]
+		}
+	}
 
 	// First place dead snakes (up to 10 turns after death)
 	for _, snake := range gf.Snakes {
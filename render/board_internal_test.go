@@ -95,20 +95,20 @@ func TestBoard(t *testing.T) {
 	}
 
 	// ensure adding content works
-	b.addSnakeTail(&engine.Point{X: 0, Y: 0}, parse.HexColor("#0acc33"), "regular", movingRight)
-	assert.Equal(t, BoardSquareSnakeTail, b.getContents(0, 0)[0].Type, "(0,0) should have tail content")
+	b.addSnakeTail(&engine.Point{X: 0, Y: 0}, parse.HexColor("#0acc33"), "snake", "regular", movingRight)
+	assert.Equal(t, BoardSquareSnakeTail, b.Contents(0, 0)[0].Type, "(0,0) should have tail content")
 
-	b.addSnakeBody(&engine.Point{X: 1, Y: 0}, parse.HexColor("#0acc33"), movingRight, "none")
-	assert.Equal(t, BoardSquareSnakeBody, b.getContents(1, 0)[0].Type, "(1,0) should have body content")
+	b.addSnakeBody(&engine.Point{X: 1, Y: 0}, parse.HexColor("#0acc33"), "snake", movingRight, "none")
+	assert.Equal(t, BoardSquareSnakeBody, b.Contents(1, 0)[0].Type, "(1,0) should have body content")
 
-	b.addSnakeHead(&engine.Point{X: 2, Y: 0}, parse.HexColor("#0acc33"), "regular", movingRight)
-	assert.Equal(t, BoardSquareSnakeHead, b.getContents(2, 0)[0].Type, "(2,0) should have head content")
+	b.addSnakeHead(&engine.Point{X: 2, Y: 0}, parse.HexColor("#0acc33"), "snake", "regular", movingRight)
+	assert.Equal(t, BoardSquareSnakeHead, b.Contents(2, 0)[0].Type, "(2,0) should have head content")
 
 	b.addFood(&engine.Point{X: 3, Y: 0})
-	assert.Equal(t, BoardSquareFood, b.getContents(3, 0)[0].Type, "(3,0) should have food content")
+	assert.Equal(t, BoardSquareFood, b.Contents(3, 0)[0].Type, "(3,0) should have food content")
 
 	b.addHazard(&engine.Point{X: 3, Y: 0})
-	assert.Equal(t, BoardSquareHazard, b.getContents(3, 0)[1].Type, "(3,0) should ALSO have hazard content")
+	assert.Equal(t, BoardSquareHazard, b.Contents(3, 0)[1].Type, "(3,0) should ALSO have hazard content")
 }
 
 func TestPlaceSnake(t *testing.T) {
@@ -129,7 +129,7 @@ func TestPlaceSnake(t *testing.T) {
 	b.placeSnake(s)
 
 	// HEAD
-	c := b.getContents(0, 0)
+	c := b.Contents(0, 0)
 	require.Len(t, c, 1, "there should only be a head here")
 	assert.Equal(t, BoardSquareSnakeHead, c[0].Type, "this should be a head")
 	assert.Equal(t, movingDown, c[0].Direction, "the head should be pointing down")
@@ -137,14 +137,14 @@ func TestPlaceSnake(t *testing.T) {
 	assert.Equal(t, "beluga", c[0].SnakeType, "the head should be customised")
 
 	// BODY
-	c = b.getContents(0, 1)
+	c = b.Contents(0, 1)
 	require.Len(t, c, 1, "there should only be a body here")
 	assert.Equal(t, BoardSquareSnakeBody, c[0].Type, "this should be a body")
 	assert.Equal(t, parse.HexColor("#3B194D"), c[0].Color, "the body should have the snake colour")
 	assert.Equal(t, "", c[0].SnakeType, "the body should not have a customization")
 
 	// TAIL
-	c = b.getContents(1, 1)
+	c = b.Contents(1, 1)
 	require.Len(t, c, 1, "there should only be a tail here")
 	assert.Equal(t, BoardSquareSnakeTail, c[0].Type, "this should be a tail")
 	assert.Equal(t, movingRight, c[0].Direction, "the tail should be pointing right")
@@ -164,7 +164,7 @@ func TestPlaceSnake(t *testing.T) {
 	}
 	b.placeSnake(s)
 
-	c = b.getContents(5, 9)
+	c = b.Contents(5, 9)
 	require.Len(t, c, 1, "there should only be a head here")
 	assert.Equal(t, BoardSquareSnakeHead, c[0].Type, "this should be a head")
 	assert.Equal(t, movingUp, c[0].Direction, "the head should be pointing up")
@@ -172,14 +172,14 @@ func TestPlaceSnake(t *testing.T) {
 	assert.Equal(t, "default", c[0].SnakeType, "the head should be default")
 
 	// BODY
-	c = b.getContents(5, 8)
+	c = b.Contents(5, 8)
 	require.Len(t, c, 1, "there should only be a body here")
 	assert.Equal(t, BoardSquareSnakeBody, c[0].Type, "this should be a body")
 	assert.Equal(t, parse.HexColor(ColorDeadSnake), c[0].Color, "the body should have the dead snake colour")
 	assert.Equal(t, "", c[0].SnakeType, "the body should not have a customization")
 
 	// TAIL
-	c = b.getContents(4, 8)
+	c = b.Contents(4, 8)
 	require.Len(t, c, 1, "there should only be a tail here")
 	assert.Equal(t, BoardSquareSnakeTail, c[0].Type, "this should be a tail")
 	assert.Equal(t, movingLeft, c[0].Direction, "the tail should be pointing left")
@@ -208,8 +208,19 @@ func TestGrowingSnakePlacement(t *testing.T) {
 	}
 	b.placeSnake(s)
 
-	require.Len(t, b.getContents(9, 9), 1, "the snake tail should replace the body")
-	require.Equal(t, BoardSquareSnakeTail, b.getContents(9, 9)[0].Type, "the snake tail should replace the body")
+	require.Len(t, b.Contents(9, 9), 1, "the snake tail should replace the body")
+	require.Equal(t, BoardSquareSnakeTail, b.Contents(9, 9)[0].Type, "the snake tail should replace the body")
+}
+
+func TestPlaceSnake_HeadOnly(t *testing.T) {
+	b := NewBoard(3, 3)
+
+	// snakes that only have a head are drawn as a head facing right
+	b.placeSnake(engine.Snake{ID: "snake", Color: "#ff0000", Body: []engine.Point{{X: 1, Y: 1}}})
+	c := b.Contents(1, 1)
+	require.Len(t, c, 1)
+	assert.Equal(t, BoardSquareSnakeHead, c[0].Type)
+	assert.Equal(t, movingRight, c[0].Direction)
 }
 
 func TestRemoveIfExists(t *testing.T) {
@@ -220,23 +231,23 @@ func TestRemoveIfExists(t *testing.T) {
 	b.removeIfExists(0, 0, BoardSquareSnakeBody)
 
 	// ensure a non-matching type doesn't get removed
-	require.Len(t, b.getContents(0, 0), 0)
-	b.addSnakeBody(&engine.Point{X: 0, Y: 0}, nil, movingUp, cornerBottomLeft)
-	require.Len(t, b.getContents(0, 0), 1)
+	require.Len(t, b.Contents(0, 0), 0)
+	b.addSnakeBody(&engine.Point{X: 0, Y: 0}, nil, "", movingUp, cornerBottomLeft)
+	require.Len(t, b.Contents(0, 0), 1)
 	b.removeIfExists(0, 0, BoardSquareFood)
-	require.Len(t, b.getContents(0, 0), 1)
+	require.Len(t, b.Contents(0, 0), 1)
 
 	// ensure that a matching type gets removed
 	b.removeIfExists(0, 0, BoardSquareSnakeBody)
-	require.Len(t, b.getContents(0, 0), 0)
+	require.Len(t, b.Contents(0, 0), 0)
 
 	// ensure that removal works okay when there is more than one content
-	b.addSnakeBody(&engine.Point{X: 0, Y: 0}, nil, movingUp, cornerBottomLeft)
+	b.addSnakeBody(&engine.Point{X: 0, Y: 0}, nil, "", movingUp, cornerBottomLeft)
 	b.addHazard(&engine.Point{X: 0, Y: 0})
-	require.Len(t, b.getContents(0, 0), 2)
+	require.Len(t, b.Contents(0, 0), 2)
 	b.removeIfExists(0, 0, BoardSquareSnakeHead)
-	require.Len(t, b.getContents(0, 0), 2, "shouldn't change when removing something that doesnt exist")
+	require.Len(t, b.Contents(0, 0), 2, "shouldn't change when removing something that doesnt exist")
 	b.removeIfExists(0, 0, BoardSquareSnakeBody)
-	require.Len(t, b.getContents(0, 0), 1, "body should be gone now")
-	require.Equal(t, BoardSquareHazard, b.getContents(0, 0)[0].Type, "just hazard should be left")
+	require.Len(t, b.Contents(0, 0), 1, "body should be gone now")
+	require.Equal(t, BoardSquareHazard, b.Contents(0, 0)[0].Type, "just hazard should be left")
 }
@@ -41,28 +41,28 @@ func TestGameFrameToBoard(t *testing.T) {
 	assert.Len(t, b.squares, 1+2+2+7) // 1 food, snake of 2, snake of 2, hazard of 7
 
 	// food
-	assert.Len(t, b.getContents(3, 3), 1, "food should be placed")
-	assert.Equal(t, BoardSquareFood, b.getContents(3, 3)[0].Type)
+	assert.Len(t, b.Contents(3, 3), 1, "food should be placed")
+	assert.Equal(t, BoardSquareFood, b.Contents(3, 3)[0].Type)
 
 	// expired dead snake
-	assert.Len(t, b.getContents(2, 0), 0, "expired snake shouldn't get placed")
-	assert.Len(t, b.getContents(2, 1), 0, "expired snake shouldn't get placed")
+	assert.Len(t, b.Contents(2, 0), 0, "expired snake shouldn't get placed")
+	assert.Len(t, b.Contents(2, 1), 0, "expired snake shouldn't get placed")
 
 	// non expired, dead snake
-	require.Len(t, b.getContents(4, 2), 1, "recently dead snake should be placed")
-	assert.Equal(t, BoardSquareSnakeHead, b.getContents(4, 2)[0].Type)
-	require.Len(t, b.getContents(3, 2), 1, "recently dead snake should be placed")
-	assert.Equal(t, BoardSquareSnakeTail, b.getContents(3, 2)[0].Type)
+	require.Len(t, b.Contents(4, 2), 1, "recently dead snake should be placed")
+	assert.Equal(t, BoardSquareSnakeHead, b.Contents(4, 2)[0].Type)
+	require.Len(t, b.Contents(3, 2), 1, "recently dead snake should be placed")
+	assert.Equal(t, BoardSquareSnakeTail, b.Contents(3, 2)[0].Type)
 
 	// alive snake
-	require.Len(t, b.getContents(0, 0), 1, "alive snake should be placed")
-	assert.Equal(t, BoardSquareSnakeHead, b.getContents(0, 0)[0].Type)
-	require.Len(t, b.getContents(0, 1), 1, "alive snake should be placed")
-	assert.Equal(t, BoardSquareSnakeTail, b.getContents(0, 1)[0].Type)
+	require.Len(t, b.Contents(0, 0), 1, "alive snake should be placed")
+	assert.Equal(t, BoardSquareSnakeHead, b.Contents(0, 0)[0].Type)
+	require.Len(t, b.Contents(0, 1), 1, "alive snake should be placed")
+	assert.Equal(t, BoardSquareSnakeTail, b.Contents(0, 1)[0].Type)
 
 	// hazard line
 	for i := 0; i < 7; i++ {
-		require.Len(t, b.getContents(i, 6), 1, "hazard (%d,6) should exist", i)
-		assert.Equal(t, BoardSquareHazard, b.getContents(i, 6)[0].Type)
+		require.Len(t, b.Contents(i, 6), 1, "hazard (%d,6) should exist", i)
+		assert.Equal(t, BoardSquareHazard, b.Contents(i, 6)[0].Type)
 	}
 }
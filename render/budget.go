@@ -0,0 +1,239 @@
+package render
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"slices"
+
+	"github.com/BattlesnakeOfficial/exporter/engine"
+)
+
+const (
+	// budgetSampleFrames is how many of the first frames are rendered to estimate the size of a GIF.
+	budgetSampleFrames = 12
+	// budgetMaxFrameStep is the most frames that get combined into one when skipping frames.
+	budgetMaxFrameStep = 8
+
+	// gifMaxDelay is the longest delay that fits in a GIF frame, which merging frames can't go over.
+	gifMaxDelay = 0xffff
+)
+
+var ErrGIFTooLarge = errors.New("GIF is too large")
+
+// AnimatedGIFPlan describes how to render an animated GIF, after it has been adapted to fit a size budget.
+type AnimatedGIFPlan struct {
+	GameFrames []*engine.GameFrame
+	// Delays are how long each frame is shown for, in hundredths of a second.
+	Delays []int
+	// Width and height of the GIF, in pixels (0 for the default size).
+	Width  int
+	Height int
+	// FrameStep is how many frames were combined into each frame (1 if no frames were skipped).
+	FrameStep int
+	// MergedFrames is how many duplicate frames were merged into the frame before them.
+	MergedFrames int
+	// EstimatedBytes is the estimated size of the encoded GIF.
+	EstimatedBytes int
+}
+
+// Encode renders the planned GIF.
+func (p AnimatedGIFPlan) Encode(ctx context.Context, w io.Writer, g *engine.Game, settings DrawSettings) error {
+	return GameFramesToAnimatedGIFWithDelays(ctx, w, g, p.GameFrames, p.Delays, p.Width, p.Height, settings)
+}
+
+// FitAnimatedGIF plans an animated GIF that is estimated to be no larger than maxBytes.
+// Each frame is shown for its delay (in hundredths of a second).
+// The size is estimated by rendering the first few frames. To fit within the budget, duplicate frames
+// are merged, then each of the sizes is tried in order (so they should go from largest to smallest),
+// and finally frames are skipped with the smallest size. Skipped frames are added to the delay of
+// the frame before them, so the GIF plays for the same amount of time.
+func FitAnimatedGIF(ctx context.Context, g *engine.Game, gameFrames []*engine.GameFrame, delays []int, sizes []image.Point, settings DrawSettings, maxBytes int) (AnimatedGIFPlan, error) {
+	if len(sizes) == 0 {
+		return AnimatedGIFPlan{}, errors.New("at least one size is required")
+	}
+	if len(delays) != len(gameFrames) {
+		return AnimatedGIFPlan{}, fmt.Errorf("mismatched frame and delay lengths: %d frames and %d delays", len(gameFrames), len(delays))
+	}
+
+	frames, delays, merged := mergeDuplicateFrames(gameFrames, delays, settings)
+
+	// the sample is only rendered once, at the largest size, and scaled for the other sizes and frame steps
+	sample, err := sampleAnimatedGIF(ctx, g, frames, delays, sizes[0].X, sizes[0].Y, settings)
+	if err != nil {
+		return AnimatedGIFPlan{}, err
+	}
+
+	plan := AnimatedGIFPlan{MergedFrames: merged, FrameStep: 1}
+	try := func(size image.Point, step int) bool {
+		plan.GameFrames, plan.Delays = stepFrames(frames, delays, step)
+		plan.Width, plan.Height, plan.FrameStep = size.X, size.Y, step
+		plan.EstimatedBytes = sample.estimate(len(plan.GameFrames), size.X*size.Y)
+		return plan.EstimatedBytes <= maxBytes
+	}
+
+	for _, size := range sizes {
+		if try(size, 1) {
+			return plan, nil
+		}
+	}
+
+	smallest := sizes[len(sizes)-1]
+	for step := 2; step <= budgetMaxFrameStep && step < len(frames); step++ {
+		if try(smallest, step) {
+			return plan, nil
+		}
+	}
+
+	return plan, fmt.Errorf("%w: the smallest GIF is estimated to be %d bytes, which is more than %d bytes", ErrGIFTooLarge, plan.EstimatedBytes, maxBytes)
+}
+
+// gifSample is the encoded size of the first few frames of an animated GIF, which is scaled to estimate the size of other GIFs of the same game.
+// The first frame is the whole board and later frames only contain what changed, so they're measured separately.
+type gifSample struct {
+	// first is the size of a GIF of only the first frame, and perFrame is the average size of each frame after it.
+	first    float64
+	perFrame float64
+	// pixels is how many pixels the sampled GIF has.
+	pixels int
+}
+
+// sampleAnimatedGIF renders the first few frames of an animated GIF to measure how big it is.
+func sampleAnimatedGIF(ctx context.Context, g *engine.Game, gameFrames []*engine.GameFrame, delays []int, width, height int, settings DrawSettings) (gifSample, error) {
+	n := min(len(gameFrames), budgetSampleFrames)
+	if n == 0 {
+		return gifSample{}, nil
+	}
+
+	first := &countingWriter{}
+	if err := GameFramesToAnimatedGIFWithDelays(ctx, first, g, gameFrames[:1], delays[:1], width, height, settings); err != nil {
+		return gifSample{}, err
+	}
+	// the default size depends on the board, so the size is read from the GIF
+	sample := gifSample{first: float64(first.n), pixels: first.pixels()}
+	if n == 1 {
+		return sample, nil
+	}
+
+	frames := &countingWriter{}
+	if err := GameFramesToAnimatedGIFWithDelays(ctx, frames, g, gameFrames[:n], delays[:n], width, height, settings); err != nil {
+		return gifSample{}, err
+	}
+	sample.perFrame = float64(frames.n-first.n) / float64(n-1)
+	return sample, nil
+}
+
+// estimate scales the sample to a GIF with the given number of frames and pixels.
+// Zero pixels is the default size, which is only known when it's the size that was sampled.
+func (s gifSample) estimate(numFrames, pixels int) int {
+	if numFrames == 0 {
+		return 0
+	}
+	// boards are mostly flat colours, which compress well, so the size grows with the width rather than the number of pixels
+	scale := 1.0
+	if pixels > 0 && s.pixels > 0 {
+		scale = math.Sqrt(float64(pixels) / float64(s.pixels))
+	}
+	return int(scale * (s.first + s.perFrame*float64(numFrames-1)))
+}
+
+// mergeDuplicateFrames removes frames that look the same as the frame before them,
+// adding their delays to the frame that's kept. It returns how many frames were removed.
+func mergeDuplicateFrames(gameFrames []*engine.GameFrame, delays []int, settings DrawSettings) ([]*engine.GameFrame, []int, int) {
+	// the turn number is drawn, so every frame looks different
+	if settings.hasOverlay("turn") {
+		return gameFrames, delays, 0
+	}
+
+	var frames []*engine.GameFrame
+	var merged []int
+	for i, gf := range gameFrames {
+		if i > 0 && sameBoardState(gameFrames[i-1], gf) {
+			merged[len(merged)-1] = addDelays(merged[len(merged)-1], delays[i])
+			continue
+		}
+		frames = append(frames, gf)
+		merged = append(merged, delays[i])
+	}
+	return frames, merged, len(gameFrames) - len(frames)
+}
+
+// sameBoardState checks whether two frames have the same contents, ignoring the turn.
+// Only the parts of snakes that change during a game are compared.
+func sameBoardState(a, b *engine.GameFrame) bool {
+	if !slices.Equal(a.Food, b.Food) || !slices.Equal(a.Hazards, b.Hazards) || len(a.Snakes) != len(b.Snakes) {
+		return false
+	}
+	for i := range a.Snakes {
+		if !sameSnakeState(a.Snakes[i], b.Snakes[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func sameSnakeState(a, b engine.Snake) bool {
+	if a.Health != b.Health || !slices.Equal(a.Body, b.Body) {
+		return false
+	}
+	if a.Death == nil || b.Death == nil {
+		return a.Death == b.Death
+	}
+	return *a.Death == *b.Death
+}
+
+// stepFrames keeps every step-th frame, plus the last frame.
+// Skipped frames are added to the delay of the frame before them.
+func stepFrames(gameFrames []*engine.GameFrame, delays []int, step int) ([]*engine.GameFrame, []int) {
+	if step <= 1 {
+		return gameFrames, delays
+	}
+
+	var frames []*engine.GameFrame
+	var stepped []int
+	for i, gf := range gameFrames {
+		if i%step == 0 || i == len(gameFrames)-1 {
+			frames = append(frames, gf)
+			stepped = append(stepped, delays[i])
+			continue
+		}
+		stepped[len(stepped)-1] = addDelays(stepped[len(stepped)-1], delays[i])
+	}
+	return frames, stepped
+}
+
+// addDelays combines the delays of frames, up to the longest delay a GIF frame can have.
+func addDelays(a, b int) int {
+	return min(a+b, gifMaxDelay)
+}
+
+// countingWriter counts the bytes written to a GIF, and discards them apart from the header.
+type countingWriter struct {
+	n      int
+	header []byte
+}
+
+// gifHeaderSize is the size of a GIF's header, up to the end of its width and height.
+const gifHeaderSize = 10
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	if len(w.header) < gifHeaderSize {
+		w.header = append(w.header, p[:min(len(p), gifHeaderSize-len(w.header))]...)
+	}
+	w.n += len(p)
+	return len(p), nil
+}
+
+// pixels reads the width and height from the GIF's header, and returns how many pixels it has.
+func (w *countingWriter) pixels() int {
+	if len(w.header) < gifHeaderSize {
+		return 0
+	}
+	width := binary.LittleEndian.Uint16(w.header[6:8])
+	height := binary.LittleEndian.Uint16(w.header[8:10])
+	return int(width) * int(height)
+}
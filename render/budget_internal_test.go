@@ -0,0 +1,169 @@
+package render
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"testing"
+
+	"github.com/BattlesnakeOfficial/exporter/engine"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// movingSnakeFrames creates a game where a snake moves around the edge of the board.
+func movingSnakeFrames(numFrames int) (*engine.Game, []*engine.GameFrame) {
+	g := &engine.Game{Width: 11, Height: 11}
+	var path []engine.Point
+	for x := 0; x < 10; x++ {
+		path = append(path, engine.Point{X: x, Y: 0})
+	}
+	for y := 0; y < 10; y++ {
+		path = append(path, engine.Point{X: 10, Y: y})
+	}
+	for x := 10; x > 0; x-- {
+		path = append(path, engine.Point{X: x, Y: 10})
+	}
+	for y := 10; y > 0; y-- {
+		path = append(path, engine.Point{X: 0, Y: y})
+	}
+
+	var gameFrames []*engine.GameFrame
+	for turn := 0; turn < numFrames; turn++ {
+		var body []engine.Point
+		for i := 0; i < 5; i++ {
+			body = append(body, path[(turn+len(path)-i)%len(path)])
+		}
+		gameFrames = append(gameFrames, &engine.GameFrame{
+			Turn:   turn,
+			Food:   []engine.Point{{X: 5, Y: 5}},
+			Snakes: []engine.Snake{{ID: "snake", Color: "#ff0000", Body: body}},
+		})
+	}
+	return g, gameFrames
+}
+
+func TestMergeDuplicateFrames(t *testing.T) {
+	_, gameFrames := movingSnakeFrames(3)
+	duplicate := *gameFrames[1]
+	duplicate.Turn = 7
+	gameFrames = []*engine.GameFrame{gameFrames[0], gameFrames[1], &duplicate, &duplicate, gameFrames[2]}
+
+	frames, delays, merged := mergeDuplicateFrames(gameFrames, []int{1, 2, 3, 4, 5}, DrawSettings{})
+	assert.Equal(t, []*engine.GameFrame{gameFrames[0], gameFrames[1], gameFrames[4]}, frames)
+	assert.Equal(t, []int{1, 9, 5}, delays)
+	assert.Equal(t, 2, merged)
+
+	// the turn overlay makes every frame different
+	frames, delays, merged = mergeDuplicateFrames(gameFrames, []int{1, 2, 3, 4, 5}, DrawSettings{Overlays: []string{"turn"}})
+	assert.Len(t, frames, 5)
+	assert.Equal(t, []int{1, 2, 3, 4, 5}, delays)
+	assert.Equal(t, 0, merged)
+}
+
+func TestMergeDuplicateFrames_MaxDelay(t *testing.T) {
+	_, gameFrames := movingSnakeFrames(1)
+	gameFrames = []*engine.GameFrame{gameFrames[0], gameFrames[0], gameFrames[0]}
+
+	// merged delays still fit in a GIF frame
+	_, delays, _ := mergeDuplicateFrames(gameFrames, []int{40000, 40000, 40000}, DrawSettings{})
+	assert.Equal(t, []int{gifMaxDelay}, delays)
+}
+
+func TestSameBoardState(t *testing.T) {
+	_, gameFrames := movingSnakeFrames(2)
+	changed := func(change func(s *engine.Snake)) *engine.GameFrame {
+		gf := *gameFrames[0]
+		gf.Snakes = []engine.Snake{gameFrames[0].Snakes[0]}
+		change(&gf.Snakes[0])
+		return &gf
+	}
+
+	assert.True(t, sameBoardState(gameFrames[0], gameFrames[0]))
+	assert.False(t, sameBoardState(gameFrames[0], gameFrames[1]))
+
+	// only the parts of snakes that are drawn differently are compared
+	assert.True(t, sameBoardState(gameFrames[0], changed(func(s *engine.Snake) { s.Name = "renamed" })))
+	assert.False(t, sameBoardState(gameFrames[0], changed(func(s *engine.Snake) { s.Health = 50 })))
+	assert.False(t, sameBoardState(gameFrames[0], changed(func(s *engine.Snake) { s.Death = &engine.Death{Cause: "wall-collision", Turn: 1} })))
+	dead := changed(func(s *engine.Snake) { s.Death = &engine.Death{Cause: "wall-collision", Turn: 1} })
+	assert.True(t, sameBoardState(dead, changed(func(s *engine.Snake) { s.Death = &engine.Death{Cause: "wall-collision", Turn: 1} })))
+}
+
+func TestStepFrames(t *testing.T) {
+	_, gameFrames := movingSnakeFrames(7)
+	delays := []int{1, 1, 1, 1, 1, 1, 10}
+
+	frames, stepped := stepFrames(gameFrames, delays, 1)
+	assert.Equal(t, gameFrames, frames)
+	assert.Equal(t, delays, stepped)
+
+	// the last frame is always kept, and the total delay doesn't change
+	frames, stepped = stepFrames(gameFrames, delays, 4)
+	assert.Equal(t, []*engine.GameFrame{gameFrames[0], gameFrames[4], gameFrames[6]}, frames)
+	assert.Equal(t, []int{4, 2, 10}, stepped)
+}
+
+func TestFitAnimatedGIF(t *testing.T) {
+	g, gameFrames := movingSnakeFrames(60)
+	delays := uniformDelays(len(gameFrames), GIFFrameDelay, GIFLoopDelay)
+	sizes := []image.Point{{X: 444, Y: 444}, {X: 334, Y: 334}, {X: 224, Y: 224}, {X: 114, Y: 114}}
+
+	// a large budget doesn't change anything
+	plan, err := FitAnimatedGIF(context.Background(), g, gameFrames, delays, sizes, DrawSettings{}, 100_000_000)
+	require.NoError(t, err)
+	assert.Equal(t, 444, plan.Width)
+	assert.Equal(t, 1, plan.FrameStep)
+	assert.Len(t, plan.GameFrames, 60)
+
+	// the estimate should be close to the real size
+	var buf bytes.Buffer
+	require.NoError(t, plan.Encode(context.Background(), &buf, g, DrawSettings{}))
+	assert.InEpsilon(t, buf.Len(), plan.EstimatedBytes, 0.2)
+
+	// a smaller budget uses a smaller size
+	plan, err = FitAnimatedGIF(context.Background(), g, gameFrames, delays, sizes, DrawSettings{}, buf.Len()/2)
+	require.NoError(t, err)
+	assert.Less(t, plan.Width, 444)
+	assert.LessOrEqual(t, plan.EstimatedBytes, buf.Len()/2)
+
+	// frames are skipped once the smallest size is too big
+	smallest, err := FitAnimatedGIF(context.Background(), g, gameFrames, delays, sizes[3:], DrawSettings{}, 100_000_000)
+	require.NoError(t, err)
+	plan, err = FitAnimatedGIF(context.Background(), g, gameFrames, delays, sizes, DrawSettings{}, smallest.EstimatedBytes*2/3)
+	require.NoError(t, err)
+	assert.Equal(t, 114, plan.Width)
+	assert.Greater(t, plan.FrameStep, 1)
+	assert.Less(t, len(plan.GameFrames), 60)
+
+	// the sample is only rendered once, so the default size is read from it
+	plan, err = FitAnimatedGIF(context.Background(), g, gameFrames, delays, []image.Point{{}, {X: 114, Y: 114}}, DrawSettings{}, 100_000_000)
+	require.NoError(t, err)
+	assert.Equal(t, 0, plan.Width)
+	buf.Reset()
+	require.NoError(t, plan.Encode(context.Background(), &buf, g, DrawSettings{}))
+	assert.InEpsilon(t, buf.Len(), plan.EstimatedBytes, 0.2)
+
+	// some budgets are impossible
+	_, err = FitAnimatedGIF(context.Background(), g, gameFrames, delays, sizes, DrawSettings{}, 100)
+	assert.ErrorIs(t, err, ErrGIFTooLarge)
+}
+
+func TestGIFSampleEstimate(t *testing.T) {
+	sample := gifSample{first: 1000, perFrame: 100, pixels: 400 * 400}
+	assert.Equal(t, 0, sample.estimate(0, 400*400))
+	assert.Equal(t, 1000, sample.estimate(1, 400*400))
+	assert.Equal(t, 1900, sample.estimate(10, 400*400))
+
+	// the size scales with the width, and the default size is the sampled size
+	assert.Equal(t, 950, sample.estimate(10, 200*200))
+	assert.Equal(t, 1900, sample.estimate(10, 0))
+}
+
+func TestCountingWriter(t *testing.T) {
+	g, gameFrames := movingSnakeFrames(1)
+	w := &countingWriter{}
+	require.NoError(t, GameFrameToGIF(context.Background(), w, g, gameFrames[0], 114, 224, DrawSettings{}))
+	assert.Greater(t, w.n, gifHeaderSize)
+	assert.Equal(t, 114*224, w.pixels())
+}
@@ -0,0 +1,52 @@
+package render
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"image"
+
+	"github.com/BattlesnakeOfficial/exporter/media"
+	"github.com/patrickmn/go-cache"
+)
+
+var ErrInvalidDirection = errors.New("invalid direction")
+
+// snakeDirections maps the names of directions to the directions snakes can face on the board.
+var snakeDirections = map[string]snakeDirection{
+	"up":    movingUp,
+	"down":  movingDown,
+	"left":  movingLeft,
+	"right": movingRight,
+}
+
+// CustomizationPNG rasterizes a customized head or tail SVG, which faces right, and rotates it to face
+// the direction ("up", "down", "left" or "right") the same way as snakes are drawn on the board.
+// An empty direction leaves it facing right. The images are cached, so that popular customizations are only rasterized once.
+func CustomizationPNG(ctx context.Context, svg string, size int, direction string) (image.Image, error) {
+	dir := movingRight
+	if direction != "" {
+		var ok bool
+		if dir, ok = snakeDirections[direction]; !ok {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidDirection, direction)
+		}
+	}
+
+	// the SVG is already customized, so its hash covers the colour and whether it's flipped
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(svg))
+	key := fmt.Sprintf("customization:%x:%d:%d", h.Sum64(), size, dir)
+	if cached, ok := imageCache.Get(key); ok {
+		return cached.(image.Image), nil
+	}
+
+	img, err := media.ConvertSVGStringToPNG(ctx, svg, size, size)
+	if err != nil {
+		return nil, err
+	}
+	img = rotateImage(img, directionRotation(dir))
+
+	imageCache.Set(key, img, cache.DefaultExpiration)
+	return img, nil
+}
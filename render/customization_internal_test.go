@@ -0,0 +1,53 @@
+package render
+
+import (
+	"context"
+	"testing"
+
+	"github.com/BattlesnakeOfficial/exporter/media"
+	"github.com/stretchr/testify/require"
+)
+
+// halfSVG fills the left half of the image, so that it's possible to tell which way it faces
+const halfSVG = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 100 100" fill="#ff0000"><rect width="50" height="100"/></svg>`
+
+func TestCustomizationPNG(t *testing.T) {
+	require.NoError(t, media.SetRasterizer(media.RasterizerGo))
+	defer func() { _ = media.SetRasterizer(media.RasterizerAuto) }()
+
+	for _, test := range []struct {
+		direction string
+		filled    [2]int
+		empty     [2]int
+	}{
+		{"", [2]int{10, 50}, [2]int{90, 50}},
+		{"right", [2]int{10, 50}, [2]int{90, 50}},
+		{"left", [2]int{90, 50}, [2]int{10, 50}},
+		{"up", [2]int{50, 90}, [2]int{50, 10}},
+		{"down", [2]int{50, 10}, [2]int{50, 90}},
+	} {
+		img, err := CustomizationPNG(context.Background(), halfSVG, 100, test.direction)
+		require.NoError(t, err, test.direction)
+		require.Equal(t, 100, img.Bounds().Dx())
+		require.Equal(t, 100, img.Bounds().Dy())
+
+		_, _, _, a := img.At(test.filled[0], test.filled[1]).RGBA()
+		require.Equal(t, uint32(0xffff), a, test.direction)
+		_, _, _, a = img.At(test.empty[0], test.empty[1]).RGBA()
+		require.Equal(t, uint32(0), a, test.direction)
+	}
+
+	// cached images don't need rasterizing again, even if the context is done
+	first, err := CustomizationPNG(context.Background(), halfSVG, 40, "up")
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	second, err := CustomizationPNG(ctx, halfSVG, 40, "up")
+	require.NoError(t, err)
+	require.Equal(t, first, second)
+	_, err = CustomizationPNG(ctx, halfSVG, 40, "down")
+	require.Error(t, err)
+
+	_, err = CustomizationPNG(context.Background(), halfSVG, 100, "sideways")
+	require.ErrorIs(t, err, ErrInvalidDirection)
+}
@@ -0,0 +1,68 @@
+package render
+
+import (
+	"github.com/BattlesnakeOfficial/exporter/engine"
+)
+
+// gameEventType is something notable that happened between two turns.
+type gameEventType string
+
+const (
+	eventDeath     gameEventType = "death"
+	eventFoodEaten gameEventType = "food-eaten"
+	// eventHeadToHead is a snake dying because it collided head-on with another snake
+	eventHeadToHead gameEventType = "head-to-head"
+)
+
+// deathCauseHeadCollision is the cause the engine gives for snakes that die in a head-to-head collision.
+const deathCauseHeadCollision = "head-collision"
+
+// gameEvent is something notable that happened to a snake between two turns.
+type gameEvent struct {
+	Type    gameEventType
+	SnakeID string
+}
+
+// frameEvents finds the events that happened between the previous frame and the current frame,
+// by comparing the state of each snake.
+func frameEvents(prev, cur *engine.GameFrame) []gameEvent {
+	var events []gameEvent
+
+	food := make(map[engine.Point]bool, len(prev.Food))
+	for _, p := range prev.Food {
+		food[p] = true
+	}
+
+	prevSnakes := make(map[string]engine.Snake, len(prev.Snakes))
+	for _, snake := range prev.Snakes {
+		prevSnakes[snake.ID] = snake
+	}
+
+	// heads of the snakes that were alive last turn, to find head-on collisions
+	heads := make(map[engine.Point]int)
+	for _, snake := range cur.Snakes {
+		if before, ok := prevSnakes[snake.ID]; ok && before.Death == nil && len(snake.Body) > 0 {
+			heads[snake.Body[0]]++
+		}
+	}
+
+	for _, snake := range cur.Snakes {
+		before, ok := prevSnakes[snake.ID]
+		if !ok || before.Death != nil {
+			continue
+		}
+		if snake.Death != nil {
+			if snake.Death.Cause == deathCauseHeadCollision || (len(snake.Body) > 0 && heads[snake.Body[0]] > 1) {
+				events = append(events, gameEvent{Type: eventHeadToHead, SnakeID: snake.ID})
+			} else {
+				events = append(events, gameEvent{Type: eventDeath, SnakeID: snake.ID})
+			}
+			continue
+		}
+		if len(snake.Body) > 0 && food[snake.Body[0]] {
+			events = append(events, gameEvent{Type: eventFoodEaten, SnakeID: snake.ID})
+		}
+	}
+
+	return events
+}
@@ -0,0 +1,135 @@
+package render
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"hash/fnv"
+	"image"
+	"image/color"
+	"math"
+	"text/template"
+
+	"github.com/BattlesnakeOfficial/exporter/media"
+	"github.com/fogleman/gg"
+	"github.com/patrickmn/go-cache"
+)
+
+const (
+	// GalleryTileSize is the size of each customization in a gallery, in pixels.
+	GalleryTileSize = 100
+	galleryPadding  = 10
+	galleryLabel    = 20
+)
+
+// GalleryTile is a customization previewed in a gallery.
+type GalleryTile struct {
+	Label string
+	// SVG is the customization, already customized with its colour.
+	SVG string
+}
+
+// gallery lays out tiles in a grid that's roughly square.
+type gallery struct {
+	Tiles   []GalleryTile
+	Columns int
+	Rows    int
+}
+
+func newGallery(tiles []GalleryTile) gallery {
+	columns := int(math.Ceil(math.Sqrt(float64(len(tiles)))))
+	if columns == 0 {
+		return gallery{}
+	}
+	rows := (len(tiles) + columns - 1) / columns
+	return gallery{Tiles: tiles, Columns: columns, Rows: rows}
+}
+
+func (g gallery) Width() int {
+	return galleryPadding + g.Columns*(GalleryTileSize+galleryPadding)
+}
+
+func (g gallery) Height() int {
+	return galleryPadding + g.Rows*(GalleryTileSize+galleryLabel+galleryPadding)
+}
+
+// tileOffset is where the tile at index i goes.
+func (g gallery) tileOffset(i int) (int, int) {
+	x := galleryPadding + (i%g.Columns)*(GalleryTileSize+galleryPadding)
+	y := galleryPadding + (i/g.Columns)*(GalleryTileSize+galleryLabel+galleryPadding)
+	return x, y
+}
+
+func (g gallery) TileX(i int) int {
+	x, _ := g.tileOffset(i)
+	return x
+}
+
+func (g gallery) TileY(i int) int {
+	_, y := g.tileOffset(i)
+	return y
+}
+
+func (g gallery) LabelX(i int) int {
+	return g.TileX(i) + GalleryTileSize/2
+}
+
+func (g gallery) LabelY(i int) int {
+	return g.TileY(i) + GalleryTileSize + galleryLabel - 5
+}
+
+// each tile is embedded as a data URI rather than inline, so that IDs in different customizations can't clash
+const galleryTemplate = `<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" width="{{ .Width }}" height="{{ .Height }}">
+	<rect width="100%" height="100%" fill="#ffffff" />
+	{{- range $i, $tile := .Tiles }}
+	<image x="{{ $.TileX $i }}" y="{{ $.TileY $i }}" width="{{ tileSize }}" height="{{ tileSize }}" xlink:href="data:image/svg+xml;base64,{{ dataURI $tile.SVG }}" />
+	<text x="{{ $.LabelX $i }}" y="{{ $.LabelY $i }}" text-anchor="middle" font-family="sans-serif" font-size="12" fill="#333333">{{ html $tile.Label }}</text>
+	{{- end }}
+</svg>`
+
+var galleryTmpl = template.Must(template.New("gallery").Funcs(template.FuncMap{
+	"tileSize": func() int { return GalleryTileSize },
+	"dataURI":  func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) },
+}).Parse(galleryTemplate))
+
+// GallerySVG lays out customizations in a labelled grid.
+func GallerySVG(tiles []GalleryTile) string {
+	buf := &bytes.Buffer{}
+	_ = galleryTmpl.Execute(buf, newGallery(tiles))
+	return buf.String()
+}
+
+// GalleryPNG lays out customizations in a labelled grid, the same as GallerySVG.
+// The images are cached, so that a gallery is only rasterized once.
+func GalleryPNG(ctx context.Context, tiles []GalleryTile) (image.Image, error) {
+	// the SVGs are already customized, so the hash covers their colours too
+	h := fnv.New64a()
+	for _, tile := range tiles {
+		fmt.Fprintf(h, "%q:%q;", tile.Label, tile.SVG)
+	}
+	key := fmt.Sprintf("gallery:%x", h.Sum64())
+	if cached, ok := imageCache.Get(key); ok {
+		return cached.(image.Image), nil
+	}
+
+	g := newGallery(tiles)
+	dc := gg.NewContext(g.Width(), g.Height())
+	dc.SetColor(color.White)
+	dc.Clear()
+
+	for i, tile := range tiles {
+		img, err := media.ConvertSVGStringToPNG(ctx, tile.SVG, GalleryTileSize, GalleryTileSize)
+		if err != nil {
+			return nil, err
+		}
+		dc.DrawImage(img, g.TileX(i), g.TileY(i))
+
+		dc.SetColor(color.RGBA{0x33, 0x33, 0x33, 0xff})
+		dc.DrawStringAnchored(tile.Label, float64(g.LabelX(i)), float64(g.LabelY(i)), 0.5, 0)
+	}
+
+	img := dc.Image()
+	imageCache.Set(key, img, cache.DefaultExpiration)
+	return img, nil
+}
@@ -0,0 +1,73 @@
+package render
+
+import (
+	"context"
+	"encoding/base64"
+	"image/color"
+	"strings"
+	"testing"
+
+	"github.com/BattlesnakeOfficial/exporter/media"
+	"github.com/stretchr/testify/require"
+)
+
+const gallerySquareSVG = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 100 100" fill="#ff0000"><rect width="100" height="100"/></svg>`
+
+func TestGalleryLayout(t *testing.T) {
+	g := newGallery(make([]GalleryTile, 5))
+	require.Equal(t, 3, g.Columns)
+	require.Equal(t, 2, g.Rows)
+	require.Equal(t, 340, g.Width())
+	require.Equal(t, 270, g.Height())
+
+	x, y := g.tileOffset(4)
+	require.Equal(t, 120, x)
+	require.Equal(t, 140, y)
+
+	require.Equal(t, 0, newGallery(nil).Columns)
+}
+
+func TestGallerySVG(t *testing.T) {
+	svg := GallerySVG([]GalleryTile{
+		{Label: "beluga", SVG: gallerySquareSVG},
+		{Label: "<bolt>", SVG: gallerySquareSVG},
+	})
+
+	require.True(t, strings.HasPrefix(svg, `<svg xmlns="http://www.w3.org/2000/svg"`))
+	require.Contains(t, svg, `width="230" height="140"`)
+	require.Equal(t, 2, strings.Count(svg, base64.StdEncoding.EncodeToString([]byte(gallerySquareSVG))))
+	require.Contains(t, svg, ">beluga</text>")
+	require.Contains(t, svg, ">&lt;bolt&gt;</text>")
+}
+
+func TestGalleryPNG(t *testing.T) {
+	require.NoError(t, media.SetRasterizer(media.RasterizerGo))
+	defer func() { _ = media.SetRasterizer(media.RasterizerAuto) }()
+
+	img, err := GalleryPNG(context.Background(), []GalleryTile{
+		{Label: "beluga", SVG: gallerySquareSVG},
+		{Label: "bolt", SVG: gallerySquareSVG},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 230, img.Bounds().Dx())
+	require.Equal(t, 140, img.Bounds().Dy())
+
+	// tiles are drawn on a white background
+	r, g, b, _ := img.At(60, 60).RGBA()
+	require.Equal(t, color.RGBA{0xff, 0, 0, 0xff}, color.RGBA{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8), 0xff})
+	r, g, b, _ = img.At(5, 5).RGBA()
+	require.Equal(t, color.RGBA{0xff, 0xff, 0xff, 0xff}, color.RGBA{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8), 0xff})
+
+	// the same gallery is only rasterized once
+	cached, err := GalleryPNG(context.Background(), []GalleryTile{
+		{Label: "beluga", SVG: gallerySquareSVG},
+		{Label: "bolt", SVG: gallerySquareSVG},
+	})
+	require.NoError(t, err)
+	require.Same(t, img, cached)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = GalleryPNG(ctx, []GalleryTile{{Label: "cancelled", SVG: gallerySquareSVG}})
+	require.Error(t, err)
+}
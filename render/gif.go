@@ -1,12 +1,14 @@
 package render
 
 import (
+	"context"
 	"fmt"
 	"image"
 	"image/color"
 	"image/draw"
 	"io"
 	"runtime"
+	"sync"
 	"time"
 
 	"github.com/BattlesnakeOfficial/exporter/engine"
@@ -21,13 +23,18 @@ const (
 	GIFMaxColorsPerFrame = 256
 )
 
-func gameFrameToPalettedImage(g *engine.Game, gf *engine.GameFrame, w, h int) *image.Paletted {
+// gameFrameToPalettedImage draws a game frame and converts it to a paletted image.
+// If a game palette is given, the frame is mapped to it. Otherwise, a palette is chosen for this frame alone.
+func gameFrameToPalettedImage(ctx context.Context, g *engine.Game, gf *engine.GameFrame, w, h int, settings DrawSettings, gp *gamePalette) *image.Paletted {
 	board := GameFrameToBoard(g, gf)
 
 	// This is where the bulk of GIF creation CPU is spent.
 	// First, Board is rendered to RGBA Image
 	// Second, RGBA Image converted to Paletted Image (lossy)
-	rgbaImage := DrawBoard(board, w, h)
+	rgbaImage := DrawBoard(ctx, board, w, h, settings)
+	if gp != nil {
+		return gp.paletted(rgbaImage)
+	}
 	q := quantize.MedianCutQuantizer{}
 	p := q.Quantize(make([]color.Color, 0, 256), rgbaImage)
 	palettedImage := image.NewPaletted(rgbaImage.Bounds(), p)
@@ -40,37 +47,67 @@ func gameFrameToPalettedImage(g *engine.Game, gf *engine.GameFrame, w, h int) *i
 	return palettedImage
 }
 
-func GameFrameToGIF(w io.Writer, g *engine.Game, gf *engine.GameFrame, width, height int) error {
-	i := gameFrameToPalettedImage(g, gf, width, height)
-	err := gif.Encode(w, i, nil)
+func GameFrameToGIF(ctx context.Context, w io.Writer, g *engine.Game, gf *engine.GameFrame, width, height int, settings DrawSettings) error {
+	i := gameFrameToPalettedImage(ctx, g, gf, width, height, settings, nil)
+	err := gif.Encode(w, i, &gif.Options{Extensions: settings.Metadata.gifExtensions()})
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func GameFramesToAnimatedGIF(w io.Writer, g *engine.Game, gameFrames []*engine.GameFrame, frameDelay, loopDelay, width, height int) error {
+func GameFramesToAnimatedGIF(ctx context.Context, w io.Writer, g *engine.Game, gameFrames []*engine.GameFrame, frameDelay, loopDelay, width, height int, settings DrawSettings) error {
+	return GameFramesToAnimatedGIFWithDelays(ctx, w, g, gameFrames, uniformDelays(len(gameFrames), frameDelay, loopDelay), width, height, settings)
+}
+
+// uniformDelays creates the delays for frames that are all shown for the same time,
+// apart from the last frame, which is shown for longer before the animation loops.
+func uniformDelays(numFrames, frameDelay, loopDelay int) []int {
+	delays := make([]int, numFrames)
+	for i := range delays {
+		delays[i] = frameDelay
+	}
+	if numFrames > 0 {
+		delays[numFrames-1] = loopDelay
+	}
+	return delays
+}
+
+// GameFramesToAnimatedGIFWithDelays renders the game frames as an animated GIF, where each frame
+// is shown for its own delay (in hundredths of a second). There must be a delay for every frame.
+// Rendering stops as soon as the context is cancelled, and the context's error is returned.
+func GameFramesToAnimatedGIFWithDelays(ctx context.Context, w io.Writer, g *engine.Game, gameFrames []*engine.GameFrame, delays []int, width, height int, settings DrawSettings) error {
+	if len(delays) != len(gameFrames) {
+		return fmt.Errorf("mismatched frame and delay lengths: %d frames and %d delays", len(gameFrames), len(delays))
+	}
+
+	// rendering also stops if encoding fails, such as when the client goes away
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// every frame uses the same palette, which is written once as the global colour table
+	gp := newGamePalette(gameFrames, settings)
+
+	images := newFrameImages(gameFrames, func(gf *engine.GameFrame) *image.Paletted {
+		return gameFrameToPalettedImage(ctx, g, gf, width, height, settings, gp)
+	})
+
 	c := make(chan gif.GIFFrame)
 	go func() {
-		defer func() {
-			if r := recover(); r != nil {
-				err := recoverToError(r)
-				c <- gif.GIFFrame{
-					Error: err,
-				}
-			}
-		}()
+		defer close(c)
+
 		start := time.Now()
-		for i, gf := range gameFrames {
-			delay := frameDelay
-			if i == len(gameFrames)-1 {
-				delay = loopDelay
-			}
-			c <- gif.GIFFrame{
-				Image:    gameFrameToPalettedImage(g, gf, width, height),
+		renderFramesInOrder(ctx, len(gameFrames), func(i int) gif.GIFFrame {
+			return gif.GIFFrame{
+				Image:    images.get(i, gameFrames[i]),
 				FrameNum: i,
-				Delay:    delay,
+				Delay:    min(delays[i], gifMaxDelay),
 			}
+		}, c)
+
+		if ctx.Err() != nil {
+			log.WithField("game", g.ID).Info("GIF render cancelled")
+			return
 		}
 
 		elapsed := time.Since(start)
@@ -84,10 +121,62 @@ func GameFramesToAnimatedGIF(w io.Writer, g *engine.Game, gameFrames []*engine.G
 			"duration": elapsed,
 			"fps":      fps,
 		}).Infof("GIF render complete")
-
-		close(c)
 	}()
-	return gif.EncodeAllConcurrent(w, c)
+	return gif.EncodeAllConcurrent(ctx, w, c, gp.palette, settings.Metadata.gifExtensions())
+}
+
+// maxFrameImageReuse is the furthest apart two uses of the same frame can be for its image to be reused.
+// Frames shown again later than that are rendered again, so that only a few images are kept at once.
+const maxFrameImageReuse = 16
+
+// frameImages renders game frames that are shown more than once in an animation only once,
+// as long as they're shown again soon after. The image is kept until the frame is shown again.
+type frameImages struct {
+	render func(gf *engine.GameFrame) *image.Paletted
+	// nextUse is the position the frame at each position is next shown at, or -1 if it isn't shown again
+	nextUse []int
+
+	mu sync.Mutex
+	// kept are the images to reuse, by the position they'll be used at
+	kept map[int]*image.Paletted
+	// started are the positions whose image has already been asked for
+	started []bool
+}
+
+func newFrameImages(gameFrames []*engine.GameFrame, render func(gf *engine.GameFrame) *image.Paletted) *frameImages {
+	nextUse := make([]int, len(gameFrames))
+	last := make(map[*engine.GameFrame]int, len(gameFrames))
+	for i := len(gameFrames) - 1; i >= 0; i-- {
+		nextUse[i] = -1
+		if next, ok := last[gameFrames[i]]; ok {
+			nextUse[i] = next
+		}
+		last[gameFrames[i]] = i
+	}
+	return &frameImages{render: render, nextUse: nextUse, kept: make(map[int]*image.Paletted), started: make([]bool, len(gameFrames))}
+}
+
+// get renders the frame at position i, or reuses the image from an earlier use of the same frame.
+func (f *frameImages) get(i int, gf *engine.GameFrame) *image.Paletted {
+	f.mu.Lock()
+	f.started[i] = true
+	img, ok := f.kept[i]
+	delete(f.kept, i)
+	f.mu.Unlock()
+
+	if !ok {
+		img = f.render(gf)
+	}
+
+	if next := f.nextUse[i]; next >= 0 && next-i <= maxFrameImageReuse {
+		f.mu.Lock()
+		// frames are rendered in parallel, so the next use may have already rendered it
+		if !f.started[next] {
+			f.kept[next] = img
+		}
+		f.mu.Unlock()
+	}
+	return img
 }
 
 func recoverToError(panicArg interface{}) error {
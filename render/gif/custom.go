@@ -1,11 +1,15 @@
 // This is our custom encoder for streaming gif frames to the browser.
-// These are the only changes we've made to the standard image/gif.
+// These are the only changes we've made to the standard image/gif,
+// apart from splitting writeImageBlock so image data can be compressed ahead of time.
 
 package gif
 
 import (
 	"bufio"
+	"bytes"
+	"context"
 	"image"
+	"image/color"
 	"io"
 )
 
@@ -14,17 +18,73 @@ type GIFFrame struct {
 	FrameNum int
 	Delay    int
 	Error    error
+
+	// block is the part of Image that gets written, and data is its compressed image data.
+	// These are set by Compress.
+	block *image.Paletted
+	data  []byte
+}
+
+// Compress works out which part of the frame needs to be written, given the previous frame
+// (or nil for the first frame), and compresses its image data.
+// Frames can be compressed in parallel before they are sent to EncodeAllConcurrent,
+// otherwise they are compressed as they're written.
+func (f *GIFFrame) Compress(prev *image.Paletted) error {
+	f.block = f.Image
+	if prev != nil {
+		f.block = deltaFrame(prev, f.Image)
+	}
+
+	var buf bytes.Buffer
+	e := encoder{w: bufio.NewWriter(&buf)}
+	e.writeImageData(f.block)
+	e.flush()
+	if e.err != nil {
+		return e.err
+	}
+	f.data = buf.Bytes()
+	return nil
 }
 
-func EncodeAllConcurrent(w io.Writer, c chan GIFFrame) error {
-	g := &GIF{}
+// ApplicationExtension is custom data stored in a GIF by an application.
+type ApplicationExtension struct {
+	// Identifier names the application, as 8 bytes followed by a 3 byte authentication code (such as "NETSCAPE2.0").
+	Identifier string
+	Data       []byte
+}
+
+// Extensions are the comments and application data stored in a GIF, which don't affect how it's displayed.
+// The looping extension (NETSCAPE2.0) is handled separately, using LoopCount.
+type Extensions struct {
+	Comments     []string
+	Applications []ApplicationExtension
+}
+
+// EncodeAllConcurrent writes the frames to w as an animated GIF, as they arrive on the channel.
+// If a global palette is given, it's written as the global colour table. Frames that use
+// that same palette slice don't need their own local colour table.
+// The extensions are written after the header.
+// Encoding stops as soon as the context is cancelled, and the context's error is returned.
+func EncodeAllConcurrent(ctx context.Context, w io.Writer, c chan GIFFrame, globalPalette color.Palette, extensions Extensions) error {
+	g := &GIF{Extensions: extensions}
 
 	// This is a hack to trick the encoder into letting us loop the animation
 	// by making it think there's multiple images. Has no impact on frames rendered.
 	g.Image = []*image.Paletted{nil, nil}
 
 	e := encoder{g: *g, w: bufio.NewWriter(w)}
-	for f := range c {
+	var prev *image.Paletted
+	for {
+		var f GIFFrame
+		var ok bool
+		select {
+		case f, ok = <-c:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if !ok {
+			break
+		}
 		if f.Error != nil {
 			return f.Error
 		}
@@ -33,13 +93,108 @@ func EncodeAllConcurrent(w io.Writer, c chan GIFFrame) error {
 			p := f.Image.Bounds().Max
 			e.g.Config.Width = p.X
 			e.g.Config.Height = p.Y
+			if len(globalPalette) > 0 {
+				e.g.Config.ColorModel = globalPalette
+			}
 			e.writeHeader()
 		}
 
-		disposal := uint8(0)
-		e.writeImageBlock(f.Image, f.Delay, disposal)
+		// Frames are drawn on top of each other, so after the first frame
+		// only the pixels that changed since the previous frame need to be written.
+		if f.data == nil {
+			if err := f.Compress(prev); err != nil {
+				return err
+			}
+		}
+		e.writeImageBlockHeader(f.block, f.Delay, DisposalNone)
+		e.write(f.data)
+		if e.err != nil {
+			// there's no point rendering the rest of the frames if they can't be written
+			return e.err
+		}
+		prev = f.Image
+	}
+	// the frames stop early when the context is cancelled, so the GIF isn't complete
+	if err := ctx.Err(); err != nil {
+		return err
 	}
 	e.writeByte(sTrailer)
 	e.flush()
 	return e.err
 }
+
+// deltaFrame creates a sub-image of the current frame that covers only the pixels that
+// have a different colour from the previous frame. Unchanged pixels inside that region are
+// made transparent when the palette has room, so they compress well and the previous frame shows through.
+//
+// A single bounding rectangle is used rather than one block per changed region, because
+// browsers treat extra blocks with a zero delay as separate frames with a default delay.
+func deltaFrame(prev, cur *image.Paletted) *image.Paletted {
+	b := cur.Bounds()
+	if !prev.Bounds().Eq(b) {
+		return cur
+	}
+
+	prevColors := paletteRGBA(prev.Palette)
+	curColors := paletteRGBA(cur.Palette)
+	changed := func(x, y int) bool {
+		pi, ci := prev.Pix[prev.PixOffset(x, y)], cur.Pix[cur.PixOffset(x, y)]
+		if int(pi) >= len(prevColors) || int(ci) >= len(curColors) {
+			return true
+		}
+		return prevColors[pi] != curColors[ci]
+	}
+
+	minX, minY, maxX, maxY := b.Max.X, b.Max.Y, b.Min.X-1, b.Min.Y-1
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if !changed(x, y) {
+				continue
+			}
+			minX, maxX = min(minX, x), max(maxX, x)
+			minY, maxY = min(minY, y), max(maxY, y)
+		}
+	}
+
+	// image blocks need at least one pixel, even when nothing changed
+	rect := image.Rect(b.Min.X, b.Min.Y, b.Min.X+1, b.Min.Y+1)
+	if maxX >= minX {
+		rect = image.Rect(minX, minY, maxX+1, maxY+1)
+	}
+
+	// the encoder uses the first fully transparent palette entry as the transparent index
+	palette := cur.Palette
+	transparentIndex := -1
+	for i, c := range curColors {
+		if c.A == 0 {
+			transparentIndex = i
+			break
+		}
+	}
+	if transparentIndex == -1 && len(palette) < 256 {
+		palette = append(palette[:len(palette):len(palette)], color.RGBA{})
+		transparentIndex = len(palette) - 1
+	}
+
+	delta := image.NewPaletted(rect, palette)
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			i := delta.PixOffset(x, y)
+			if transparentIndex != -1 && !changed(x, y) {
+				delta.Pix[i] = uint8(transparentIndex)
+			} else {
+				delta.Pix[i] = cur.Pix[cur.PixOffset(x, y)]
+			}
+		}
+	}
+	return delta
+}
+
+// paletteRGBA resolves every colour in the palette, so that pixels can be compared by colour rather than by index.
+func paletteRGBA(p color.Palette) []color.RGBA64 {
+	colors := make([]color.RGBA64, len(p))
+	for i, c := range p {
+		colors[i] = color.RGBA64Model.Convert(c).(color.RGBA64)
+	}
+	return colors
+}
@@ -101,6 +101,7 @@ type decoder struct {
 
 	// Computed.
 	globalColorTable color.Palette
+	extensions       Extensions
 
 	// Used when decoding.
 	delay    []int
@@ -310,7 +311,12 @@ func (d *decoder) readExtension() error {
 	case eGraphicControl:
 		return d.readGraphicControl()
 	case eComment:
-		// nothing to do but read the data.
+		comment, err := d.readSubBlocks()
+		if err != nil {
+			return fmt.Errorf("gif: reading extension: %v", err)
+		}
+		d.extensions.Comments = append(d.extensions.Comments, string(comment))
+		return nil
 	case eApplication:
 		b, err := readByte(d.r)
 		if err != nil {
@@ -340,6 +346,14 @@ func (d *decoder) readExtension() error {
 		if n == 3 && d.tmp[0] == 1 {
 			d.loopCount = int(d.tmp[1]) | int(d.tmp[2])<<8
 		}
+	} else if extension == eApplication {
+		identifier := string(d.tmp[:size])
+		data, err := d.readSubBlocks()
+		if err != nil {
+			return fmt.Errorf("gif: reading extension: %v", err)
+		}
+		d.extensions.Applications = append(d.extensions.Applications, ApplicationExtension{Identifier: identifier, Data: data})
+		return nil
 	}
 	for {
 		n, err := d.readBlock()
@@ -352,6 +366,21 @@ func (d *decoder) readExtension() error {
 	}
 }
 
+// readSubBlocks reads the data sub-blocks of an extension, up to and including the block terminator.
+func (d *decoder) readSubBlocks() ([]byte, error) {
+	var data []byte
+	for {
+		n, err := d.readBlock()
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			return data, nil
+		}
+		data = append(data, d.tmp[:n]...)
+	}
+}
+
 func (d *decoder) readGraphicControl() error {
 	if err := readFull(d.r, d.tmp[:6]); err != nil {
 		return fmt.Errorf("gif: can't read graphic control: %s", err)
@@ -594,6 +623,8 @@ type GIF struct {
 	// BackgroundIndex is the background index in the global color table, for
 	// use with the DisposalBackground disposal method.
 	BackgroundIndex byte
+	// Extensions are the comments and application data stored in the GIF.
+	Extensions Extensions
 }
 
 // DecodeAll reads a GIF image from r and returns the sequential frames
@@ -614,6 +645,7 @@ func DecodeAll(r io.Reader) (*GIF, error) {
 			Height:     d.height,
 		},
 		BackgroundIndex: d.backgroundIndex,
+		Extensions:      d.extensions,
 	}
 	return gif, nil
 }
@@ -9,6 +9,7 @@ import (
 	"bytes"
 	"compress/lzw"
 	"errors"
+	"fmt"
 	"image"
 	"image/color"
 	"image/color/palette"
@@ -22,6 +23,9 @@ const (
 	gcBlockSize = 0x04
 )
 
+// applicationIdentifierSize is the size of an application extension's identifier and authentication code.
+const applicationIdentifierSize = 11
+
 var log2Lookup = [8]int{2, 4, 8, 16, 32, 64, 128, 256}
 
 func log2(x int) int {
@@ -194,6 +198,44 @@ func (e *encoder) writeHeader() {
 		e.buf[4] = 0x00 // Block Terminator.
 		e.write(e.buf[:5])
 	}
+
+	e.writeExtensions()
+}
+
+// writeExtensions writes the comment and application extensions.
+func (e *encoder) writeExtensions() {
+	for _, comment := range e.g.Extensions.Comments {
+		e.buf[0] = sExtension
+		e.buf[1] = eComment
+		e.write(e.buf[:2])
+		e.writeSubBlocks([]byte(comment))
+	}
+
+	for _, app := range e.g.Extensions.Applications {
+		if len(app.Identifier) != applicationIdentifierSize {
+			if e.err == nil {
+				e.err = fmt.Errorf("gif: application identifier %q must be %d bytes", app.Identifier, applicationIdentifierSize)
+			}
+			return
+		}
+		e.buf[0] = sExtension
+		e.buf[1] = eApplication
+		e.buf[2] = applicationIdentifierSize
+		e.write(e.buf[:3])
+		e.write([]byte(app.Identifier))
+		e.writeSubBlocks(app.Data)
+	}
+}
+
+// writeSubBlocks writes data as sub-blocks of up to 255 bytes, followed by the block terminator.
+func (e *encoder) writeSubBlocks(data []byte) {
+	for len(data) > 0 {
+		n := min(len(data), 255)
+		e.writeByte(byte(n))
+		e.write(data[:n])
+		data = data[n:]
+	}
+	e.writeByte(0x00) // Block Terminator.
 }
 
 func encodeColorTable(dst []byte, p color.Palette, size int) (int, error) {
@@ -239,6 +281,12 @@ func (e *encoder) colorTablesMatch(localLen, transparentIndex int) bool {
 }
 
 func (e *encoder) writeImageBlock(pm *image.Paletted, delay int, disposal byte) {
+	e.writeImageBlockHeader(pm, delay, disposal)
+	e.writeImageData(pm)
+}
+
+// writeImageBlockHeader writes everything in the image block that comes before the image data.
+func (e *encoder) writeImageBlockHeader(pm *image.Paletted, delay int, disposal byte) {
 	if e.err != nil {
 		return
 	}
@@ -323,7 +371,16 @@ func (e *encoder) writeImageBlock(pm *image.Paletted, delay int, disposal byte)
 			e.write(e.localColorTable[:ct])
 		}
 	}
+}
+
+// writeImageData writes the LZW compressed image data of the image block.
+func (e *encoder) writeImageData(pm *image.Paletted) {
+	if e.err != nil {
+		return
+	}
 
+	b := pm.Bounds()
+	paddedSize := log2(len(pm.Palette))
 	litWidth := paddedSize + 1
 	if litWidth < 2 {
 		litWidth = 2
@@ -365,6 +422,9 @@ type Options struct {
 	// Drawer is used to convert the source image to the desired palette.
 	// draw.FloydSteinberg is used in place of a nil Drawer.
 	Drawer draw.Drawer
+
+	// Extensions are the comments and application data to store in the GIF.
+	Extensions Extensions
 }
 
 // EncodeAll writes the images in g to w in GIF format with the
@@ -460,5 +520,6 @@ func Encode(w io.Writer, m image.Image, o *Options) error {
 			Width:      b.Dx(),
 			Height:     b.Dy(),
 		},
+		Extensions: opts.Extensions,
 	})
 }
@@ -0,0 +1,204 @@
+package render
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/BattlesnakeOfficial/exporter/engine"
+	"github.com/BattlesnakeOfficial/exporter/imagetest"
+	"github.com/BattlesnakeOfficial/exporter/parse"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func asciiGameFrames(t *testing.T, boards ...string) (*engine.Game, []*engine.GameFrame) {
+	var game *engine.Game
+	var gameFrames []*engine.GameFrame
+	for i, board := range boards {
+		g, gf, err := ASCIIToGameFrame(strings.NewReader(board))
+		require.NoError(t, err)
+		gf.Turn = i
+		game = g
+		gameFrames = append(gameFrames, gf)
+	}
+	return game, gameFrames
+}
+
+func TestAnimatedGIFGamePaletteFrames(t *testing.T) {
+	game, gameFrames := asciiGameFrames(t,
+		"-------\n|*    |\n|     |\n| OOH |\n|     |\n|  .  |\n-------\n",
+		"-------\n|*    |\n|     |\n|  OOH|\n|     |\n|  .  |\n-------\n",
+		"-------\n|*   H|\n|    O|\n|    O|\n|     |\n|  .  |\n-------\n",
+	)
+
+	var buf bytes.Buffer
+	err := GameFramesToAnimatedGIF(context.Background(), &buf, game, gameFrames, GIFFrameDelay, GIFLoopDelay, 0, 0, DrawSettings{})
+	require.NoError(t, err)
+	animated, err := gif.DecodeAll(&buf)
+	require.NoError(t, err)
+
+	// every frame uses the palette built for the game, rather than one quantized from the frame
+	gp := newGamePalette(gameFrames, DrawSettings{})
+	canvas := image.NewRGBA(image.Rect(0, 0, animated.Config.Width, animated.Config.Height))
+	for i, frame := range animated.Image {
+		assert.Equal(t, gp.palette, frame.Palette)
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+		imagetest.Equal(t, gameFrameToPalettedImage(context.Background(), game, gameFrames[i], 0, 0, DrawSettings{}, gp), canvas)
+	}
+}
+
+func TestAnimatedGIFBoomerang(t *testing.T) {
+	game, gameFrames := asciiGameFrames(t,
+		"-------\n|*    |\n|     |\n| OOH |\n|     |\n|  .  |\n-------\n",
+		"-------\n|*    |\n|     |\n|  OOH|\n|     |\n|  .  |\n-------\n",
+		"-------\n|*   H|\n|    O|\n|    O|\n|     |\n|  .  |\n-------\n",
+	)
+	frames, delays := Playback{FrameDelay: GIFFrameDelay, LoopDelay: GIFLoopDelay, Mode: PlaybackBoomerang}.Apply(gameFrames)
+	require.Equal(t, []*engine.GameFrame{gameFrames[0], gameFrames[1], gameFrames[2], gameFrames[1]}, frames)
+
+	var buf bytes.Buffer
+	err := GameFramesToAnimatedGIFWithDelays(context.Background(), &buf, game, frames, delays, 0, 0, DrawSettings{})
+	require.NoError(t, err)
+	animated, err := gif.DecodeAll(&buf)
+	require.NoError(t, err)
+	require.Len(t, animated.Image, len(frames))
+	require.Equal(t, delays, animated.Delay)
+
+	// frames going backward are deltas of the frame shown before them, not of the previous turn
+	gp := newGamePalette(gameFrames, DrawSettings{})
+	canvas := image.NewRGBA(image.Rect(0, 0, animated.Config.Width, animated.Config.Height))
+	for i, frame := range animated.Image {
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+		imagetest.Equal(t, gameFrameToPalettedImage(context.Background(), game, frames[i], 0, 0, DrawSettings{}, gp), canvas)
+	}
+}
+
+func TestAnimatedGIF_Cancelled(t *testing.T) {
+	game, gameFrames := movingSnakeFrames(50)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := GameFramesToAnimatedGIF(ctx, io.Discard, game, gameFrames, GIFFrameDelay, GIFLoopDelay, 0, 0, DrawSettings{})
+	require.ErrorIs(t, err, context.Canceled)
+
+	// encoding stops when the frames can't be written
+	errWrite := errors.New("client went away")
+	err = GameFramesToAnimatedGIF(context.Background(), failingWriter{errWrite}, game, gameFrames, GIFFrameDelay, GIFLoopDelay, 0, 0, DrawSettings{})
+	require.ErrorIs(t, err, errWrite)
+}
+
+type failingWriter struct {
+	err error
+}
+
+func (w failingWriter) Write(p []byte) (int, error) {
+	return 0, w.err
+}
+
+func TestFrameImages(t *testing.T) {
+	_, gameFrames := asciiGameFrames(t, "---\n|H|\n---\n", "---\n|*|\n---\n")
+	frames := []*engine.GameFrame{gameFrames[0], gameFrames[1], gameFrames[0], gameFrames[0]}
+
+	var renders atomic.Int32
+	images := newFrameImages(frames, func(gf *engine.GameFrame) *image.Paletted {
+		renders.Add(1)
+		return image.NewPaletted(image.Rect(0, 0, 1, 1), nil)
+	})
+	first := images.get(0, frames[0])
+	images.get(1, frames[1])
+	require.Same(t, first, images.get(2, frames[2]))
+	require.Equal(t, int32(2), renders.Load())
+
+	// the image is released after its last use
+	require.Same(t, first, images.get(3, frames[3]))
+	require.Empty(t, images.kept)
+}
+
+func TestFrameImages_Boomerang(t *testing.T) {
+	_, gameFrames := movingSnakeFrames(100)
+	frames, _ := Playback{Mode: PlaybackBoomerang}.Apply(gameFrames)
+
+	var renders int
+	images := newFrameImages(frames, func(gf *engine.GameFrame) *image.Paletted {
+		renders++
+		return image.NewPaletted(image.Rect(0, 0, 1, 1), nil)
+	})
+
+	// only frames that are shown again soon are kept, no matter how long the game is
+	peak := 0
+	for i, gf := range frames {
+		images.get(i, gf)
+		peak = max(peak, len(images.kept))
+	}
+	require.LessOrEqual(t, peak, maxFrameImageReuse)
+	require.Empty(t, images.kept)
+
+	// the frames either side of the turnaround are reused
+	require.Equal(t, len(frames)-maxFrameImageReuse/2, renders)
+}
+
+func TestAnimatedGIFGlobalPalette(t *testing.T) {
+	game, gameFrames := asciiGameFrames(t,
+		"A: one #123456\n------\n|Aaa*|\n|    |\n------\n",
+		"A: one #123456\n------\n| Aaa|\n|   .|\n------\n",
+	)
+
+	var buf bytes.Buffer
+	err := GameFramesToAnimatedGIF(context.Background(), &buf, game, gameFrames, GIFFrameDelay, GIFLoopDelay, 0, 0, DrawSettings{})
+	require.NoError(t, err)
+	animated, err := gif.DecodeAll(&buf)
+	require.NoError(t, err)
+
+	// the palette is written once, and shared by every frame
+	global, ok := animated.Config.ColorModel.(color.Palette)
+	require.True(t, ok)
+	for _, frame := range animated.Image {
+		assert.Equal(t, len(global), len(frame.Palette))
+	}
+
+	// solid colours are drawn exactly, such as the middle of the snake's body
+	assertSameColor(t, parse.HexColor("#123456"), animated.Image[0].At(32, 12))
+}
+
+func TestGamePalette(t *testing.T) {
+	gameFrames := []*engine.GameFrame{
+		{Snakes: []engine.Snake{{Color: "#ff0000"}, {Color: "#00ff00"}}},
+		{Snakes: []engine.Snake{{Color: "#ff0000"}, {Color: "#0000ff"}}},
+	}
+	gp := newGamePalette(gameFrames, DrawSettings{})
+
+	require.LessOrEqual(t, len(gp.palette), GIFMaxColorsPerFrame)
+	_, _, _, a := gp.palette[0].RGBA()
+	assert.Equal(t, uint32(0), a, "the first entry should be transparent")
+
+	for _, hex := range []string{"#ff0000", "#00ff00", "#0000ff", ColorFood, ColorDeadSnake, ColorEmptySquare} {
+		assert.Contains(t, gp.palette, color.RGBAModel.Convert(parse.HexColor(hex)), hex)
+	}
+	assert.Contains(t, gp.palette, color.RGBAModel.Convert(blendOver(parse.HexColor(ColorHazard), parse.HexColor(ColorEmptySquare))))
+
+	// focused games also need the faded colours
+	gp = newGamePalette(gameFrames, DrawSettings{Focus: "snake"})
+	assert.Contains(t, gp.palette, color.RGBAModel.Convert(fadeColor(parse.HexColor("#00ff00"))))
+
+	// transparent and opaque colours are never mapped to the transparent entry
+	img := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	img.Set(1, 0, color.RGBA{0xff, 0, 0, 0xff})
+	paletted := gp.paletted(img)
+	assert.NotEqual(t, uint8(0), paletted.Pix[0])
+	assert.Equal(t, color.RGBA{0xff, 0, 0, 0xff}, paletted.At(1, 0))
+}
+
+func TestBlendOver(t *testing.T) {
+	assert.Equal(t, color.RGBA{0x80, 0x80, 0x80, 0xff}, blendOver(color.RGBA{0, 0, 0, 0x7f}, color.White))
+	assert.Equal(t, color.White, blendOver(color.RGBA{}, color.White))
+	assert.Equal(t, color.RGBA{0x12, 0x34, 0x56, 0xff}, blendOver(color.RGBA{0x12, 0x34, 0x56, 0xff}, color.White))
+}
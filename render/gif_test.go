@@ -2,9 +2,14 @@ package render_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"image"
+	"image/color"
+	"image/draw"
 	"image/gif"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/BattlesnakeOfficial/exporter/engine"
@@ -30,13 +35,55 @@ func TestHappyPath(t *testing.T) {
 	var buf bytes.Buffer
 	game, frame := loadState(t)
 
-	err = render.GameFrameToGIF(&buf, game, frame, 0, 0)
+	err = render.GameFrameToGIF(context.Background(), &buf, game, frame, 0, 0, render.DrawSettings{})
 	require.NoError(t, err)
 	current, err := gif.Decode(&buf)
 	require.NoError(t, err)
 	imagetest.Equal(t, snapshot, current)
 }
 
+func TestAnimatedGIFDeltaFrames(t *testing.T) {
+	var gameFrames []*engine.GameFrame
+	var game *engine.Game
+	for _, board := range []string{
+		"-------\n|*    |\n|     |\n| OOH |\n|     |\n|  .  |\n-------\n",
+		"-------\n|*    |\n|     |\n|  OOH|\n|     |\n|  .  |\n-------\n",
+		"-------\n|*    |\n|     |\n|  OOH|\n|     |\n|  .  |\n-------\n", // nothing changes
+		"-------\n|*   H|\n|    O|\n|    O|\n|     |\n|  .  |\n-------\n",
+	} {
+		g, gf, err := render.ASCIIToGameFrame(strings.NewReader(board))
+		require.NoError(t, err)
+		game = g
+		gameFrames = append(gameFrames, gf)
+	}
+
+	var buf bytes.Buffer
+	err := render.GameFramesToAnimatedGIF(context.Background(), &buf, game, gameFrames, render.GIFFrameDelay, render.GIFLoopDelay, 0, 0, render.DrawSettings{})
+	require.NoError(t, err)
+	animated, err := gif.DecodeAll(&buf)
+	require.NoError(t, err)
+	require.Len(t, animated.Image, len(gameFrames))
+
+	canvas := image.NewRGBA(image.Rect(0, 0, animated.Config.Width, animated.Config.Height))
+	for i, frame := range animated.Image {
+		require.Equal(t, byte(gif.DisposalNone), animated.Disposal[i])
+		if i > 0 {
+			// only the changed region is written after the first frame
+			require.Less(t, frame.Bounds().Dx()*frame.Bounds().Dy(), canvas.Bounds().Dx()*canvas.Bounds().Dy())
+		}
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+
+		// the composited animation should match each frame rendered on its own, in the animation's palette
+		single := render.DrawBoard(context.Background(), render.GameFrameToBoard(game, gameFrames[i]), 0, 0, render.DrawSettings{})
+		expected := image.NewPaletted(single.Bounds(), animated.Config.ColorModel.(color.Palette))
+		draw.Draw(expected, expected.Bounds(), single, single.Bounds().Min, draw.Src)
+		imagetest.Equal(t, expected, canvas)
+	}
+
+	// unchanged frames are a single transparent pixel
+	require.Equal(t, 1, animated.Image[2].Bounds().Dx()*animated.Image[2].Bounds().Dy())
+}
+
 // generates the golden file, uncomment to regenerate
 // nolint: unused,deadcode
 func generateGoldenFile(t *testing.T, name string) {
@@ -44,7 +91,7 @@ func generateGoldenFile(t *testing.T, name string) {
 	f, err := os.Create(name)
 	require.NoError(t, err)
 	defer f.Close()
-	err = render.GameFrameToGIF(f, game, frame, 0, 0)
+	err = render.GameFrameToGIF(context.Background(), f, game, frame, 0, 0, render.DrawSettings{})
 	require.NoError(t, err)
 }
 
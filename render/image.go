@@ -1,12 +1,14 @@
 package render
 
 import (
+	"context"
 	"fmt"
 	"image"
 	"image/color"
 	"time"
 
 	"github.com/BattlesnakeOfficial/exporter/media"
+	"github.com/BattlesnakeOfficial/exporter/parse"
 	"github.com/disintegration/imaging"
 	"github.com/fogleman/gg"
 	"github.com/patrickmn/go-cache"
@@ -37,8 +39,76 @@ const (
 	ColorHazard                = "#00000066"
 )
 
-type boardContext struct {
+// focusFadeAmount controls how far faded content is blended towards white when a snake is in focus.
+// 0 leaves the (desaturated) colour as is, 1 makes it disappear completely.
+const focusFadeAmount = 0.6
+
+// DrawSettings are optional settings that change how a board is drawn.
+// The zero value draws the board normally.
+type DrawSettings struct {
+	// Focus is the ID of a snake to highlight.
+	// When set, all other snakes and food are desaturated and faded out.
+	Focus string
+	// Viewport is the region of the board to draw.
+	// When unset, the entire board is drawn.
+	Viewport Viewport
+	// Follow is the ID of a snake to keep in view.
+	// When set, the viewport is centred on that snake's head.
+	Follow string
+	// Watermark configures the branding drawn on the board.
+	// When nil, DefaultWatermark is used.
+	Watermark *Watermark
+	// Overlays are the names of optional layers to draw on top of the board (see Overlays).
+	Overlays []string
+	// Metadata is embedded in the exported file, to record where it came from.
+	// When nil, no metadata is embedded.
+	Metadata *Metadata
+}
+
+// watermark gets the watermark that should be drawn.
+func (s DrawSettings) watermark() Watermark {
+	if s.Watermark == nil {
+		return DefaultWatermark
+	}
+	return *s.Watermark
+}
+
+// visibleRegion resolves the region of the board that should be drawn.
+// The zero viewport is returned when the entire board should be drawn.
+func (s DrawSettings) visibleRegion(b *Board) Viewport {
+	v := s.Viewport
+	if v.IsZero() {
+		return v
+	}
+
+	if s.Follow != "" {
+		if head, ok := b.findSnakeHead(s.Follow); ok {
+			v = v.centreOn(head, b.Width, b.Height)
+		}
+	}
+	return v
+}
+
+// isFaded checks whether the given content should be drawn faded out.
+func (s DrawSettings) isFaded(c BoardSquareContent) bool {
+	if s.Focus == "" {
+		return false
+	}
+
+	if c.Type == BoardSquareFood {
+		return true
+	}
+	if c.isSnake() {
+		return c.SnakeID != s.Focus
+	}
+	return false
+}
+
+// BoardContext is the image that layers draw on, along with where the board's squares are in the image.
+type BoardContext struct {
 	*gg.Context
+	// ctx is the context of the request the board is drawn for, which stops loading snake images when it's done.
+	ctx context.Context
 	// boardOffsetX is the x offset for the bottom-left corner of the board in the image.
 	// For boards that don't perfectly fit within the image bounds, it will > 0 to center the board.
 	boardOffsetX int
@@ -58,9 +128,38 @@ type boardContext struct {
 	squareSizeHalfPx float64
 }
 
+// SquareSize is the size of a board square, in pixels.
+func (dc *BoardContext) SquareSize() int {
+	return dc.squareSizePx
+}
+
+// SquarePosition is the top-left corner of the board square at (x, y) in the image.
+// Like the game, the board's coordinates have (0, 0) at the bottom-left.
+func (dc *BoardContext) SquarePosition(x, y int) (float64, float64) {
+	return boardXToDrawX(dc, x) + BoardBorder, boardYToDrawY(dc, y) + BoardBorder
+}
+
+// BoardBounds is the part of the image that the board, including its border, covers.
+func (dc *BoardContext) BoardBounds() image.Rectangle {
+	return image.Rect(dc.boardOffsetX, dc.boardOffsetY, dc.boardOffsetX+dc.boardWidthPx, dc.boardOffsetY+dc.boardHeightPx)
+}
+
 // cache for storing image.Image objects to speed up rendering
 var imageCache = cache.New(6*time.Hour, 10*time.Minute)
 
+// directionRotation is how much to rotate a head or tail image, which faces right, so that it faces the direction.
+func directionRotation(dir snakeDirection) rotations {
+	switch dir {
+	case movingDown:
+		return rotate270
+	case movingLeft:
+		return rotate180
+	case movingUp:
+		return rotate90
+	}
+	return rotate0
+}
+
 func rotateImage(src image.Image, rot rotations) image.Image {
 	switch rot {
 	case rotate90:
@@ -73,21 +172,7 @@ func rotateImage(src image.Image, rot rotations) image.Image {
 	return src
 }
 
-func drawWatermark(dc *boardContext) {
-
-	// The watermark is a square image.
-	// We want to scale it close to the maximum size of square that can fit within the board.
-	wmSize := min(dc.boardWidthPx, dc.boardHeightPx)
-
-	watermarkImage, err := media.GetWatermarkPNG(wmSize*2/3, wmSize*2/3)
-	if err != nil {
-		log.WithError(err).Error("Unable to load watermark image")
-		return
-	}
-	dc.DrawImageAnchored(watermarkImage, dc.Width()/2, dc.Height()/2, 0.5, 0.5)
-}
-
-func drawEmptySquare(dc *boardContext, bx int, by int) {
+func drawEmptySquare(dc *BoardContext, bx int, by int) {
 	dc.SetHexColor(ColorEmptySquare)
 	dc.DrawRectangle(
 		boardXToDrawX(dc, bx)+SquareBorderPixels+BoardBorder,
@@ -98,8 +183,8 @@ func drawEmptySquare(dc *boardContext, bx int, by int) {
 	dc.Fill()
 }
 
-func drawFood(dc *boardContext, bx int, by int) {
-	dc.SetHexColor(ColorFood)
+func drawFood(dc *BoardContext, bx int, by int, c color.Color) {
+	dc.SetColor(c)
 	dc.DrawCircle(
 		boardXToDrawX(dc, bx)+dc.squareSizeHalfPx+BoardBorder,
 		boardYToDrawY(dc, by)+dc.squareSizeHalfPx+BoardBorder,
@@ -108,7 +193,7 @@ func drawFood(dc *boardContext, bx int, by int) {
 	dc.Fill()
 }
 
-func drawHazard(dc *boardContext, bx int, by int) {
+func drawHazard(dc *BoardContext, bx int, by int) {
 	dc.SetHexColor(ColorHazard)
 	dc.DrawRectangle(
 		boardXToDrawX(dc, bx)+SquareBorderPixels+BoardBorder,
@@ -119,7 +204,7 @@ func drawHazard(dc *boardContext, bx int, by int) {
 	dc.Fill()
 }
 
-func drawSnakeImage(name string, st snakeImageType, dc *boardContext, bx int, by int, c color.Color, dir snakeDirection) {
+func drawSnakeImage(name string, st snakeImageType, dc *BoardContext, bx int, by int, c color.Color, dir snakeDirection) {
 
 	width := dc.squareSizePx - int(SquareBorderPixels*2)
 	height := dc.squareSizePx - int(SquareBorderPixels*2)
@@ -128,9 +213,9 @@ func drawSnakeImage(name string, st snakeImageType, dc *boardContext, bx int, by
 	var err error
 	switch st {
 	case snakeHead:
-		snakeImg, err = media.GetHeadPNG(name, width, height, c)
+		snakeImg, err = media.GetHeadPNG(dc.ctx, name, width, height, c)
 	case snakeTail:
-		snakeImg, err = media.GetTailPNG(name, width, height, c)
+		snakeImg, err = media.GetTailPNG(dc.ctx, name, width, height, c)
 	default:
 		log.WithField("snakeImageType", st).Error("unable to draw an unrecognized snake image type")
 	}
@@ -140,25 +225,14 @@ func drawSnakeImage(name string, st snakeImageType, dc *boardContext, bx int, by
 		return
 	}
 
-	var rot rotations
-	switch dir {
-	case movingRight:
-		rot = rotate0
-	case movingDown:
-		rot = rotate270
-	case movingLeft:
-		rot = rotate180
-	case movingUp:
-		rot = rotate90
-	}
-	snakeImg = rotateImage(snakeImg, rot)
+	snakeImg = rotateImage(snakeImg, directionRotation(dir))
 
 	dx := int(boardXToDrawX(dc, bx) + SquareBorderPixels + BoardBorder)
 	dy := int(boardYToDrawY(dc, by) + SquareBorderPixels + BoardBorder)
 	dc.DrawImage(snakeImg, dx, dy)
 }
 
-func drawSnakeBody(dc *boardContext, bx int, by int, c color.Color, corner snakeCorner) {
+func drawSnakeBody(dc *BoardContext, bx int, by int, c color.Color, corner snakeCorner) {
 	dc.SetColor(c)
 	if corner == "none" {
 		dc.DrawRectangle(
@@ -227,7 +301,7 @@ func drawSnakeBody(dc *boardContext, bx int, by int, c color.Color, corner snake
 	dc.Fill()
 }
 
-func drawGaps(dc *boardContext, bx, by int, dir snakeDirection, c color.Color) {
+func drawGaps(dc *BoardContext, bx, by int, dir snakeDirection, c color.Color) {
 	dc.SetColor(c)
 	switch dir {
 	case movingUp:
@@ -262,7 +336,7 @@ func drawGaps(dc *boardContext, bx, by int, dir snakeDirection, c color.Color) {
 	dc.Fill()
 }
 
-func createBoardContext(b *Board, w, h int) *boardContext {
+func createBoardContext(ctx context.Context, b *Board, w, h int, settings DrawSettings) *BoardContext {
 	ss := calcSquarePx(w, h, b.Width, b.Height)
 
 	boardWidthPx := ss*b.Width + int(BoardBorder)*2
@@ -270,8 +344,9 @@ func createBoardContext(b *Board, w, h int) *boardContext {
 	offsetX := (w - boardWidthPx) / 2
 	offsetY := (h - boardHeightPx) / 2
 
-	dc := &boardContext{
+	dc := &BoardContext{
 		Context:          gg.NewContext(w, h),
+		ctx:              ctx,
 		squareSizePx:     ss,
 		boardWidthPx:     boardWidthPx,
 		boardHeightPx:    boardHeightPx,
@@ -280,7 +355,7 @@ func createBoardContext(b *Board, w, h int) *boardContext {
 		squareSizeHalfPx: float64(ss) / 2, // float to avoid rounding errors
 	}
 
-	cacheKey := fmt.Sprintf("board:%d:%d:%d:%d", b.Width, b.Height, w, h)
+	cacheKey := fmt.Sprintf("board:%d:%d:%d:%d:%s", b.Width, b.Height, w, h, layers.backgroundCacheKey(settings))
 	cachedBoardImage, ok := imageCache.Get(cacheKey)
 	if ok {
 		dc.DrawImage(cachedBoardImage.(image.Image), 0, 0)
@@ -291,15 +366,8 @@ func createBoardContext(b *Board, w, h int) *boardContext {
 	dc.SetColor(color.White)
 	dc.Clear()
 
-	// Draw empty squares
-	for y := 0; y < b.Height; y++ {
-		for x := 0; x < b.Width; x++ {
-			drawEmptySquare(dc, x, y)
-		}
-	}
-
-	// Draw watermark
-	drawWatermark(dc)
+	// Draw empty squares and watermark
+	layers.draw(backgroundLayer, b, dc, settings)
 
 	// Cache for next time
 	cacheDC := gg.NewContext(dc.Width(), dc.Height())
@@ -334,7 +402,15 @@ func squareFit(bounds, numSquares int) int {
 // Width and height values are in pixels.
 // If the image width/height is invalid (<= 0) a valid width/height
 // is calculated using the number of squares in the board.
-func DrawBoard(b *Board, imageWidth, imageHeight int) image.Image {
+// The settings allow for optional changes to the drawing, such as focusing on a single snake
+// or only drawing a region of the board.
+// Snake images that can't be loaded before the context is done are left out.
+func DrawBoard(ctx context.Context, b *Board, imageWidth, imageHeight int, settings DrawSettings) image.Image {
+	// only the visible region gets drawn, so it gets all of the available pixels
+	if v := settings.visibleRegion(b); !v.IsZero() {
+		b = b.crop(v)
+	}
+
 	// check if we need to calculate the image width
 	if imageWidth <= 0 || imageHeight <= 0 {
 
@@ -345,11 +421,48 @@ func DrawBoard(b *Board, imageWidth, imageHeight int) image.Image {
 		imageWidth = b.Width*20 + int(BoardBorder)*2
 		imageHeight = b.Height*20 + int(BoardBorder)*2
 	}
-	dc := createBoardContext(b, imageWidth, imageHeight)
+	dc := createBoardContext(ctx, b, imageWidth, imageHeight, settings)
+
+	// Draw food, snakes, etc. over the watermark
+	layers.draw(boardLayer, b, dc, settings)
+	layers.draw(overlayLayer, b, dc, settings)
+
+	return dc.Image()
+}
+
+func drawEmptySquaresLayer(b *Board, dc *BoardContext, settings DrawSettings) {
+	for y := 0; y < b.Height; y++ {
+		for x := 0; x < b.Width; x++ {
+			drawEmptySquare(dc, x, y)
+		}
+	}
+}
 
-	// Draw food and snakes over watermark
+func drawFoodLayer(b *Board, dc *BoardContext, settings DrawSettings) {
+	foodColor := parse.HexColor(ColorFood)
 	for p, s := range b.squares { // cool, we can iterate ONLY the non-empty squares!
 		for _, c := range s.Contents {
+			if c.Type != BoardSquareFood {
+				continue
+			}
+			if settings.isFaded(c) {
+				drawFood(dc, p.X, p.Y, fadeColor(foodColor))
+			} else {
+				drawFood(dc, p.X, p.Y, foodColor)
+			}
+		}
+	}
+}
+
+func drawSnakesLayer(b *Board, dc *BoardContext, settings DrawSettings) {
+	for p, s := range b.squares {
+		for _, c := range s.Contents {
+			if !c.isSnake() {
+				continue
+			}
+			if settings.isFaded(c) {
+				c.Color = fadeColor(c.Color)
+			}
 			switch c.Type {
 			case BoardSquareSnakeHead:
 				drawSnakeImage(c.SnakeType, snakeHead, dc, p.X, p.Y, c.Color, c.Direction)
@@ -359,29 +472,45 @@ func DrawBoard(b *Board, imageWidth, imageHeight int) image.Image {
 				drawGaps(dc, p.X, p.Y, c.Direction, c.Color)
 			case BoardSquareSnakeTail:
 				drawSnakeImage(c.SnakeType, snakeTail, dc, p.X, p.Y, c.Color, c.Direction)
-			case BoardSquareFood:
-				drawFood(dc, p.X, p.Y)
-			case BoardSquareHazard:
-				drawHazard(dc, p.X, p.Y)
 			}
 		}
+	}
+}
 
+func drawHazardsLayer(b *Board, dc *BoardContext, settings DrawSettings) {
+	for p, s := range b.squares {
+		for _, c := range s.Contents {
+			if c.Type == BoardSquareHazard {
+				drawHazard(dc, p.X, p.Y)
+			}
+		}
 	}
+}
 
-	return dc.Image()
+// fadeColor desaturates the given colour and blends it towards white,
+// so that it recedes into the background of the board.
+func fadeColor(c color.Color) color.Color {
+	r, g, b, _ := c.RGBA()
+
+	// perceived brightness (ITU-R BT.601 luma)
+	grey := (299*r + 587*g + 114*b) / 1000
+
+	faded := float64(grey) + (0xffff-float64(grey))*focusFadeAmount
+	v := uint8(uint32(faded) >> 8)
+	return color.RGBA{v, v, v, 0xff}
 }
 
 // boardXToDrawX converts an x coordinate in "board space" to the x coordinate used by graphics.
 // More specifically, it assumes the board coordinates are the indexes of squares and it returns the upper left
 // corner for that square.
-func boardXToDrawX(dc *boardContext, x int) float64 {
+func boardXToDrawX(dc *BoardContext, x int) float64 {
 	return float64(dc.boardOffsetX + x*dc.squareSizePx)
 }
 
 // boardYToDrawY converts a y coordinate in "board space" to the y coordinate used by graphics.
 // More specifically, it assumes the board coordinates are the indexes of squares and it returns the upper left
 // corner for that square.
-func boardYToDrawY(dc *boardContext, y int) float64 {
+func boardYToDrawY(dc *BoardContext, y int) float64 {
 	// Note: the Battlesnake board coordinates have (0,0) at the bottom left
 	// so we need to flip the y-axis to convert to the graphics, which follows the convention
 	// of (0,0) being the top left.
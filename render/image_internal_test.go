@@ -0,0 +1,44 @@
+package render
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/BattlesnakeOfficial/exporter/parse"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFadeColor(t *testing.T) {
+	// faded colours are grey
+	faded := fadeColor(parse.HexColor("#ff0000")).(color.RGBA)
+	assert.Equal(t, faded.R, faded.G)
+	assert.Equal(t, faded.G, faded.B)
+	assert.Equal(t, uint8(0xff), faded.A)
+
+	// and lighter than the original brightness
+	assert.Greater(t, faded.R, uint8(0x4c))
+
+	// white stays white, black gets lighter
+	assert.Equal(t, color.RGBA{0xff, 0xff, 0xff, 0xff}, fadeColor(color.White))
+	assert.Equal(t, color.RGBA{0x99, 0x99, 0x99, 0xff}, fadeColor(color.Black))
+}
+
+func TestDrawSettingsIsFaded(t *testing.T) {
+	food := BoardSquareContent{Type: BoardSquareFood}
+	hazard := BoardSquareContent{Type: BoardSquareHazard}
+	focused := BoardSquareContent{Type: BoardSquareSnakeBody, SnakeID: "snake1"}
+	other := BoardSquareContent{Type: BoardSquareSnakeHead, SnakeID: "snake2"}
+
+	// nothing is faded without a focus
+	s := DrawSettings{}
+	assert.False(t, s.isFaded(food))
+	assert.False(t, s.isFaded(hazard))
+	assert.False(t, s.isFaded(focused))
+	assert.False(t, s.isFaded(other))
+
+	s = DrawSettings{Focus: "snake1"}
+	assert.True(t, s.isFaded(food), "food should be faded")
+	assert.False(t, s.isFaded(hazard), "hazards should not be faded")
+	assert.False(t, s.isFaded(focused), "the focused snake should not be faded")
+	assert.True(t, s.isFaded(other), "other snakes should be faded")
+}
@@ -0,0 +1,180 @@
+package render
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Layer draws one visual part of the board, such as the food or the snakes.
+// Layers are drawn on top of each other to produce the final board image.
+// They can find what to draw with Board.Contents, and where to draw it with the BoardContext.
+type Layer interface {
+	Draw(b *Board, dc *BoardContext, settings DrawSettings)
+}
+
+// LayerFunc is an adapter to allow the use of ordinary functions as layers.
+type LayerFunc func(b *Board, dc *BoardContext, settings DrawSettings)
+
+// Draw calls f(b, dc, settings).
+func (f LayerFunc) Draw(b *Board, dc *BoardContext, settings DrawSettings) {
+	f(b, dc, settings)
+}
+
+// Z-order of the built-in layers.
+// Layers are drawn in ascending z-order, so new layers can be slotted in between these.
+const (
+	ZOrderSquares   = 0
+	ZOrderWatermark = 100
+	ZOrderFood      = 200
+	ZOrderSnakes    = 300
+	ZOrderHazards   = 400
+	ZOrderOverlays  = 500
+)
+
+type layerKind int
+
+const (
+	// backgroundLayer layers are drawn once for each board size and cached.
+	// They must not depend on anything that changes between turns.
+	backgroundLayer layerKind = iota
+	// boardLayer layers are always drawn.
+	boardLayer
+	// overlayLayer layers are only drawn when enabled by name in the draw settings.
+	overlayLayer
+)
+
+type registeredLayer struct {
+	name   string
+	zOrder int
+	kind   layerKind
+	layer  Layer
+	// cacheKey identifies the settings that a background layer depends on, since it's cached.
+	cacheKey func(settings DrawSettings) string
+}
+
+// layerRegistry keeps track of all the layers that can be drawn on a board.
+type layerRegistry struct {
+	sync.RWMutex
+	layers []registeredLayer
+}
+
+var layers = &layerRegistry{}
+
+func (r *layerRegistry) register(name string, zOrder int, kind layerKind, l Layer) {
+	r.registerLayer(registeredLayer{name: name, zOrder: zOrder, kind: kind, layer: l})
+}
+
+// registerBackground adds a background layer, which is cached separately for each of its cache keys.
+func (r *layerRegistry) registerBackground(name string, zOrder int, l Layer, cacheKey func(settings DrawSettings) string) {
+	r.registerLayer(registeredLayer{name: name, zOrder: zOrder, kind: backgroundLayer, layer: l, cacheKey: cacheKey})
+}
+
+func (r *layerRegistry) registerLayer(rl registeredLayer) {
+	r.Lock()
+	defer r.Unlock()
+
+	for _, existing := range r.layers {
+		if existing.name == rl.name {
+			panic(fmt.Sprintf("render: layer %q is already registered", rl.name))
+		}
+	}
+
+	r.layers = append(r.layers, rl)
+	sort.SliceStable(r.layers, func(i, j int) bool {
+		return r.layers[i].zOrder < r.layers[j].zOrder
+	})
+}
+
+// draw draws all layers of the given kind, in z-order.
+// Overlays are only drawn if they are enabled in the settings.
+func (r *layerRegistry) draw(kind layerKind, b *Board, dc *BoardContext, settings DrawSettings) {
+	r.RLock()
+	defer r.RUnlock()
+
+	for _, rl := range r.layers {
+		if rl.kind != kind {
+			continue
+		}
+		if kind == overlayLayer && !settings.hasOverlay(rl.name) {
+			continue
+		}
+		rl.layer.Draw(b, dc, settings)
+	}
+}
+
+// backgroundCacheKey identifies everything in the settings that the background layers depend on.
+func (r *layerRegistry) backgroundCacheKey(settings DrawSettings) string {
+	r.RLock()
+	defer r.RUnlock()
+
+	var keys []string
+	for _, rl := range r.layers {
+		if rl.kind == backgroundLayer && rl.cacheKey != nil {
+			keys = append(keys, rl.name+"="+rl.cacheKey(settings))
+		}
+	}
+	return strings.Join(keys, ",")
+}
+
+func (r *layerRegistry) overlays() []string {
+	r.RLock()
+	defer r.RUnlock()
+
+	var names []string
+	for _, rl := range r.layers {
+		if rl.kind == overlayLayer {
+			names = append(names, rl.name)
+		}
+	}
+	return names
+}
+
+// RegisterLayer adds a layer that is drawn on every board.
+// It panics if a layer with the same name is already registered.
+func RegisterLayer(name string, zOrder int, l Layer) {
+	layers.register(name, zOrder, boardLayer, l)
+}
+
+// RegisterOverlay adds an optional layer that is only drawn when it's enabled by name in the draw settings.
+// It panics if a layer with the same name is already registered.
+func RegisterOverlay(name string, zOrder int, l Layer) {
+	layers.register(name, zOrder, overlayLayer, l)
+}
+
+// Overlays lists the names of all the optional layers that can be enabled.
+func Overlays() []string {
+	return layers.overlays()
+}
+
+// IsOverlay checks whether an optional layer with the given name is registered.
+func IsOverlay(name string) bool {
+	for _, o := range Overlays() {
+		if o == name {
+			return true
+		}
+	}
+	return false
+}
+
+// hasOverlay checks whether the named overlay is enabled.
+func (s DrawSettings) hasOverlay(name string) bool {
+	for _, o := range s.Overlays {
+		if o == name {
+			return true
+		}
+	}
+	return false
+}
+
+func init() {
+	layers.register("squares", ZOrderSquares, backgroundLayer, LayerFunc(drawEmptySquaresLayer))
+	layers.registerBackground("watermark", ZOrderWatermark, LayerFunc(drawWatermarkLayer), func(settings DrawSettings) string {
+		return settings.watermark().cacheKey()
+	})
+	RegisterLayer("food", ZOrderFood, LayerFunc(drawFoodLayer))
+	RegisterLayer("snakes", ZOrderSnakes, LayerFunc(drawSnakesLayer))
+	RegisterLayer("hazards", ZOrderHazards, LayerFunc(drawHazardsLayer))
+	RegisterOverlay("turn", ZOrderOverlays, LayerFunc(drawTurnOverlay))
+}
@@ -0,0 +1,80 @@
+package render
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLayerRegistry(t *testing.T) {
+	var drawn []string
+	recordLayer := func(name string) Layer {
+		return LayerFunc(func(b *Board, dc *BoardContext, settings DrawSettings) {
+			drawn = append(drawn, name)
+		})
+	}
+
+	r := &layerRegistry{}
+	r.register("top", 20, boardLayer, recordLayer("top"))
+	r.register("bottom", 0, boardLayer, recordLayer("bottom"))
+	r.register("middle", 10, boardLayer, recordLayer("middle"))
+	r.register("background", 100, backgroundLayer, recordLayer("background"))
+	r.register("overlay", 50, overlayLayer, recordLayer("overlay"))
+
+	require.Panics(t, func() { r.register("top", 30, boardLayer, recordLayer("top")) }, "names must be unique")
+
+	// layers are drawn in z-order, and only the requested kind
+	r.draw(boardLayer, nil, nil, DrawSettings{})
+	assert.Equal(t, []string{"bottom", "middle", "top"}, drawn)
+
+	drawn = nil
+	r.draw(backgroundLayer, nil, nil, DrawSettings{})
+	assert.Equal(t, []string{"background"}, drawn)
+
+	// overlays must be enabled
+	drawn = nil
+	r.draw(overlayLayer, nil, nil, DrawSettings{})
+	assert.Empty(t, drawn)
+	r.draw(overlayLayer, nil, nil, DrawSettings{Overlays: []string{"overlay"}})
+	assert.Equal(t, []string{"overlay"}, drawn)
+
+	assert.Equal(t, []string{"overlay"}, r.overlays())
+}
+
+func TestBackgroundCacheKey(t *testing.T) {
+	r := &layerRegistry{}
+	r.register("plain", 0, backgroundLayer, LayerFunc(drawEmptySquaresLayer))
+	r.registerBackground("focus", 10, LayerFunc(drawEmptySquaresLayer), func(settings DrawSettings) string {
+		return settings.Focus
+	})
+	r.register("board", 20, boardLayer, LayerFunc(drawEmptySquaresLayer))
+
+	// only the settings that background layers depend on change the key
+	assert.Equal(t, "focus=snake1", r.backgroundCacheKey(DrawSettings{Focus: "snake1", Follow: "snake2"}))
+	assert.NotEqual(t, r.backgroundCacheKey(DrawSettings{Focus: "snake1"}), r.backgroundCacheKey(DrawSettings{Focus: "snake2"}))
+
+	league := Watermark{Asset: "league", Opacity: 0.5}
+	assert.NotEqual(t, layers.backgroundCacheKey(DrawSettings{}), layers.backgroundCacheKey(DrawSettings{Watermark: &league}))
+}
+
+func TestBuiltinLayers(t *testing.T) {
+	assert.Contains(t, Overlays(), "turn")
+	assert.True(t, IsOverlay("turn"))
+	assert.False(t, IsOverlay("snakes"), "core layers aren't optional")
+	assert.False(t, IsOverlay("notalayer"))
+}
+
+func TestDrawTurnOverlay(t *testing.T) {
+	b := NewBoard(5, 5)
+	b.Turn = 42
+	noWatermark := NoWatermark
+
+	without := DrawBoard(context.Background(), b, 0, 0, DrawSettings{Watermark: &noWatermark})
+	with := DrawBoard(context.Background(), b, 0, 0, DrawSettings{Watermark: &noWatermark, Overlays: []string{"turn"}})
+
+	// the top-left corner should have the turn drawn on it
+	assertSameColor(t, without.At(50, 50), with.At(50, 50))
+	assert.NotEqual(t, without.At(6, 6), with.At(6, 6))
+}
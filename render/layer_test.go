@@ -0,0 +1,40 @@
+package render_test
+
+import (
+	"context"
+	"image/color"
+	"testing"
+
+	"github.com/BattlesnakeOfficial/exporter/engine"
+	"github.com/BattlesnakeOfficial/exporter/render"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterOverlay_OutsidePackage(t *testing.T) {
+	// an overlay that fills every square with food in it
+	render.RegisterOverlay("test-food-highlight", render.ZOrderOverlays, render.LayerFunc(func(b *render.Board, dc *render.BoardContext, settings render.DrawSettings) {
+		for y := 0; y < b.Height; y++ {
+			for x := 0; x < b.Width; x++ {
+				for _, c := range b.Contents(x, y) {
+					if c.Type == render.BoardSquareFood {
+						px, py := dc.SquarePosition(x, y)
+						dc.SetColor(color.RGBA{0, 0, 0xff, 0xff})
+						dc.DrawRectangle(px, py, float64(dc.SquareSize()), float64(dc.SquareSize()))
+						dc.Fill()
+					}
+				}
+			}
+		}
+	}))
+	require.True(t, render.IsOverlay("test-food-highlight"))
+
+	g := &engine.Game{Width: 3, Height: 3}
+	gf := &engine.GameFrame{Food: []engine.Point{{X: 0, Y: 0}}}
+	noWatermark := render.NoWatermark
+	img := render.DrawBoard(context.Background(), render.GameFrameToBoard(g, gf), 0, 0, render.DrawSettings{Watermark: &noWatermark, Overlays: []string{"test-food-highlight"}})
+
+	// the bottom-left square is highlighted, and the top-right square isn't
+	assert.Equal(t, color.RGBA{0, 0, 0xff, 0xff}, color.RGBAModel.Convert(img.At(10, 52)))
+	assert.NotEqual(t, color.RGBA{0, 0, 0xff, 0xff}, color.RGBAModel.Convert(img.At(52, 10)))
+}
@@ -0,0 +1,26 @@
+package render
+
+import (
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"github.com/BattlesnakeOfficial/exporter/media"
+)
+
+// TestMain uses a stub media source, so that the tests don't depend on the media server,
+// and saves downloaded SVGs to a temporary directory, rather than the source tree.
+func TestMain(m *testing.M) {
+	downloadDir, err := os.MkdirTemp("", "downloads")
+	if err != nil {
+		panic(err)
+	}
+	media.SetDownloadDir(downloadDir)
+	media.SetSource(media.BundleSource{FS: fstest.MapFS{
+		"snakes/heads/default.svg": {Data: []byte(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 100 100"><path d="M0 0h60l40 50-40 50H0z"/></svg>`)},
+		"snakes/tails/default.svg": {Data: []byte(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 100 100"><path d="M0 50 100 0v100z"/></svg>`)},
+	}})
+	code := m.Run()
+	_ = os.RemoveAll(downloadDir)
+	os.Exit(code)
+}
@@ -15,3 +15,15 @@ func min(a, b int) int {
 	}
 	return b
 }
+
+// clamp restricts an integer to the range [lo, hi].
+// If hi < lo, lo is returned.
+func clamp(a, lo, hi int) int {
+	if a > hi {
+		a = hi
+	}
+	if a < lo {
+		a = lo
+	}
+	return a
+}
@@ -23,3 +23,10 @@ func TestMin(t *testing.T) {
 	assert.Equal(t, 1, min(1, 2))
 	assert.Equal(t, -1, min(-1, 1))
 }
+
+func TestClamp(t *testing.T) {
+	assert.Equal(t, 0, clamp(-1, 0, 10))
+	assert.Equal(t, 5, clamp(5, 0, 10))
+	assert.Equal(t, 10, clamp(11, 0, 10))
+	assert.Equal(t, 0, clamp(5, 0, -1), "lower bound wins when the range is empty")
+}
@@ -0,0 +1,120 @@
+package render
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/BattlesnakeOfficial/exporter/engine"
+	"github.com/BattlesnakeOfficial/exporter/render/gif"
+)
+
+const (
+	// metadataApplicationID identifies the GIF application extension that holds the metadata.
+	metadataApplicationID = "BSNAKEXP1.0"
+	// metadataPNGKeyword is the keyword of the PNG text chunk that holds the metadata.
+	metadataPNGKeyword = "battlesnake"
+)
+
+var ErrNoMetadata = errors.New("no Battlesnake metadata found")
+
+// Metadata describes where an exported file came from.
+// It's embedded in exported GIFs and PNGs, and can be read back with ReadMetadata.
+type Metadata struct {
+	GameID string `json:"game"`
+	// FirstTurn and LastTurn are the range of turns that were exported.
+	FirstTurn int `json:"firstTurn"`
+	LastTurn  int `json:"lastTurn"`
+	// Version is the version of the exporter.
+	Version string `json:"version"`
+	// EngineHost is the host of the engine the game was fetched from.
+	EngineHost string `json:"engine"`
+}
+
+// NewMetadata creates the metadata for exporting some frames of a game.
+func NewMetadata(gameID string, gameFrames []*engine.GameFrame, version, engineHost string) *Metadata {
+	m := &Metadata{GameID: gameID, Version: version, EngineHost: engineHost}
+	if len(gameFrames) > 0 {
+		m.FirstTurn = gameFrames[0].Turn
+		m.LastTurn = gameFrames[len(gameFrames)-1].Turn
+	}
+	return m
+}
+
+// String describes the metadata for people, and is used as the comment in exported files.
+func (m Metadata) String() string {
+	return fmt.Sprintf("Battlesnake game %s, turns %d-%d, exported by exporter %s from %s", m.GameID, m.FirstTurn, m.LastTurn, m.Version, m.EngineHost)
+}
+
+// gifExtensions stores the metadata as a GIF comment, for people, and an application extension, for ReadMetadata.
+func (m *Metadata) gifExtensions() gif.Extensions {
+	if m == nil {
+		return gif.Extensions{}
+	}
+	data, _ := json.Marshal(m)
+	return gif.Extensions{
+		Comments:     []string{m.String()},
+		Applications: []gif.ApplicationExtension{{Identifier: metadataApplicationID, Data: data}},
+	}
+}
+
+// pngText stores the metadata as PNG text chunks.
+func (m *Metadata) pngText() []pngTextChunk {
+	if m == nil {
+		return nil
+	}
+	data, _ := json.Marshal(m)
+	return []pngTextChunk{
+		{Keyword: "Comment", Text: m.String()},
+		{Keyword: "Software", Text: "Battlesnake exporter " + m.Version},
+		{Keyword: metadataPNGKeyword, Text: string(data)},
+	}
+}
+
+// ReadMetadata reads the metadata embedded in an exported GIF or PNG.
+// ErrNoMetadata is returned for files that don't have any metadata.
+func ReadMetadata(r io.Reader) (*Metadata, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(len(pngSignature))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read file: %w", err)
+	}
+
+	var data []byte
+	switch {
+	case bytes.HasPrefix(magic, []byte("GIF8")):
+		g, err := gif.DecodeAll(br)
+		if err != nil {
+			return nil, err
+		}
+		for _, app := range g.Extensions.Applications {
+			if app.Identifier == metadataApplicationID {
+				data = app.Data
+			}
+		}
+	case bytes.Equal(magic, []byte(pngSignature)):
+		chunks, err := readPNGText(br)
+		if err != nil {
+			return nil, err
+		}
+		for _, chunk := range chunks {
+			if chunk.Keyword == metadataPNGKeyword {
+				data = []byte(chunk.Text)
+			}
+		}
+	default:
+		return nil, errors.New("unsupported file type - only GIF and PNG files can have metadata")
+	}
+
+	if data == nil {
+		return nil, ErrNoMetadata
+	}
+	m := &Metadata{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("invalid metadata: %w", err)
+	}
+	return m, nil
+}
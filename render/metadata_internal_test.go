@@ -0,0 +1,83 @@
+package render
+
+import (
+	"bytes"
+	"context"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/BattlesnakeOfficial/exporter/render/gif"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetadata(t *testing.T) {
+	game, gameFrames := asciiGameFrames(t,
+		"-------\n|*    |\n|     |\n| OOH |\n|     |\n|  .  |\n-------\n",
+		"-------\n|*    |\n|     |\n|  OOH|\n|     |\n|  .  |\n-------\n",
+	)
+	gameFrames[0].Turn, gameFrames[1].Turn = 4, 5
+	settings := DrawSettings{Metadata: NewMetadata("GAME_ID", gameFrames, "1.2.3", "engine.example.com")}
+	expected := &Metadata{GameID: "GAME_ID", FirstTurn: 4, LastTurn: 5, Version: "1.2.3", EngineHost: "engine.example.com"}
+
+	// animated GIFs
+	var buf bytes.Buffer
+	require.NoError(t, GameFramesToAnimatedGIF(context.Background(), &buf, game, gameFrames, GIFFrameDelay, GIFLoopDelay, 0, 0, settings))
+	animated, err := gif.DecodeAll(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	require.Equal(t, []string{"Battlesnake game GAME_ID, turns 4-5, exported by exporter 1.2.3 from engine.example.com"}, animated.Extensions.Comments)
+	require.Equal(t, 0, animated.LoopCount)
+	m, err := ReadMetadata(&buf)
+	require.NoError(t, err)
+	require.Equal(t, expected, m)
+
+	// single frame GIFs
+	buf.Reset()
+	require.NoError(t, GameFrameToGIF(context.Background(), &buf, game, gameFrames[0], 0, 0, settings))
+	m, err = ReadMetadata(&buf)
+	require.NoError(t, err)
+	require.Equal(t, expected, m)
+
+	// PNGs are still valid images
+	buf.Reset()
+	require.NoError(t, GameFrameToPNG(context.Background(), &buf, game, gameFrames[0], 0, 0, settings))
+	img, err := png.Decode(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	require.Equal(t, 5*20+2*int(BoardBorder), img.Bounds().Dx())
+	m, err = ReadMetadata(&buf)
+	require.NoError(t, err)
+	require.Equal(t, expected, m)
+
+	// without metadata
+	buf.Reset()
+	require.NoError(t, GameFrameToPNG(context.Background(), &buf, game, gameFrames[0], 0, 0, DrawSettings{}))
+	_, err = ReadMetadata(&buf)
+	require.ErrorIs(t, err, ErrNoMetadata)
+
+	_, err = ReadMetadata(strings.NewReader("not an image"))
+	require.Error(t, err)
+
+	// chunk lengths are checked before reading the chunk
+	_, err = ReadMetadata(strings.NewReader("\x89PNG\r\n\x1a\n\xff\xff\xff\xfftEXtabc"))
+	require.Error(t, err)
+	_, err = ReadMetadata(strings.NewReader("\x89PNG\r\n\x1a\n\x00\x20\x00\x00tEXtabc"))
+	require.Error(t, err)
+}
+
+func TestGIFExtensions_LongData(t *testing.T) {
+	// data longer than a single sub-block
+	extensions := gif.Extensions{
+		Comments:     []string{strings.Repeat("c", 600)},
+		Applications: []gif.ApplicationExtension{{Identifier: "TESTTEST1.0", Data: bytes.Repeat([]byte{1, 2, 3}, 200)}},
+	}
+	game, gameFrames := asciiGameFrames(t, "---\n|*|\n---\n")
+	var buf bytes.Buffer
+	require.NoError(t, gif.Encode(&buf, gameFrameToPalettedImage(context.Background(), game, gameFrames[0], 0, 0, DrawSettings{}, nil), &gif.Options{Extensions: extensions}))
+	decoded, err := gif.DecodeAll(&buf)
+	require.NoError(t, err)
+	require.Equal(t, extensions, decoded.Extensions)
+
+	// application identifiers have a fixed size
+	extensions.Applications[0].Identifier = "TEST"
+	require.Error(t, gif.Encode(&buf, decoded.Image[0], &gif.Options{Extensions: extensions}))
+}
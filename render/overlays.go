@@ -0,0 +1,25 @@
+package render
+
+import (
+	"fmt"
+	"image/color"
+)
+
+// drawTurnOverlay draws the current turn number in the top-left corner of the board.
+func drawTurnOverlay(b *Board, dc *BoardContext, settings DrawSettings) {
+	label := fmt.Sprintf("Turn %d", b.Turn)
+	w, h := dc.MeasureString(label)
+
+	const padding = 3
+	// overlays only use what's exported, so that they work the same as ones added from outside the package
+	bounds := dc.BoardBounds()
+	x := float64(bounds.Min.X) + BoardBorder + SquareBorderPixels
+	y := float64(bounds.Min.Y) + BoardBorder + SquareBorderPixels
+
+	dc.SetColor(color.RGBA{0x00, 0x00, 0x00, 0x99})
+	dc.DrawRectangle(x, y, w+padding*2, h+padding*2)
+	dc.Fill()
+
+	dc.SetColor(color.White)
+	dc.DrawStringAnchored(label, x+padding, y+padding, 0, 1)
+}
@@ -0,0 +1,174 @@
+package render
+
+import (
+	"image"
+	"image/color"
+	"sync"
+
+	"github.com/BattlesnakeOfficial/exporter/engine"
+	"github.com/BattlesnakeOfficial/exporter/parse"
+)
+
+const (
+	// paletteBlendSteps is how many colours are added between each snake colour and the squares it's drawn on.
+	// These cover the antialiased edges of heads, tails and rounded corners.
+	paletteBlendSteps = 6
+	// paletteGreySteps is how many shades of grey are added, which cover the watermark and overlay text.
+	paletteGreySteps = 16
+)
+
+// gamePalette is a fixed palette shared by every frame of an animated GIF.
+// Since almost every colour drawn on a board is known before rendering, using one palette
+// avoids quantizing each frame separately, and stops colours from shifting between frames.
+type gamePalette struct {
+	palette color.Palette
+
+	// lookup caches the closest palette index for each colour that has been mapped
+	mu     sync.RWMutex
+	lookup map[color.RGBA]uint8
+}
+
+// newGamePalette builds the palette for all of the colours that can be drawn in the game frames.
+// The first entry is always transparent, so that it can be used for delta frames.
+func newGamePalette(gameFrames []*engine.GameFrame, settings DrawSettings) *gamePalette {
+	empty := parse.HexColor(ColorEmptySquare)
+	hazard := parse.HexColor(ColorHazard)
+	hazardSquare := blendOver(hazard, empty)
+
+	b := &paletteBuilder{seen: make(map[color.RGBA]bool)}
+	b.add(color.RGBA{}) // transparent
+	b.add(color.White)
+	b.add(color.Black)
+	b.add(empty)
+	b.add(hazardSquare)
+
+	// food, snakes and the colours they're blended with
+	var colors []color.Color
+	colors = append(colors, parse.HexColor(ColorFood), parse.HexColor(ColorDeadSnake))
+	seenSnakes := make(map[string]bool)
+	for _, gf := range gameFrames {
+		for _, snake := range gf.Snakes {
+			if seenSnakes[snake.Color] {
+				continue
+			}
+			seenSnakes[snake.Color] = true
+			colors = append(colors, parse.HexColor(snake.Color))
+		}
+	}
+	if settings.Focus != "" {
+		for _, c := range colors {
+			colors = append(colors, fadeColor(c))
+		}
+	}
+
+	for _, c := range colors {
+		b.add(c)
+		b.add(blendOver(hazard, c))
+	}
+	for _, c := range colors {
+		b.addBlends(c, empty, paletteBlendSteps)
+		b.addBlends(blendOver(hazard, c), hazardSquare, paletteBlendSteps/2)
+	}
+
+	b.addBlends(color.Black, color.White, paletteGreySteps)
+
+	// fill any remaining space with a coarse spread of colours, for anything unexpected (such as custom watermarks)
+	levels := []uint8{0x00, 0x33, 0x66, 0x99, 0xcc, 0xff}
+	for _, r := range levels {
+		for _, g := range levels {
+			for _, bl := range levels {
+				b.add(color.RGBA{r, g, bl, 0xff})
+			}
+		}
+	}
+
+	return &gamePalette{
+		palette: b.palette,
+		lookup:  make(map[color.RGBA]uint8),
+	}
+}
+
+// paletted maps an image to the palette, using the closest colour for each pixel.
+func (p *gamePalette) paletted(img image.Image) *image.Paletted {
+	bounds := img.Bounds()
+	paletted := image.NewPaletted(bounds, p.palette)
+
+	// a per-image cache avoids locking for each pixel
+	local := make(map[color.RGBA]uint8)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)
+			i, ok := local[c]
+			if !ok {
+				i = p.index(c)
+				local[c] = i
+			}
+			paletted.Pix[paletted.PixOffset(x, y)] = i
+		}
+	}
+	return paletted
+}
+
+// index finds the closest palette entry for an opaque colour, excluding the transparent entry.
+func (p *gamePalette) index(c color.RGBA) uint8 {
+	p.mu.RLock()
+	i, ok := p.lookup[c]
+	p.mu.RUnlock()
+	if ok {
+		return i
+	}
+
+	// skip the transparent entry, so that opaque pixels are never treated as transparent
+	opaque := c
+	opaque.A = 0xff
+	i = uint8(p.palette[1:].Index(opaque) + 1)
+
+	p.mu.Lock()
+	p.lookup[c] = i
+	p.mu.Unlock()
+	return i
+}
+
+// paletteBuilder collects unique colours, up to the maximum size of a GIF palette.
+type paletteBuilder struct {
+	palette color.Palette
+	seen    map[color.RGBA]bool
+}
+
+func (b *paletteBuilder) add(c color.Color) {
+	rgba := color.RGBAModel.Convert(c).(color.RGBA)
+	if len(b.palette) >= GIFMaxColorsPerFrame || b.seen[rgba] {
+		return
+	}
+	b.seen[rgba] = true
+	b.palette = append(b.palette, rgba)
+}
+
+// addBlends adds the colours in between from and to (excluding both ends).
+func (b *paletteBuilder) addBlends(from, to color.Color, steps int) {
+	for i := 1; i < steps; i++ {
+		b.add(blend(from, to, float64(i)/float64(steps)))
+	}
+}
+
+// blend mixes two opaque colours. An amount of 0 gives the first colour and 1 gives the second.
+func blend(from, to color.Color, amount float64) color.Color {
+	r1, g1, b1, _ := from.RGBA()
+	r2, g2, b2, _ := to.RGBA()
+	mix := func(a, b uint32) uint8 {
+		return uint8((float64(a)*(1-amount) + float64(b)*amount) / 0x101)
+	}
+	return color.RGBA{mix(r1, r2), mix(g1, g2), mix(b1, b2), 0xff}
+}
+
+// blendOver draws a (possibly translucent) colour over an opaque background colour.
+func blendOver(c, background color.Color) color.Color {
+	_, _, _, a := c.RGBA()
+	if a == 0 {
+		return background
+	}
+	r, g, b, _ := c.RGBA()
+	// un-premultiply the colour, then mix it with the background
+	opaque := color.RGBA64{uint16(r * 0xffff / a), uint16(g * 0xffff / a), uint16(b * 0xffff / a), 0xffff}
+	return blend(background, opaque, float64(a)/0xffff)
+}
@@ -0,0 +1,167 @@
+package render
+
+import (
+	"context"
+	"image"
+	"runtime"
+	"sync/atomic"
+
+	"github.com/BattlesnakeOfficial/exporter/render/gif"
+)
+
+// semaphore limits how many things can happen at the same time.
+type semaphore chan struct{}
+
+func (s semaphore) release() { <-s }
+
+// acquireContext waits to acquire the semaphore, unless the context is cancelled first.
+func (s semaphore) acquireContext(ctx context.Context) error {
+	select {
+	case s <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// frameWorkers limits how many GIF frames are rendered or compressed at the same time, across all requests.
+// This keeps the total CPU used by GIF rendering within a fixed budget, no matter how many requests are in flight.
+var frameWorkers atomic.Pointer[semaphore]
+
+func init() {
+	SetFrameWorkers(runtime.NumCPU())
+}
+
+// SetFrameWorkers sets how many GIF frames can be rendered at the same time, across all requests.
+// It should be called before any rendering starts.
+func SetFrameWorkers(n int) {
+	if n < 1 {
+		n = 1
+	}
+	s := make(semaphore, n)
+	frameWorkers.Store(&s)
+}
+
+// FrameWorkers gets how many GIF frames can be rendered at the same time, across all requests.
+func FrameWorkers() int {
+	return cap(*frameWorkers.Load())
+}
+
+// pipelineFrame tracks a single frame as it moves through the pipeline.
+type pipelineFrame struct {
+	frame gif.GIFFrame
+	// image is the rendered frame, or nil if it couldn't be rendered.
+	// It's set before rendered is closed, and never changed afterward.
+	image *image.Paletted
+	// rendered is closed once the frame's image is ready, so that the next frame can compare against it
+	rendered chan struct{}
+	// compressed is closed once the frame is ready to be written
+	compressed chan struct{}
+}
+
+// renderFramesInOrder renders and compresses frames in parallel, and sends them to out in order.
+// To limit memory use, only a bounded number of frames can be waiting to be sent at any time.
+// It stops after sending the first frame with an error, or as soon as the context is cancelled.
+func renderFramesInOrder(ctx context.Context, numFrames int, renderFrame func(i int) gif.GIFFrame, out chan<- gif.GIFFrame) {
+	workers := frameWorkers.Load()
+	window := make(semaphore, 2*cap(*workers))
+	stop := make(chan struct{})
+	defer close(stop)
+
+	frames := make([]*pipelineFrame, numFrames)
+	for i := range frames {
+		frames[i] = &pipelineFrame{rendered: make(chan struct{}), compressed: make(chan struct{})}
+	}
+
+	go func() {
+		for i := range frames {
+			select {
+			case window <- struct{}{}:
+			case <-stop:
+				return
+			}
+
+			i := i
+			var prev *pipelineFrame
+			if i > 0 {
+				prev = frames[i-1]
+			}
+			go renderPipelineFrame(ctx, workers, frames[i], prev, func() gif.GIFFrame { return renderFrame(i) })
+		}
+	}()
+
+	for i, pf := range frames {
+		select {
+		case <-pf.compressed:
+		case <-ctx.Done():
+			// the frames still being worked on stop once they try to get a worker
+			return
+		}
+		select {
+		case out <- pf.frame:
+		case <-ctx.Done():
+			return
+		}
+		if pf.frame.Error != nil {
+			return
+		}
+
+		// the frame isn't needed for comparisons once the next frame is compressed
+		if i > 0 {
+			frames[i-1] = nil
+		}
+		window.release()
+	}
+}
+
+// renderPipelineFrame renders a frame, then compresses it once the previous frame is available.
+// Workers are only held while rendering or compressing, not while waiting for the previous frame.
+// If the context is cancelled while waiting for a worker, the frame isn't rendered.
+func renderPipelineFrame(ctx context.Context, workers *semaphore, pf, prev *pipelineFrame, renderFrame func() gif.GIFFrame) {
+	defer close(pf.compressed)
+
+	if err := workers.acquireContext(ctx); err != nil {
+		pf.frame = gif.GIFFrame{Error: err}
+		close(pf.rendered)
+		return
+	}
+	pf.frame = renderFrameSafely(renderFrame)
+	workers.release()
+	if pf.frame.Error == nil {
+		pf.image = pf.frame.Image
+	}
+	close(pf.rendered)
+
+	if pf.frame.Error != nil {
+		return
+	}
+
+	var prevImage *image.Paletted
+	if prev != nil {
+		<-prev.rendered
+		if prev.image == nil {
+			// this frame will never be written
+			return
+		}
+		prevImage = prev.image
+	}
+
+	if err := workers.acquireContext(ctx); err != nil {
+		pf.frame.Error = err
+		return
+	}
+	defer workers.release()
+	if err := pf.frame.Compress(prevImage); err != nil {
+		pf.frame.Error = err
+	}
+}
+
+// renderFrameSafely converts any panic while rendering into a frame error.
+func renderFrameSafely(renderFrame func() gif.GIFFrame) (f gif.GIFFrame) {
+	defer func() {
+		if r := recover(); r != nil {
+			f = gif.GIFFrame{Error: recoverToError(r)}
+		}
+	}()
+	return renderFrame()
+}
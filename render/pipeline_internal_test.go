@@ -0,0 +1,138 @@
+package render
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/BattlesnakeOfficial/exporter/render/gif"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func withFrameWorkers(t *testing.T, n int) {
+	original := FrameWorkers()
+	SetFrameWorkers(n)
+	t.Cleanup(func() { SetFrameWorkers(original) })
+}
+
+func testPipelineImage(i int) *image.Paletted {
+	img := image.NewPaletted(image.Rect(0, 0, 4, 4), color.Palette{color.RGBA{}, color.Black, color.White})
+	img.Pix[i%len(img.Pix)] = 1
+	return img
+}
+
+func collectFrames(numFrames int, renderFrame func(i int) gif.GIFFrame) []gif.GIFFrame {
+	out := make(chan gif.GIFFrame)
+	go func() {
+		defer close(out)
+		renderFramesInOrder(context.Background(), numFrames, renderFrame, out)
+	}()
+
+	var frames []gif.GIFFrame
+	for f := range out {
+		frames = append(frames, f)
+	}
+	return frames
+}
+
+func TestRenderFramesInOrder(t *testing.T) {
+	withFrameWorkers(t, 3)
+
+	var active, maxActive int32
+	frames := collectFrames(20, func(i int) gif.GIFFrame {
+		n := atomic.AddInt32(&active, 1)
+		for {
+			m := atomic.LoadInt32(&maxActive)
+			if n <= m || atomic.CompareAndSwapInt32(&maxActive, m, n) {
+				break
+			}
+		}
+		// later frames finish first
+		time.Sleep(time.Duration(20-i) * time.Millisecond / 4)
+		atomic.AddInt32(&active, -1)
+		return gif.GIFFrame{Image: testPipelineImage(i), FrameNum: i}
+	})
+
+	require.Len(t, frames, 20)
+	for i, f := range frames {
+		assert.Equal(t, i, f.FrameNum)
+		assert.NoError(t, f.Error)
+	}
+	assert.LessOrEqual(t, maxActive, int32(3), "should stay within the frame worker budget")
+	assert.Greater(t, maxActive, int32(1), "should render frames in parallel")
+}
+
+func TestRenderFramesInOrder_Errors(t *testing.T) {
+	withFrameWorkers(t, 2)
+
+	errRender := errors.New("render failed")
+	frames := collectFrames(10, func(i int) gif.GIFFrame {
+		if i == 4 {
+			return gif.GIFFrame{Error: errRender}
+		}
+		return gif.GIFFrame{Image: testPipelineImage(i), FrameNum: i}
+	})
+	require.Len(t, frames, 5, "should stop after the first error")
+	assert.ErrorIs(t, frames[4].Error, errRender)
+
+	// panics are turned into errors
+	frames = collectFrames(10, func(i int) gif.GIFFrame {
+		if i == 2 {
+			panic("oops")
+		}
+		return gif.GIFFrame{Image: testPipelineImage(i), FrameNum: i}
+	})
+	require.Len(t, frames, 3)
+	require.Error(t, frames[2].Error)
+	assert.Contains(t, frames[2].Error.Error(), "oops")
+}
+
+func TestRenderFramesInOrder_Cancelled(t *testing.T) {
+	withFrameWorkers(t, 2)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var rendered int32
+	out := make(chan gif.GIFFrame)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		renderFramesInOrder(ctx, 100, func(i int) gif.GIFFrame {
+			atomic.AddInt32(&rendered, 1)
+			if i == 3 {
+				cancel()
+			}
+			return gif.GIFFrame{Image: testPipelineImage(i), FrameNum: i}
+		}, out)
+	}()
+	go func() {
+		for range out {
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("rendering should stop when the context is cancelled")
+	}
+	close(out)
+	assert.Less(t, atomic.LoadInt32(&rendered), int32(100))
+
+	// every worker is released
+	workers := frameWorkers.Load()
+	require.Eventually(t, func() bool { return len(*workers) == 0 }, time.Second, time.Millisecond)
+}
+
+func TestSetFrameWorkers(t *testing.T) {
+	withFrameWorkers(t, 5)
+	assert.Equal(t, 5, FrameWorkers())
+
+	SetFrameWorkers(0)
+	assert.Equal(t, 1, FrameWorkers())
+}
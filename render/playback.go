@@ -0,0 +1,190 @@
+package render
+
+import (
+	"github.com/BattlesnakeOfficial/exporter/engine"
+)
+
+const (
+	// timelapseContext is how many frames either side of an event are shown at normal speed in a timelapse.
+	timelapseContext = 3
+	// timelapseQuietStep is how much quiet stretches of the game are sped up in a timelapse.
+	timelapseQuietStep = 4
+)
+
+// eventSlowdown is how many times longer frames are shown for around each type of event, when slowing down for events.
+var eventSlowdown = map[gameEventType]int{
+	eventFoodEaten:  2,
+	eventDeath:      3,
+	eventHeadToHead: 4,
+}
+
+// Playback controls which frames of a game are shown in an animated GIF, and for how long.
+type Playback struct {
+	// FrameDelay is how long each frame is shown for, in hundredths of a second.
+	FrameDelay int
+	// LoopDelay is how long the last frame is shown for before the animation loops.
+	LoopDelay int
+	// Step only shows every Nth frame (plus the last frame). 0 or 1 shows every frame.
+	Step int
+	// Timelapse speeds up quiet stretches of the game, where no snakes eat or die,
+	// by skipping frames that are far away from anything happening.
+	Timelapse bool
+	// SlowEvents shows the frames around snakes eating, dying and colliding head-on for longer.
+	SlowEvents bool
+	// Mode is the order the frames are shown in.
+	Mode PlaybackMode
+}
+
+// DefaultPlayback shows every frame at the default speed.
+var DefaultPlayback = Playback{FrameDelay: GIFFrameDelay, LoopDelay: GIFLoopDelay}
+
+// PlaybackMode is the order that frames are shown in.
+type PlaybackMode string
+
+const (
+	// PlaybackForward shows the frames from the start of the game to the end.
+	PlaybackForward PlaybackMode = ""
+	// PlaybackReverse shows the frames from the end of the game to the start.
+	PlaybackReverse PlaybackMode = "reverse"
+	// PlaybackBoomerang shows the frames forward, then backward.
+	PlaybackBoomerang PlaybackMode = "boomerang"
+)
+
+// PlaybackModes are the valid playback modes, apart from the default forward mode.
+var PlaybackModes = []PlaybackMode{PlaybackReverse, PlaybackBoomerang}
+
+// Apply picks the frames to show, the order to show them in, and how long to show each of them for.
+// Frames that are shown more than once (such as in boomerang mode) are the same *GameFrame,
+// so that they can be rendered once when they're shown again soon after.
+func (p Playback) Apply(gameFrames []*engine.GameFrame) ([]*engine.GameFrame, []int) {
+	frames := gameFrames
+	if p.Timelapse {
+		frames = timelapseFrames(frames)
+	}
+	if p.Step > 1 {
+		frames = everyNthFrame(frames, p.Step)
+	}
+
+	slowdown := make([]int, len(frames))
+	for i := range slowdown {
+		slowdown[i] = 1
+	}
+	if p.SlowEvents {
+		slowdown = eventSlowdowns(gameFrames, frames)
+	}
+
+	order := playbackOrder(len(frames), p.Mode)
+	ordered := make([]*engine.GameFrame, len(order))
+	delays := make([]int, len(order))
+	for i, j := range order {
+		ordered[i] = frames[j]
+		delays[i] = slowdown[j] * p.FrameDelay
+	}
+
+	// the last frame is shown for longer before the animation loops, unless it's slowed down even more
+	if last := len(delays) - 1; last >= 0 {
+		if slowdown[order[last]] > 1 {
+			delays[last] = max(delays[last], p.LoopDelay)
+		} else {
+			delays[last] = p.LoopDelay
+		}
+	}
+	return ordered, delays
+}
+
+// playbackOrder lists the indexes of the frames to show, in the order they're shown.
+func playbackOrder(numFrames int, mode PlaybackMode) []int {
+	var order []int
+	switch mode {
+	case PlaybackReverse:
+		for i := numFrames - 1; i >= 0; i-- {
+			order = append(order, i)
+		}
+	case PlaybackBoomerang:
+		for i := 0; i < numFrames; i++ {
+			order = append(order, i)
+		}
+		// the first and last frames aren't repeated, so the animation doesn't pause when it changes direction or loops
+		for i := numFrames - 2; i > 0; i-- {
+			order = append(order, i)
+		}
+	default:
+		for i := 0; i < numFrames; i++ {
+			order = append(order, i)
+		}
+	}
+	return order
+}
+
+// eventSlowdowns finds how many times longer each of the shown frames should be shown for, because of the events around it.
+// Events are found using every game frame, so that events in skipped frames slow down the next frame that's shown.
+func eventSlowdowns(gameFrames, shown []*engine.GameFrame) []int {
+	// the slowdown for the events between each game frame and the one before it
+	slowdown := make(map[*engine.GameFrame]int, len(gameFrames))
+	for i := 1; i < len(gameFrames); i++ {
+		for _, event := range frameEvents(gameFrames[i-1], gameFrames[i]) {
+			slowdown[gameFrames[i]] = max(slowdown[gameFrames[i]], eventSlowdown[event.Type])
+		}
+	}
+
+	factors := make([]int, len(shown))
+	for i := range factors {
+		factors[i] = 1
+	}
+	next := 0
+	for i, gf := range shown {
+		// include the events from any frames skipped since the last frame that was shown
+		factor := 0
+		for ; next < len(gameFrames); next++ {
+			factor = max(factor, slowdown[gameFrames[next]])
+			if gameFrames[next] == gf {
+				next++
+				break
+			}
+		}
+		if factor == 0 {
+			continue
+		}
+		// the frames either side of an event are slowed down too
+		for j := max(0, i-1); j <= min(len(shown)-1, i+1); j++ {
+			factors[j] = max(factors[j], factor)
+		}
+	}
+	return factors
+}
+
+// everyNthFrame keeps every nth frame, plus the last frame.
+func everyNthFrame(gameFrames []*engine.GameFrame, n int) []*engine.GameFrame {
+	var frames []*engine.GameFrame
+	for i, gf := range gameFrames {
+		if i%n == 0 || i == len(gameFrames)-1 {
+			frames = append(frames, gf)
+		}
+	}
+	return frames
+}
+
+// timelapseFrames keeps all of the frames close to an event, and skips frames in the quiet stretches in between.
+// The first and last frames are always kept.
+func timelapseFrames(gameFrames []*engine.GameFrame) []*engine.GameFrame {
+	busy := make([]bool, len(gameFrames))
+	for i := 1; i < len(gameFrames); i++ {
+		if len(frameEvents(gameFrames[i-1], gameFrames[i])) == 0 {
+			continue
+		}
+		for j := max(0, i-timelapseContext); j <= min(len(gameFrames)-1, i+timelapseContext); j++ {
+			busy[j] = true
+		}
+	}
+
+	// quiet frames are counted from the last frame that was kept, so quiet stretches start by skipping frames
+	var frames []*engine.GameFrame
+	lastKept := 0
+	for i, gf := range gameFrames {
+		if busy[i] || i == 0 || i == len(gameFrames)-1 || i-lastKept >= timelapseQuietStep {
+			frames = append(frames, gf)
+			lastKept = i
+		}
+	}
+	return frames
+}
@@ -0,0 +1,106 @@
+package render
+
+import (
+	"testing"
+
+	"github.com/BattlesnakeOfficial/exporter/engine"
+	"github.com/stretchr/testify/require"
+)
+
+func frameTurns(gameFrames []*engine.GameFrame) []int {
+	var turns []int
+	for _, gf := range gameFrames {
+		turns = append(turns, gf.Turn)
+	}
+	return turns
+}
+
+func TestEveryNthFrame(t *testing.T) {
+	_, gameFrames := movingSnakeFrames(8)
+	require.Equal(t, []int{0, 3, 6, 7}, frameTurns(everyNthFrame(gameFrames, 3)))
+	require.Equal(t, []int{0, 4, 7}, frameTurns(everyNthFrame(gameFrames, 4)))
+	require.Equal(t, []int{0, 7}, frameTurns(everyNthFrame(gameFrames, 100)))
+}
+
+func TestFrameEvents(t *testing.T) {
+	_, gameFrames := movingSnakeFrames(3)
+	require.Empty(t, frameEvents(gameFrames[0], gameFrames[1]))
+
+	// food under the snake's next head
+	gameFrames[0].Food = []engine.Point{gameFrames[1].Snakes[0].Body[0]}
+	require.Equal(t, []gameEvent{{Type: eventFoodEaten, SnakeID: "snake"}}, frameEvents(gameFrames[0], gameFrames[1]))
+
+	// deaths are only reported on the turn the snake died
+	gameFrames[1].Snakes[0].Death = &engine.Death{Cause: "wall-collision", Turn: 1}
+	gameFrames[2].Snakes[0].Death = gameFrames[1].Snakes[0].Death
+	require.Equal(t, []gameEvent{{Type: eventDeath, SnakeID: "snake"}}, frameEvents(gameFrames[0], gameFrames[1]))
+	require.Empty(t, frameEvents(gameFrames[1], gameFrames[2]))
+}
+
+func TestFrameEvents_HeadToHead(t *testing.T) {
+	prev := &engine.GameFrame{Snakes: []engine.Snake{
+		{ID: "one", Body: []engine.Point{{X: 1, Y: 1}, {X: 0, Y: 1}}},
+		{ID: "two", Body: []engine.Point{{X: 3, Y: 1}, {X: 4, Y: 1}}},
+	}}
+	cur := &engine.GameFrame{Snakes: []engine.Snake{
+		{ID: "one", Body: []engine.Point{{X: 2, Y: 1}, {X: 1, Y: 1}}, Death: &engine.Death{Cause: "snake-collision", Turn: 1}},
+		{ID: "two", Body: []engine.Point{{X: 2, Y: 1}, {X: 3, Y: 1}}, Death: &engine.Death{Cause: "head-collision", Turn: 1}},
+	}}
+	require.Equal(t, []gameEvent{{Type: eventHeadToHead, SnakeID: "one"}, {Type: eventHeadToHead, SnakeID: "two"}}, frameEvents(prev, cur))
+}
+
+func TestTimelapseFrames(t *testing.T) {
+	_, gameFrames := movingSnakeFrames(30)
+
+	// nothing happens, so only the quiet frames and the ends are kept
+	require.Equal(t, []int{0, 4, 8, 12, 16, 20, 24, 28, 29}, frameTurns(timelapseFrames(gameFrames)))
+
+	// food eaten on turn 15 keeps the frames around it
+	gameFrames[14].Food = []engine.Point{gameFrames[15].Snakes[0].Body[0]}
+	require.Equal(t, []int{0, 4, 8, 12, 13, 14, 15, 16, 17, 18, 22, 26, 29}, frameTurns(timelapseFrames(gameFrames)))
+}
+
+func TestPlaybackApply(t *testing.T) {
+	_, gameFrames := movingSnakeFrames(10)
+
+	frames, delays := DefaultPlayback.Apply(gameFrames)
+	require.Len(t, frames, 10)
+	require.Equal(t, uniformDelays(10, GIFFrameDelay, GIFLoopDelay), delays)
+
+	frames, delays = Playback{FrameDelay: 4, LoopDelay: 100, Step: 4}.Apply(gameFrames)
+	require.Equal(t, []int{0, 4, 8, 9}, frameTurns(frames))
+	require.Equal(t, []int{4, 4, 4, 100}, delays)
+}
+
+func TestPlaybackApply_SlowEvents(t *testing.T) {
+	_, gameFrames := movingSnakeFrames(10)
+	gameFrames[4].Food = []engine.Point{gameFrames[5].Snakes[0].Body[0]}
+	gameFrames[9].Snakes[0].Death = &engine.Death{Cause: "wall-collision", Turn: 9}
+
+	_, delays := Playback{FrameDelay: 4, LoopDelay: 10, SlowEvents: true}.Apply(gameFrames)
+	require.Equal(t, []int{4, 4, 4, 4, 8, 8, 8, 4, 12, 12}, delays)
+
+	// food eaten in a skipped frame slows down the next frame that's shown
+	frames, delays := Playback{FrameDelay: 4, LoopDelay: 100, Step: 3, SlowEvents: true}.Apply(gameFrames)
+	require.Equal(t, []int{0, 3, 6, 9}, frameTurns(frames))
+	require.Equal(t, []int{4, 8, 12, 100}, delays)
+}
+
+func TestPlaybackApply_Modes(t *testing.T) {
+	_, gameFrames := movingSnakeFrames(4)
+
+	frames, delays := Playback{FrameDelay: 4, LoopDelay: 100, Mode: PlaybackReverse}.Apply(gameFrames)
+	require.Equal(t, []int{3, 2, 1, 0}, frameTurns(frames))
+	require.Equal(t, []int{4, 4, 4, 100}, delays)
+
+	frames, delays = Playback{FrameDelay: 4, LoopDelay: 100, Mode: PlaybackBoomerang}.Apply(gameFrames)
+	require.Equal(t, []int{0, 1, 2, 3, 2, 1}, frameTurns(frames))
+	require.Equal(t, []int{4, 4, 4, 4, 4, 100}, delays)
+	// repeated frames are shared, so they can be rendered once
+	require.Same(t, frames[1], frames[5])
+
+	// slowed down frames stay slow when they're shown backward
+	gameFrames[1].Food = []engine.Point{gameFrames[2].Snakes[0].Body[0]}
+	_, delays = Playback{FrameDelay: 4, LoopDelay: 100, SlowEvents: true, Mode: PlaybackReverse}.Apply(gameFrames)
+	require.Equal(t, []int{8, 8, 8, 100}, delays)
+}
@@ -0,0 +1,122 @@
+package render
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"io"
+
+	"github.com/BattlesnakeOfficial/exporter/engine"
+)
+
+const pngSignature = "\x89PNG\r\n\x1a\n"
+
+const (
+	// pngMaxChunkLength is the longest any chunk can be, according to the PNG spec.
+	pngMaxChunkLength = 1<<31 - 1
+	// pngMaxTextLength is the longest text chunk that's read, which is far more than the metadata that's written.
+	pngMaxTextLength = 1 << 20
+)
+
+// pngTextChunk is an uncompressed text chunk (tEXt) in a PNG.
+type pngTextChunk struct {
+	// Keyword is 1-79 Latin-1 characters, such as "Comment".
+	Keyword string
+	Text    string
+}
+
+// GameFrameToPNG draws a game frame as a PNG.
+func GameFrameToPNG(ctx context.Context, w io.Writer, g *engine.Game, gf *engine.GameFrame, width, height int, settings DrawSettings) error {
+	img := DrawBoard(ctx, GameFrameToBoard(g, gf), width, height, settings)
+	return encodePNG(w, img, settings.Metadata.pngText())
+}
+
+// encodePNG encodes an image as a PNG, with text chunks after the header.
+func encodePNG(w io.Writer, img image.Image, text []pngTextChunk) error {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return err
+	}
+	if len(text) == 0 {
+		_, err := buf.WriteTo(w)
+		return err
+	}
+
+	// the image header is always the first chunk, and text chunks can go anywhere after it
+	encoded := buf.Bytes()
+	headerEnd := len(pngSignature) + 8 + 13 + 4
+	var out bytes.Buffer
+	out.Write(encoded[:headerEnd])
+	for _, chunk := range text {
+		if len(chunk.Keyword) < 1 || len(chunk.Keyword) > 79 {
+			return fmt.Errorf("invalid PNG text keyword: %q", chunk.Keyword)
+		}
+		writePNGChunk(&out, "tEXt", []byte(chunk.Keyword+"\x00"+chunk.Text))
+	}
+	out.Write(encoded[headerEnd:])
+	_, err := out.WriteTo(w)
+	return err
+}
+
+func writePNGChunk(w *bytes.Buffer, chunkType string, data []byte) {
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(len(data)))
+	w.Write(length[:])
+
+	crc := crc32.NewIEEE()
+	crc.Write([]byte(chunkType))
+	crc.Write(data)
+	w.WriteString(chunkType)
+	w.Write(data)
+
+	var sum [4]byte
+	binary.BigEndian.PutUint32(sum[:], crc.Sum32())
+	w.Write(sum[:])
+}
+
+// readPNGText reads the text chunks (tEXt) from a PNG, skipping all of the other chunks.
+func readPNGText(r io.Reader) ([]pngTextChunk, error) {
+	signature := make([]byte, len(pngSignature))
+	if _, err := io.ReadFull(r, signature); err != nil || string(signature) != pngSignature {
+		return nil, errors.New("not a PNG file")
+	}
+
+	var chunks []pngTextChunk
+	for {
+		var header [8]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return nil, fmt.Errorf("unable to read PNG chunk: %w", err)
+		}
+		length := int64(binary.BigEndian.Uint32(header[:4]))
+		chunkType := string(header[4:])
+		if length > pngMaxChunkLength || (chunkType == "tEXt" && length > pngMaxTextLength) {
+			return nil, fmt.Errorf("invalid PNG chunk: %s chunk is too long (%d bytes)", chunkType, length)
+		}
+
+		if chunkType != "tEXt" {
+			// skip the data and the CRC
+			if _, err := io.CopyN(io.Discard, r, length+4); err != nil {
+				return nil, fmt.Errorf("unable to read PNG chunk: %w", err)
+			}
+			if chunkType == "IEND" {
+				return chunks, nil
+			}
+			continue
+		}
+
+		data := make([]byte, length+4)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, fmt.Errorf("unable to read PNG chunk: %w", err)
+		}
+		keyword, text, ok := bytes.Cut(data[:length], []byte{0})
+		if !ok {
+			return nil, errors.New("invalid PNG text chunk")
+		}
+		chunks = append(chunks, pngTextChunk{Keyword: string(keyword), Text: string(text)})
+	}
+}
@@ -0,0 +1,73 @@
+package render
+
+import (
+	"fmt"
+
+	"github.com/BattlesnakeOfficial/exporter/engine"
+)
+
+// Viewport is a rectangular region of the game board, measured in board squares.
+// X and Y are the coordinates of the bottom-left square of the region.
+// The zero value represents the entire board.
+type Viewport struct {
+	X      int
+	Y      int
+	Width  int
+	Height int
+}
+
+// IsZero checks whether the viewport is unset, meaning the entire board is visible.
+func (v Viewport) IsZero() bool {
+	return v.Width == 0 || v.Height == 0
+}
+
+// Validate checks that the viewport fits within a board of the given width/height (in squares).
+func (v Viewport) Validate(boardWidth, boardHeight int) error {
+	if v.Width < 1 || v.Height < 1 {
+		return fmt.Errorf("invalid viewport %dx%d: width and height must be at least 1", v.Width, v.Height)
+	}
+	if v.X < 0 || v.Y < 0 {
+		return fmt.Errorf("invalid viewport position (%d,%d): cannot be < 0", v.X, v.Y)
+	}
+	if v.X+v.Width > boardWidth || v.Y+v.Height > boardHeight {
+		return fmt.Errorf("invalid viewport: region (%d,%d) %dx%d does not fit on a %dx%d board", v.X, v.Y, v.Width, v.Height, boardWidth, boardHeight)
+	}
+	return nil
+}
+
+// centreOn moves the viewport so that it is centred on the given point,
+// while making sure it stays within the bounds of the board.
+func (v Viewport) centreOn(p engine.Point, boardWidth, boardHeight int) Viewport {
+	v.X = clamp(p.X-v.Width/2, 0, boardWidth-v.Width)
+	v.Y = clamp(p.Y-v.Height/2, 0, boardHeight-v.Height)
+	return v
+}
+
+// findSnakeHead finds the location of the head of the snake with the given ID.
+// Snakes that have died are found where their head last was, so that following them doesn't jump away when they're no longer drawn.
+// The second return value is false if the snake isn't in the frame at all.
+func (b *Board) findSnakeHead(snakeID string) (engine.Point, bool) {
+	for p, s := range b.squares {
+		for _, c := range s.Contents {
+			if c.Type == BoardSquareSnakeHead && c.SnakeID == snakeID {
+				return p, true
+			}
+		}
+	}
+	p, ok := b.lastHeads[snakeID]
+	return p, ok
+}
+
+// crop creates a new board containing only the squares within the viewport.
+// Coordinates on the new board are relative to the bottom-left corner of the viewport.
+func (b *Board) crop(v Viewport) *Board {
+	cropped := NewBoard(v.Width, v.Height)
+	cropped.Turn = b.Turn
+	for p, s := range b.squares {
+		if p.X < v.X || p.X >= v.X+v.Width || p.Y < v.Y || p.Y >= v.Y+v.Height {
+			continue
+		}
+		cropped.squares[engine.Point{X: p.X - v.X, Y: p.Y - v.Y}] = s
+	}
+	return cropped
+}
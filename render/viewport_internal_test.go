@@ -0,0 +1,93 @@
+package render
+
+import (
+	"context"
+	"testing"
+
+	"github.com/BattlesnakeOfficial/exporter/engine"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestViewportValidate(t *testing.T) {
+	require.NoError(t, Viewport{X: 0, Y: 0, Width: 11, Height: 11}.Validate(11, 11))
+	require.NoError(t, Viewport{X: 14, Y: 14, Width: 11, Height: 11}.Validate(25, 25))
+	require.NoError(t, Viewport{X: 3, Y: 1, Width: 1, Height: 1}.Validate(11, 11))
+
+	require.Error(t, Viewport{X: 0, Y: 0, Width: 0, Height: 5}.Validate(11, 11), "width must be > 0")
+	require.Error(t, Viewport{X: -1, Y: 0, Width: 5, Height: 5}.Validate(11, 11), "position must be on the board")
+	require.Error(t, Viewport{X: 7, Y: 0, Width: 5, Height: 5}.Validate(11, 11), "region must fit on the board")
+	require.Error(t, Viewport{X: 0, Y: 0, Width: 12, Height: 11}.Validate(11, 11), "region must fit on the board")
+}
+
+func TestViewportCentreOn(t *testing.T) {
+	v := Viewport{Width: 5, Height: 5}
+
+	// middle of the board
+	assert.Equal(t, Viewport{X: 10, Y: 8, Width: 5, Height: 5}, v.centreOn(engine.Point{X: 12, Y: 10}, 25, 25))
+
+	// clamped to the bottom-left and top-right edges
+	assert.Equal(t, Viewport{X: 0, Y: 0, Width: 5, Height: 5}, v.centreOn(engine.Point{X: 1, Y: 0}, 25, 25))
+	assert.Equal(t, Viewport{X: 20, Y: 20, Width: 5, Height: 5}, v.centreOn(engine.Point{X: 24, Y: 23}, 25, 25))
+}
+
+func TestBoardCrop(t *testing.T) {
+	b := NewBoard(11, 11)
+	b.addFood(&engine.Point{X: 0, Y: 0})
+	b.addFood(&engine.Point{X: 5, Y: 6})
+	b.addHazard(&engine.Point{X: 7, Y: 8})
+	b.addHazard(&engine.Point{X: 8, Y: 8})
+
+	c := b.crop(Viewport{X: 4, Y: 5, Width: 4, Height: 4})
+	assert.Equal(t, 4, c.Width)
+	assert.Equal(t, 4, c.Height)
+	require.Len(t, c.squares, 2, "only the squares within the viewport should be kept")
+	require.Len(t, c.Contents(1, 1), 1)
+	assert.Equal(t, BoardSquareFood, c.Contents(1, 1)[0].Type)
+	require.Len(t, c.Contents(3, 3), 1)
+	assert.Equal(t, BoardSquareHazard, c.Contents(3, 3)[0].Type)
+}
+
+func TestDrawSettingsVisibleRegion(t *testing.T) {
+	b := NewBoard(25, 25)
+	b.placeSnake(engine.Snake{ID: "snake1", Body: []engine.Point{{X: 20, Y: 3}, {X: 19, Y: 3}, {X: 18, Y: 3}}})
+
+	// the whole board is visible by default
+	assert.True(t, DrawSettings{}.visibleRegion(b).IsZero())
+
+	// a fixed viewport doesn't move
+	v := Viewport{X: 2, Y: 2, Width: 11, Height: 11}
+	assert.Equal(t, v, DrawSettings{Viewport: v}.visibleRegion(b))
+
+	// following a snake centres on its head
+	assert.Equal(t, Viewport{X: 14, Y: 0, Width: 11, Height: 11}, DrawSettings{Viewport: v, Follow: "snake1"}.visibleRegion(b))
+
+	// following a snake that isn't on the board falls back to the fixed viewport
+	assert.Equal(t, v, DrawSettings{Viewport: v, Follow: "snake2"}.visibleRegion(b))
+}
+
+func TestDrawSettingsVisibleRegion_DeadSnake(t *testing.T) {
+	g := &engine.Game{Width: 25, Height: 25}
+	v := Viewport{X: 2, Y: 2, Width: 11, Height: 11}
+	settings := DrawSettings{Viewport: v, Follow: "snake1"}
+
+	// a snake that died long enough ago isn't drawn, but the viewport stays where its head last was
+	gf := &engine.GameFrame{Turn: 30, Snakes: []engine.Snake{{
+		ID:    "snake1",
+		Body:  []engine.Point{{X: 20, Y: 3}, {X: 19, Y: 3}, {X: 18, Y: 3}},
+		Death: &engine.Death{Turn: 12},
+	}}}
+	b := GameFrameToBoard(g, gf)
+	require.Empty(t, b.squares)
+	assert.Equal(t, Viewport{X: 14, Y: 0, Width: 11, Height: 11}, settings.visibleRegion(b))
+
+	// snakes that aren't in the frame at all still fall back to the fixed viewport
+	assert.Equal(t, v, DrawSettings{Viewport: v, Follow: "snake2"}.visibleRegion(b))
+}
+
+func TestDrawBoardViewport(t *testing.T) {
+	b := NewBoard(25, 25)
+	img := DrawBoard(context.Background(), b, 0, 0, DrawSettings{Viewport: Viewport{X: 0, Y: 0, Width: 5, Height: 7}})
+	assert.Equal(t, 5*20+int(BoardBorder)*2, img.Bounds().Dx(), "the default size should be based on the viewport")
+	assert.Equal(t, 7*20+int(BoardBorder)*2, img.Bounds().Dy(), "the default size should be based on the viewport")
+}
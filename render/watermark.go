@@ -0,0 +1,137 @@
+package render
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+
+	"github.com/BattlesnakeOfficial/exporter/media"
+	log "github.com/sirupsen/logrus"
+)
+
+// WatermarkPosition is the location on the board where the watermark is drawn.
+type WatermarkPosition string
+
+const (
+	WatermarkCenter      WatermarkPosition = "center"
+	WatermarkTopLeft     WatermarkPosition = "top-left"
+	WatermarkTopRight    WatermarkPosition = "top-right"
+	WatermarkBottomLeft  WatermarkPosition = "bottom-left"
+	WatermarkBottomRight WatermarkPosition = "bottom-right"
+)
+
+// WatermarkPositions lists all the valid watermark positions.
+var WatermarkPositions = []WatermarkPosition{
+	WatermarkCenter,
+	WatermarkTopLeft,
+	WatermarkTopRight,
+	WatermarkBottomLeft,
+	WatermarkBottomRight,
+}
+
+// Watermark configures the branding image that is drawn underneath the board contents.
+type Watermark struct {
+	// Asset is the name of the branding asset to draw (see media.BrandingAssets).
+	// When empty, no watermark is drawn.
+	Asset string
+	// Opacity of the watermark, from 0 (invisible) to 1 (as is).
+	Opacity float64
+	// Position of the watermark on the board.
+	Position WatermarkPosition
+}
+
+// DefaultWatermark is the watermark used when none is specified in the draw settings.
+var DefaultWatermark = Watermark{
+	Asset:    media.DefaultBranding,
+	Opacity:  1,
+	Position: WatermarkCenter,
+}
+
+// NoWatermark disables drawing a watermark.
+var NoWatermark = Watermark{}
+
+// cacheKey creates a key that is unique to the appearance of the watermark.
+func (wm Watermark) cacheKey() string {
+	if wm.Asset == "" {
+		return "none"
+	}
+	// the opacity is keyed by the alpha it's drawn with, so that opacities that look the same share a cached background
+	return fmt.Sprintf("%s:%d:%s", wm.Asset, opacityAlpha(wm.Opacity), wm.Position)
+}
+
+// sizeFraction is the size of the watermark, relative to the largest square that fits on the board.
+func (wm Watermark) sizeFraction() float64 {
+	if wm.Position == WatermarkCenter || wm.Position == "" {
+		return 2.0 / 3.0
+	}
+	// corner watermarks are kept small, so they don't get in the way
+	return 1.0 / 4.0
+}
+
+func drawWatermarkLayer(b *Board, dc *BoardContext, settings DrawSettings) {
+	drawWatermark(dc, settings.watermark())
+}
+
+func drawWatermark(dc *BoardContext, wm Watermark) {
+	if wm.Asset == "" || wm.Opacity <= 0 {
+		return
+	}
+
+	// The watermark is a square image.
+	// We want to scale it relative to the maximum size of square that can fit within the board.
+	wmSize := int(float64(min(dc.boardWidthPx, dc.boardHeightPx)) * wm.sizeFraction())
+	if wmSize < 1 {
+		return
+	}
+
+	watermarkImage, err := media.GetBrandingPNG(wm.Asset, wmSize, wmSize)
+	if err != nil {
+		log.WithError(err).WithField("asset", wm.Asset).Error("Unable to load watermark image")
+		return
+	}
+	watermarkImage = applyOpacity(watermarkImage, wm.Opacity)
+
+	// corner watermarks are inset by half a square, so they don't touch the board border
+	inset := int(BoardBorder) + dc.squareSizePx/2
+	left := dc.boardOffsetX + inset
+	right := dc.boardOffsetX + dc.boardWidthPx - inset
+	top := dc.boardOffsetY + inset
+	bottom := dc.boardOffsetY + dc.boardHeightPx - inset
+
+	switch wm.Position {
+	case WatermarkTopLeft:
+		dc.DrawImageAnchored(watermarkImage, left, top, 0, 0)
+	case WatermarkTopRight:
+		dc.DrawImageAnchored(watermarkImage, right, top, 1, 0)
+	case WatermarkBottomLeft:
+		dc.DrawImageAnchored(watermarkImage, left, bottom, 0, 1)
+	case WatermarkBottomRight:
+		dc.DrawImageAnchored(watermarkImage, right, bottom, 1, 1)
+	default:
+		dc.DrawImageAnchored(watermarkImage, dc.Width()/2, dc.Height()/2, 0.5, 0.5)
+	}
+}
+
+// opacityAlpha is the alpha that an opacity, from 0 to 1, is drawn with.
+func opacityAlpha(opacity float64) uint8 {
+	if opacity <= 0 {
+		return 0
+	}
+	if opacity >= 1 {
+		return 0xff
+	}
+	return uint8(opacity * 0xff)
+}
+
+// applyOpacity scales the alpha channel of the image by the given opacity.
+func applyOpacity(src image.Image, opacity float64) image.Image {
+	if opacity >= 1 {
+		return src
+	}
+
+	dst := image.NewNRGBA(src.Bounds())
+	mask := image.NewUniform(color.Alpha{A: opacityAlpha(opacity)})
+	draw.DrawMask(dst, dst.Bounds(), src, src.Bounds().Min, mask, image.Point{}, draw.Over)
+	return dst
+}
@@ -0,0 +1,59 @@
+package render
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/BattlesnakeOfficial/exporter/parse"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatermarkCacheKey(t *testing.T) {
+	assert.Equal(t, "none", NoWatermark.cacheKey())
+	assert.Equal(t, "default:255:center", DefaultWatermark.cacheKey())
+	assert.Equal(t, "league:127:top-left", Watermark{Asset: "league", Opacity: 0.5, Position: WatermarkTopLeft}.cacheKey())
+	// opacities that are drawn the same share a cached background, so varying them doesn't fill the cache
+	assert.Equal(t, Watermark{Asset: "league", Opacity: 0.5}.cacheKey(), Watermark{Asset: "league", Opacity: 0.5000001}.cacheKey())
+	// opacities that only differ slightly are still drawn differently, so they can't share a cached background
+	assert.NotEqual(t, Watermark{Asset: "league", Opacity: 0.501}.cacheKey(), Watermark{Asset: "league", Opacity: 0.504}.cacheKey())
+}
+
+func TestDrawSettingsWatermark(t *testing.T) {
+	assert.Equal(t, DefaultWatermark, DrawSettings{}.watermark())
+
+	wm := Watermark{Asset: "league", Opacity: 0.5, Position: WatermarkBottomRight}
+	assert.Equal(t, wm, DrawSettings{Watermark: &wm}.watermark())
+}
+
+func TestApplyOpacity(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+	src.Set(0, 0, color.NRGBA{0xff, 0x00, 0x00, 0xff})
+	src.Set(1, 1, color.NRGBA{0x00, 0xff, 0x00, 0x80})
+
+	// fully opaque is a no-op
+	assert.Same(t, src, applyOpacity(src, 1))
+
+	dst := applyOpacity(src, 0.5)
+	assert.Equal(t, color.NRGBA{0xff, 0x00, 0x00, 0x7f}, dst.At(0, 0))
+	assert.Equal(t, uint8(0x3f), dst.At(1, 1).(color.NRGBA).A)
+	assert.Equal(t, uint8(0x00), dst.At(1, 0).(color.NRGBA).A)
+}
+
+func TestDrawBoardNoWatermark(t *testing.T) {
+	b := NewBoard(3, 3)
+	noWatermark := NoWatermark
+
+	// 3 squares of 20px + border on each side, so the middle pixel is in the centre square
+	img := DrawBoard(context.Background(), b, 0, 0, DrawSettings{Watermark: &noWatermark})
+	require.Equal(t, 64, img.Bounds().Dx())
+	assertSameColor(t, parse.HexColor(ColorEmptySquare), img.At(32, 32))
+}
+
+func assertSameColor(t *testing.T, want, got color.Color) {
+	r1, g1, b1, a1 := want.RGBA()
+	r2, g2, b2, a2 := got.RGBA()
+	assert.Equal(t, []uint32{r1, g1, b1, a1}, []uint32{r2, g2, b2, a2})
+}
@@ -0,0 +1,379 @@
+package svg
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// segmentKind is the type of a path segment, after it has been converted to absolute coordinates.
+type segmentKind int
+
+const (
+	segmentMove segmentKind = iota
+	segmentLine
+	segmentCubic
+	segmentQuadratic
+	segmentClose
+)
+
+// segment is a single step of a path, in absolute user coordinates.
+// Points holds 1 point for moves and lines, 2 for quadratic curves and 3 for cubic curves.
+type segment struct {
+	Kind   segmentKind
+	Points []point
+}
+
+type point struct {
+	X, Y float64
+}
+
+// pathBuilder collects the segments of a path, tracking the current point like an SVG path does.
+type pathBuilder struct {
+	segments []segment
+	current  point
+	start    point
+	// open is whether a subpath has been started, so that lines after a close start a new subpath
+	open bool
+}
+
+func (b *pathBuilder) moveTo(p point) {
+	b.segments = append(b.segments, segment{Kind: segmentMove, Points: []point{p}})
+	b.current, b.start, b.open = p, p, true
+}
+
+// ensureSubpath starts a new subpath at the current point, for segments that follow a close.
+func (b *pathBuilder) ensureSubpath() {
+	if !b.open {
+		b.moveTo(b.current)
+	}
+}
+
+func (b *pathBuilder) lineTo(p point) {
+	b.ensureSubpath()
+	b.segments = append(b.segments, segment{Kind: segmentLine, Points: []point{p}})
+	b.current = p
+}
+
+func (b *pathBuilder) cubicTo(c1, c2, p point) {
+	b.ensureSubpath()
+	b.segments = append(b.segments, segment{Kind: segmentCubic, Points: []point{c1, c2, p}})
+	b.current = p
+}
+
+func (b *pathBuilder) quadraticTo(c, p point) {
+	b.ensureSubpath()
+	b.segments = append(b.segments, segment{Kind: segmentQuadratic, Points: []point{c, p}})
+	b.current = p
+}
+
+func (b *pathBuilder) close() {
+	if !b.open {
+		return
+	}
+	b.segments = append(b.segments, segment{Kind: segmentClose})
+	b.current, b.open = b.start, false
+}
+
+// arcTo adds an elliptical arc, using the endpoint parameters from SVG path data.
+// The arc is approximated with cubic curves.
+func (b *pathBuilder) arcTo(rx, ry, rotation float64, largeArc, sweep bool, p point) {
+	from := b.current
+	if from == p {
+		return
+	}
+	rx, ry = math.Abs(rx), math.Abs(ry)
+	if rx == 0 || ry == 0 {
+		b.lineTo(p)
+		return
+	}
+
+	// convert to the centre parameterization (SVG spec, appendix B.2.4)
+	phi := rotation * math.Pi / 180
+	sinPhi, cosPhi := math.Sincos(phi)
+	dx, dy := (from.X-p.X)/2, (from.Y-p.Y)/2
+	x1 := cosPhi*dx + sinPhi*dy
+	y1 := -sinPhi*dx + cosPhi*dy
+
+	// scale up radii that are too small to reach the end point
+	if lambda := (x1*x1)/(rx*rx) + (y1*y1)/(ry*ry); lambda > 1 {
+		s := math.Sqrt(lambda)
+		rx, ry = rx*s, ry*s
+	}
+
+	num := rx*rx*ry*ry - rx*rx*y1*y1 - ry*ry*x1*x1
+	den := rx*rx*y1*y1 + ry*ry*x1*x1
+	coef := 0.0
+	if num > 0 && den > 0 {
+		coef = math.Sqrt(num / den)
+	}
+	if largeArc == sweep {
+		coef = -coef
+	}
+	cx1 := coef * rx * y1 / ry
+	cy1 := -coef * ry * x1 / rx
+	cx := cosPhi*cx1 - sinPhi*cy1 + (from.X+p.X)/2
+	cy := sinPhi*cx1 + cosPhi*cy1 + (from.Y+p.Y)/2
+
+	theta1 := vectorAngle(1, 0, (x1-cx1)/rx, (y1-cy1)/ry)
+	delta := vectorAngle((x1-cx1)/rx, (y1-cy1)/ry, (-x1-cx1)/rx, (-y1-cy1)/ry)
+	if !sweep && delta > 0 {
+		delta -= 2 * math.Pi
+	} else if sweep && delta < 0 {
+		delta += 2 * math.Pi
+	}
+
+	// each cubic covers at most a quarter of the ellipse
+	n := int(math.Ceil(math.Abs(delta) / (math.Pi / 2)))
+	step := delta / float64(n)
+	k := 4.0 / 3.0 * math.Tan(step/4)
+	onEllipse := func(theta float64) (point, point) {
+		sin, cos := math.Sincos(theta)
+		pt := point{cx + rx*cos*cosPhi - ry*sin*sinPhi, cy + rx*cos*sinPhi + ry*sin*cosPhi}
+		tangent := point{-rx*sin*cosPhi - ry*cos*sinPhi, -rx*sin*sinPhi + ry*cos*cosPhi}
+		return pt, tangent
+	}
+
+	theta := theta1
+	p1, t1 := onEllipse(theta)
+	for i := 0; i < n; i++ {
+		p2, t2 := onEllipse(theta + step)
+		if i == n-1 {
+			// end exactly on the requested point, regardless of rounding errors
+			p2 = p
+		}
+		b.cubicTo(point{p1.X + k*t1.X, p1.Y + k*t1.Y}, point{p2.X - k*t2.X, p2.Y - k*t2.Y}, p2)
+		theta += step
+		p1, t1 = p2, t2
+	}
+}
+
+// vectorAngle is the signed angle from vector u to vector v.
+func vectorAngle(ux, uy, vx, vy float64) float64 {
+	return math.Atan2(ux*vy-uy*vx, ux*vx+uy*vy)
+}
+
+// parsePathData parses the "d" attribute of a path element.
+// Path data with an error is drawn up to the error, as the SVG spec requires, and the error is returned.
+func parsePathData(d string) ([]segment, error) {
+	s := &pathScanner{data: d}
+	b := &pathBuilder{}
+
+	var command byte
+	// the previous control point, for smooth curves
+	var lastControl point
+	var lastCommand byte
+
+	for {
+		s.skipSeparators()
+		if s.done() {
+			return b.segments, nil
+		}
+
+		if c := s.peek(); isCommand(c) {
+			command = c
+			s.pos++
+		} else if command == 0 {
+			return b.segments, fmt.Errorf("path data must start with a command: %q", d)
+		} else if command == 'M' {
+			// coordinates after a move are implicit lines
+			command = 'L'
+		} else if command == 'm' {
+			command = 'l'
+		} else if command == 'z' || command == 'Z' {
+			return b.segments, fmt.Errorf("unexpected number after close in path data: %q", d)
+		}
+
+		relative := command >= 'a'
+		abs := func(x, y float64) point {
+			if relative {
+				return point{b.current.X + x, b.current.Y + y}
+			}
+			return point{x, y}
+		}
+
+		var err error
+		var nums []float64
+		switch command {
+		case 'M', 'm':
+			if nums, err = s.numbers(2); err == nil {
+				b.moveTo(abs(nums[0], nums[1]))
+			}
+		case 'L', 'l':
+			if nums, err = s.numbers(2); err == nil {
+				b.lineTo(abs(nums[0], nums[1]))
+			}
+		case 'H', 'h':
+			if nums, err = s.numbers(1); err == nil {
+				x := nums[0]
+				if relative {
+					x += b.current.X
+				}
+				b.lineTo(point{x, b.current.Y})
+			}
+		case 'V', 'v':
+			if nums, err = s.numbers(1); err == nil {
+				y := nums[0]
+				if relative {
+					y += b.current.Y
+				}
+				b.lineTo(point{b.current.X, y})
+			}
+		case 'C', 'c':
+			if nums, err = s.numbers(6); err == nil {
+				c1, c2, p := abs(nums[0], nums[1]), abs(nums[2], nums[3]), abs(nums[4], nums[5])
+				b.cubicTo(c1, c2, p)
+				lastControl = c2
+			}
+		case 'S', 's':
+			if nums, err = s.numbers(4); err == nil {
+				c1 := b.current
+				if lastCommand == 'C' || lastCommand == 'S' {
+					c1 = reflect(lastControl, b.current)
+				}
+				c2, p := abs(nums[0], nums[1]), abs(nums[2], nums[3])
+				b.cubicTo(c1, c2, p)
+				lastControl = c2
+			}
+		case 'Q', 'q':
+			if nums, err = s.numbers(4); err == nil {
+				c, p := abs(nums[0], nums[1]), abs(nums[2], nums[3])
+				b.quadraticTo(c, p)
+				lastControl = c
+			}
+		case 'T', 't':
+			if nums, err = s.numbers(2); err == nil {
+				c := b.current
+				if lastCommand == 'Q' || lastCommand == 'T' {
+					c = reflect(lastControl, b.current)
+				}
+				b.quadraticTo(c, abs(nums[0], nums[1]))
+				lastControl = c
+			}
+		case 'A', 'a':
+			var rx, ry, rotation float64
+			var largeArc, sweep bool
+			if nums, err = s.numbers(3); err == nil {
+				rx, ry, rotation = nums[0], nums[1], nums[2]
+				if largeArc, err = s.flag(); err == nil {
+					if sweep, err = s.flag(); err == nil {
+						if nums, err = s.numbers(2); err == nil {
+							b.arcTo(rx, ry, rotation, largeArc, sweep, abs(nums[0], nums[1]))
+						}
+					}
+				}
+			}
+		case 'Z', 'z':
+			b.close()
+		}
+		if err != nil {
+			return b.segments, fmt.Errorf("invalid path data %q: %w", d, err)
+		}
+		lastCommand = command
+		if lastCommand >= 'a' {
+			lastCommand -= 'a' - 'A'
+		}
+	}
+}
+
+// reflect reflects a control point around the current point, for smooth curves.
+func reflect(control, current point) point {
+	return point{2*current.X - control.X, 2*current.Y - control.Y}
+}
+
+func isCommand(c byte) bool {
+	switch c {
+	case 'M', 'm', 'L', 'l', 'H', 'h', 'V', 'v', 'C', 'c', 'S', 's', 'Q', 'q', 'T', 't', 'A', 'a', 'Z', 'z':
+		return true
+	}
+	return false
+}
+
+// pathScanner reads numbers from path data, which can be separated by whitespace, commas,
+// or nothing at all when the next number starts with a sign or a second decimal point ("1-2.5.5").
+type pathScanner struct {
+	data string
+	pos  int
+}
+
+func (s *pathScanner) done() bool {
+	return s.pos >= len(s.data)
+}
+
+func (s *pathScanner) peek() byte {
+	return s.data[s.pos]
+}
+
+func (s *pathScanner) skipSeparators() {
+	for !s.done() {
+		switch s.peek() {
+		case ' ', '\t', '\n', '\r', '\f', ',':
+			s.pos++
+		default:
+			return
+		}
+	}
+}
+
+func (s *pathScanner) numbers(n int) ([]float64, error) {
+	nums := make([]float64, n)
+	for i := range nums {
+		v, err := s.number()
+		if err != nil {
+			return nil, err
+		}
+		nums[i] = v
+	}
+	return nums, nil
+}
+
+func (s *pathScanner) number() (float64, error) {
+	s.skipSeparators()
+	start := s.pos
+	if !s.done() && (s.peek() == '+' || s.peek() == '-') {
+		s.pos++
+	}
+	digits, dot := false, false
+	for !s.done() {
+		c := s.peek()
+		if c >= '0' && c <= '9' {
+			digits = true
+		} else if c == '.' && !dot {
+			dot = true
+		} else {
+			break
+		}
+		s.pos++
+	}
+	if digits && !s.done() && (s.peek() == 'e' || s.peek() == 'E') {
+		// only treat this as an exponent if digits follow, since "e" isn't a path command
+		end := s.pos + 1
+		if end < len(s.data) && (s.data[end] == '+' || s.data[end] == '-') {
+			end++
+		}
+		if end < len(s.data) && s.data[end] >= '0' && s.data[end] <= '9' {
+			s.pos = end
+			for !s.done() && s.peek() >= '0' && s.peek() <= '9' {
+				s.pos++
+			}
+		}
+	}
+	if !digits {
+		return 0, fmt.Errorf("expected a number at position %d", start)
+	}
+	return strconv.ParseFloat(s.data[start:s.pos], 64)
+}
+
+// flag reads an arc flag, which is a single 0 or 1 that doesn't need a separator after it.
+func (s *pathScanner) flag() (bool, error) {
+	s.skipSeparators()
+	if s.done() {
+		return false, fmt.Errorf("expected a flag at position %d", s.pos)
+	}
+	c := s.peek()
+	if c != '0' && c != '1' {
+		return false, fmt.Errorf("expected a flag at position %d", s.pos)
+	}
+	s.pos++
+	return c == '1', nil
+}
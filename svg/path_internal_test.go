@@ -0,0 +1,112 @@
+package svg
+
+import (
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePathData(t *testing.T) {
+	segments, err := parsePathData("M10 10h10v10H10zm5,5l1-1.5.5.5e1")
+	require.NoError(t, err)
+	require.Equal(t, []segment{
+		{Kind: segmentMove, Points: []point{{10, 10}}},
+		{Kind: segmentLine, Points: []point{{20, 10}}},
+		{Kind: segmentLine, Points: []point{{20, 20}}},
+		{Kind: segmentLine, Points: []point{{10, 20}}},
+		{Kind: segmentClose},
+		// relative to the start of the closed subpath
+		{Kind: segmentMove, Points: []point{{15, 15}}},
+		{Kind: segmentLine, Points: []point{{16, 13.5}}},
+		{Kind: segmentLine, Points: []point{{16.5, 18.5}}},
+	}, segments)
+
+	// implicit lines after a move, and smooth curves reflecting the last control point
+	segments, err = parsePathData("M0 0 10 0 C 10 5 15 10 20 10 S 30 15 30 20 Q 40 20 40 30 T 40 50")
+	require.NoError(t, err)
+	require.Equal(t, []segment{
+		{Kind: segmentMove, Points: []point{{0, 0}}},
+		{Kind: segmentLine, Points: []point{{10, 0}}},
+		{Kind: segmentCubic, Points: []point{{10, 5}, {15, 10}, {20, 10}}},
+		{Kind: segmentCubic, Points: []point{{25, 10}, {30, 15}, {30, 20}}},
+		{Kind: segmentQuadratic, Points: []point{{40, 20}, {40, 30}}},
+		{Kind: segmentQuadratic, Points: []point{{40, 40}, {40, 50}}},
+	}, segments)
+
+	// errors keep the segments before the error
+	segments, err = parsePathData("M0 0 L10 10 L 5")
+	require.Error(t, err)
+	require.Len(t, segments, 2)
+
+	_, err = parsePathData("10 10")
+	require.Error(t, err)
+}
+
+func TestParsePathDataArc(t *testing.T) {
+	// a half circle, with flags that don't need separators
+	segments, err := parsePathData("M0 0a5 5 0 1120 0")
+	require.NoError(t, err)
+	require.Len(t, segments, 3)
+	assert.Equal(t, point{20, 0}, segments[2].Points[2])
+
+	// the radius is too small to reach the end point, so it's scaled up to a half circle
+	segments, err = parsePathData("M0 0A1 1 0 0 1 20 0")
+	require.NoError(t, err)
+	require.Len(t, segments, 3)
+	mid := segments[1].Points[2]
+	assert.InDelta(t, 10, mid.X, 1e-9)
+	assert.InDelta(t, -10, mid.Y, 1e-9, "a clockwise sweep from left to right goes through the top")
+
+	// the curve stays on the circle
+	for _, s := range segments[1:] {
+		p := s.Points[2]
+		assert.InDelta(t, 10, math.Hypot(p.X-10, p.Y), 1e-9)
+	}
+}
+
+func TestParseTransform(t *testing.T) {
+	m, err := parseTransform("translate(10, 20) scale(2)")
+	require.NoError(t, err)
+	assert.Equal(t, point{12, 22}, m.apply(point{1, 1}))
+
+	m, err = parseTransform("rotate(90 10 10)")
+	require.NoError(t, err)
+	p := m.apply(point{20, 10})
+	assert.InDelta(t, 10, p.X, 1e-9)
+	assert.InDelta(t, 20, p.Y, 1e-9)
+
+	m, err = parseTransform("scale(-1, 1) translate(-100, 0)")
+	require.NoError(t, err)
+	assert.Equal(t, point{90, 5}, m.apply(point{10, 5}))
+
+	_, err = parseTransform("rotate(45")
+	require.Error(t, err)
+	_, err = parseTransform("translate(1, 2, 3)")
+	require.Error(t, err)
+}
+
+func TestParseColor(t *testing.T) {
+	for input, expected := range map[string]paint{
+		"#0ca":               {color: color.RGBA{0x00, 0xcc, 0xaa, 0xff}},
+		"#00CCAA":            {color: color.RGBA{0x00, 0xcc, 0xaa, 0xff}},
+		"rgb(0, 204, 170)":   {color: color.RGBA{0x00, 0xcc, 0xaa, 0xff}},
+		"rgb(0%, 100%, 0%)":  {color: color.RGBA{0x00, 0xff, 0x00, 0xff}},
+		"White":              {color: color.RGBA{0xff, 0xff, 0xff, 0xff}},
+		"none":               {none: true},
+		"currentColor":       {current: true},
+		"rgba(255, 0, 0, 1)": {color: color.RGBA{0xff, 0x00, 0x00, 0xff}},
+		"rgba(255, 0, 0, 0)": {color: color.RGBA{0x00, 0x00, 0x00, 0x00}},
+	} {
+		p, err := parsePaint(input)
+		require.NoError(t, err, input)
+		assert.Equal(t, expected, p, input)
+	}
+
+	for _, input := range []string{"#12", "#1234567", "url(#gradient)", "notacolor"} {
+		_, err := parsePaint(input)
+		assert.Error(t, err, input)
+	}
+}
@@ -0,0 +1,487 @@
+package svg
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/fogleman/gg"
+)
+
+// Rasterizer renders SVG images in-process, as an alternative to the inkscape CLI.
+//
+// It supports the subset of SVG used by Battlesnake customizations: nested svg elements with viewBox,
+// groups, transforms, the basic shapes, paths, and fill/stroke presentation attributes (including the style attribute).
+// Anything else, such as text, gradients, CSS stylesheets and references with use, isn't drawn.
+type Rasterizer struct{}
+
+// IsAvailable is always true, because the rasterizer doesn't need anything outside of this process.
+func (Rasterizer) IsAvailable() bool {
+	return true
+}
+
+// SVGToPNG rasterizes the SVG at the specified path.
+// Rendering stops if the context is done before it's finished.
+func (r Rasterizer) SVGToPNG(ctx context.Context, path string, width, height int) (image.Image, error) {
+	if height < 1 {
+		return nil, errors.New("invalid height")
+	}
+	if width < 1 {
+		return nil, errors.New("invalid width")
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return rasterize(ctx, f, width, height)
+}
+
+// SVGStringToPNG rasterizes the provided SVG text.
+// Rendering stops if the context is done before it's finished.
+func (r Rasterizer) SVGStringToPNG(ctx context.Context, svgText string, width, height int) (image.Image, error) {
+	if height < 1 {
+		return nil, errors.New("invalid height")
+	}
+	if width < 1 {
+		return nil, errors.New("invalid width")
+	}
+
+	return rasterize(ctx, strings.NewReader(svgText), width, height)
+}
+
+var (
+	// ErrInvalidSVG is returned when the document can't be parsed.
+	ErrInvalidSVG = errors.New("invalid SVG")
+	// ErrNotSVG is returned when the document doesn't have an svg root element.
+	ErrNotSVG = fmt.Errorf("%w: not an SVG document", ErrInvalidSVG)
+)
+
+// elementState is what an element passes down to its children.
+type elementState struct {
+	// skip is whether the element and everything inside it isn't drawn
+	skip  bool
+	style style
+	// transform maps the element's user coordinates to pixels
+	transform matrix
+	// viewport is the size of the nearest viewport in user coordinates, for percentage lengths
+	viewport point
+}
+
+// rasterize draws the SVG document to an image of the given size, scaling the root viewBox to fit.
+func rasterize(ctx context.Context, r io.Reader, width, height int) (image.Image, error) {
+	dc := gg.NewContext(width, height)
+	decoder := xml.NewDecoder(r)
+	decoder.Strict = false
+
+	var stack []elementState
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidSVG, err)
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			attrs := make(map[string]string, len(t.Attr))
+			for _, a := range t.Attr {
+				if a.Name.Space == "" || a.Name.Space == "http://www.w3.org/2000/svg" {
+					attrs[a.Name.Local] = a.Value
+				}
+			}
+
+			if len(stack) == 0 {
+				if t.Name.Local != "svg" {
+					return nil, ErrNotSVG
+				}
+				stack = append(stack, rootState(attrs, float64(width), float64(height)))
+				continue
+			}
+
+			parent := stack[len(stack)-1]
+			state := elementState{skip: true}
+			if !parent.skip {
+				if state, err = childState(ctx, dc, parent, t.Name.Local, attrs); err != nil {
+					return nil, err
+				}
+			}
+			stack = append(stack, state)
+		case xml.EndElement:
+			if len(stack) == 0 {
+				break
+			}
+			stack = stack[:len(stack)-1]
+			if len(stack) == 0 {
+				// anything after the root element is ignored
+				return dc.Image(), nil
+			}
+		}
+	}
+
+	if stack == nil {
+		return nil, ErrNotSVG
+	}
+	return dc.Image(), nil
+}
+
+// rootState scales the root element's viewBox to the output image.
+// A root element without a viewBox uses its width and height as the viewBox, like inkscape does when exporting at a given size.
+// Unlike nested svg elements, a transform on the root applies inside the viewBox, which is how CustomizeSnakeSVG flips images.
+func rootState(attrs map[string]string, width, height float64) elementState {
+	viewBox, ok := parseViewBox(attrs["viewBox"])
+	if !ok {
+		viewBox = [4]float64{0, 0, width, height}
+		if w, err := parseLength(attrs["width"], width); err == nil && w > 0 {
+			viewBox[2] = w
+		}
+		if h, err := parseLength(attrs["height"], height); err == nil && h > 0 {
+			viewBox[3] = h
+		}
+	}
+
+	m := viewBoxTransform(viewBox, attrs["preserveAspectRatio"], width, height)
+	if t, err := parseTransform(attrs["transform"]); err == nil {
+		m = m.multiply(t)
+	}
+
+	s := defaultStyle.inherit(attrs)
+	return elementState{
+		skip:      !s.display,
+		style:     s,
+		transform: m,
+		viewport:  point{viewBox[2], viewBox[3]},
+	}
+}
+
+// childState draws an element if it's a shape, and works out what it passes down to its children.
+// An error is only returned if the context is done while drawing.
+func childState(ctx context.Context, dc *gg.Context, parent elementState, name string, attrs map[string]string) (elementState, error) {
+	s := parent.style.inherit(attrs)
+	m := parent.transform
+	if t, err := parseTransform(attrs["transform"]); err == nil {
+		m = m.multiply(t)
+	}
+	state := elementState{skip: !s.display, style: s, transform: m, viewport: parent.viewport}
+	if state.skip {
+		return state, nil
+	}
+
+	length := func(name string, relativeTo float64) float64 {
+		v, err := parseLength(attrs[name], relativeTo)
+		if err != nil {
+			return 0
+		}
+		return v
+	}
+	vw, vh := parent.viewport.X, parent.viewport.Y
+	// lengths that aren't horizontal or vertical are relative to the normalized diagonal
+	vd := math.Hypot(vw, vh) / math.Sqrt2
+
+	var segments []segment
+	switch name {
+	case "svg":
+		x, y := length("x", vw), length("y", vh)
+		w, h := vw, vh
+		if _, ok := attrs["width"]; ok {
+			w = length("width", vw)
+		}
+		if _, ok := attrs["height"]; ok {
+			h = length("height", vh)
+		}
+		if w <= 0 || h <= 0 {
+			state.skip = true
+			return state, nil
+		}
+		viewBox, ok := parseViewBox(attrs["viewBox"])
+		if !ok {
+			viewBox = [4]float64{0, 0, w, h}
+		}
+		state.transform = m.multiply(translate(x, y)).multiply(viewBoxTransform(viewBox, attrs["preserveAspectRatio"], w, h))
+		state.viewport = point{viewBox[2], viewBox[3]}
+		return state, nil
+	case "g", "a":
+		return state, nil
+	case "path":
+		// path data with an error is drawn up to the error
+		segments, _ = parsePathData(attrs["d"])
+	case "rect":
+		segments = rectSegments(length("x", vw), length("y", vh), length("width", vw), length("height", vh), attrs, vw, vh)
+	case "circle":
+		r := length("r", vd)
+		segments = ellipseSegments(length("cx", vw), length("cy", vh), r, r)
+	case "ellipse":
+		segments = ellipseSegments(length("cx", vw), length("cy", vh), length("rx", vw), length("ry", vh))
+	case "line":
+		b := &pathBuilder{}
+		b.moveTo(point{length("x1", vw), length("y1", vh)})
+		b.lineTo(point{length("x2", vw), length("y2", vh)})
+		segments = b.segments
+	case "polyline", "polygon":
+		segments = polySegments(attrs["points"], name == "polygon")
+	default:
+		// unsupported elements, and elements that are never drawn directly like defs and title
+		state.skip = true
+		return state, nil
+	}
+
+	// shapes can only contain things like title and desc, which aren't drawn
+	state.skip = true
+	return state, drawShape(ctx, dc, segments, s, m)
+}
+
+// parseViewBox parses a viewBox attribute, which must have a positive width and height.
+func parseViewBox(s string) ([4]float64, bool) {
+	nums, err := parseNumberList(s)
+	if err != nil || len(nums) != 4 || nums[2] <= 0 || nums[3] <= 0 {
+		return [4]float64{}, false
+	}
+	return [4]float64{nums[0], nums[1], nums[2], nums[3]}, true
+}
+
+// viewBoxTransform maps a viewBox onto a viewport of the given size, following the preserveAspectRatio rules.
+func viewBoxTransform(viewBox [4]float64, preserveAspectRatio string, width, height float64) matrix {
+	sx, sy := width/viewBox[2], height/viewBox[3]
+
+	fields := strings.Fields(preserveAspectRatio)
+	align := "xMidYMid"
+	if len(fields) > 0 {
+		align = fields[0]
+	}
+	if align == "none" {
+		return scale(sx, sy).multiply(translate(-viewBox[0], -viewBox[1]))
+	}
+
+	s := math.Min(sx, sy)
+	if len(fields) > 1 && fields[1] == "slice" {
+		s = math.Max(sx, sy)
+	}
+	tx, ty := 0.0, 0.0
+	switch {
+	case strings.HasPrefix(align, "xMid"):
+		tx = (width - viewBox[2]*s) / 2
+	case strings.HasPrefix(align, "xMax"):
+		tx = width - viewBox[2]*s
+	}
+	switch {
+	case strings.HasSuffix(align, "YMid"):
+		ty = (height - viewBox[3]*s) / 2
+	case strings.HasSuffix(align, "YMax"):
+		ty = height - viewBox[3]*s
+	}
+	return translate(tx, ty).multiply(scale(s, s)).multiply(translate(-viewBox[0], -viewBox[1]))
+}
+
+// rectSegments builds a rectangle, with rounded corners when rx or ry is set.
+func rectSegments(x, y, w, h float64, attrs map[string]string, vw, vh float64) []segment {
+	if w <= 0 || h <= 0 {
+		return nil
+	}
+
+	rx, errX := parseLength(attrs["rx"], vw)
+	ry, errY := parseLength(attrs["ry"], vh)
+	// a missing or invalid radius uses the other one
+	switch {
+	case errX != nil && errY != nil:
+		rx, ry = 0, 0
+	case errX != nil:
+		rx = ry
+	case errY != nil:
+		ry = rx
+	}
+	rx = math.Min(math.Max(rx, 0), w/2)
+	ry = math.Min(math.Max(ry, 0), h/2)
+
+	b := &pathBuilder{}
+	if rx == 0 || ry == 0 {
+		b.moveTo(point{x, y})
+		b.lineTo(point{x + w, y})
+		b.lineTo(point{x + w, y + h})
+		b.lineTo(point{x, y + h})
+		b.close()
+		return b.segments
+	}
+
+	b.moveTo(point{x + rx, y})
+	b.lineTo(point{x + w - rx, y})
+	b.arcTo(rx, ry, 0, false, true, point{x + w, y + ry})
+	b.lineTo(point{x + w, y + h - ry})
+	b.arcTo(rx, ry, 0, false, true, point{x + w - rx, y + h})
+	b.lineTo(point{x + rx, y + h})
+	b.arcTo(rx, ry, 0, false, true, point{x, y + h - ry})
+	b.lineTo(point{x, y + ry})
+	b.arcTo(rx, ry, 0, false, true, point{x + rx, y})
+	b.close()
+	return b.segments
+}
+
+func ellipseSegments(cx, cy, rx, ry float64) []segment {
+	if rx <= 0 || ry <= 0 {
+		return nil
+	}
+
+	b := &pathBuilder{}
+	b.moveTo(point{cx + rx, cy})
+	b.arcTo(rx, ry, 0, false, true, point{cx - rx, cy})
+	b.arcTo(rx, ry, 0, false, true, point{cx + rx, cy})
+	b.close()
+	return b.segments
+}
+
+// polySegments builds a polyline or polygon from a points attribute.
+// A trailing odd coordinate is ignored, as the SVG spec requires.
+func polySegments(points string, closed bool) []segment {
+	nums, err := parseNumberList(points)
+	if err != nil || len(nums) < 4 {
+		return nil
+	}
+
+	b := &pathBuilder{}
+	b.moveTo(point{nums[0], nums[1]})
+	for i := 2; i+1 < len(nums); i += 2 {
+		b.lineTo(point{nums[i], nums[i+1]})
+	}
+	if closed {
+		b.close()
+	}
+	return b.segments
+}
+
+const (
+	// maxShapeExtent is how far shapes can reach outside of the image, as a multiple of the image size.
+	maxShapeExtent = 8
+	// maxShapePoints is how many points shapes can be flattened into, as a multiple of the image's width plus height.
+	maxShapePoints = 1024
+	// shapeContextCheckInterval is how many segments are drawn between checking whether the context is done.
+	shapeContextCheckInterval = 64
+)
+
+// drawShape fills then strokes the segments, transforming them to pixels.
+// The points are transformed here rather than with the context's matrix, so the stroke width has to be scaled to match.
+// Shapes that are far bigger than the image, like a circle with a huge radius, are skipped,
+// because curves are flattened into a point for each pixel of their length.
+func drawShape(ctx context.Context, dc *gg.Context, segments []segment, s style, m matrix) error {
+	if len(segments) == 0 {
+		return nil
+	}
+
+	transformed := make([]segment, len(segments))
+	for i, seg := range segments {
+		pts := make([]point, len(seg.Points))
+		for j, p := range seg.Points {
+			pts[j] = m.apply(p)
+		}
+		transformed[i] = segment{Kind: seg.Kind, Points: pts}
+	}
+	if !shapeFits(transformed, dc.Width(), dc.Height()) {
+		return nil
+	}
+
+	for i, seg := range transformed {
+		if i%shapeContextCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				dc.ClearPath()
+				return err
+			}
+		}
+		pts := seg.Points
+		switch seg.Kind {
+		case segmentMove:
+			dc.MoveTo(pts[0].X, pts[0].Y)
+		case segmentLine:
+			dc.LineTo(pts[0].X, pts[0].Y)
+		case segmentCubic:
+			dc.CubicTo(pts[0].X, pts[0].Y, pts[1].X, pts[1].Y, pts[2].X, pts[2].Y)
+		case segmentQuadratic:
+			dc.QuadraticTo(pts[0].X, pts[0].Y, pts[1].X, pts[1].Y)
+		case segmentClose:
+			dc.ClosePath()
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		dc.ClearPath()
+		return err
+	}
+
+	if !s.fill.none {
+		if s.fillRule == "evenodd" {
+			dc.SetFillRuleEvenOdd()
+		} else {
+			dc.SetFillRuleWinding()
+		}
+		dc.SetColor(s.paintColor(s.fill, s.fillOpacity))
+		dc.FillPreserve()
+		// filling big shapes is slow, so stop before stroking them too
+		if err := ctx.Err(); err != nil {
+			dc.ClearPath()
+			return err
+		}
+	}
+
+	if width := s.strokeWidth * m.scale(); !s.stroke.none && width > 0 {
+		dc.SetLineWidth(width)
+		switch s.lineCap {
+		case "round":
+			dc.SetLineCapRound()
+		case "square":
+			dc.SetLineCapSquare()
+		default:
+			dc.SetLineCapButt()
+		}
+		// gg doesn't support miter joins, so bevel is the closest match
+		if s.lineJoin == "round" {
+			dc.SetLineJoinRound()
+		} else {
+			dc.SetLineJoinBevel()
+		}
+		dc.SetColor(s.paintColor(s.stroke, s.strokeOpacity))
+		dc.StrokePreserve()
+	}
+
+	dc.ClearPath()
+	return nil
+}
+
+// shapeFits checks that a shape, in pixels, is close enough to the size of the image to be drawn.
+// Curves are flattened into about as many points as the length of their control points, the same as gg does.
+func shapeFits(segments []segment, width, height int) bool {
+	w, h := float64(width), float64(height)
+	minX, minY := -maxShapeExtent*w, -maxShapeExtent*h
+	maxX, maxY := (maxShapeExtent+1)*w, (maxShapeExtent+1)*h
+
+	points := 0.0
+	var current point
+	for _, seg := range segments {
+		for _, p := range seg.Points {
+			// NaN fails these checks too
+			if !(p.X >= minX && p.X <= maxX && p.Y >= minY && p.Y <= maxY) {
+				return false
+			}
+		}
+		switch seg.Kind {
+		case segmentCubic:
+			c1, c2, p := seg.Points[0], seg.Points[1], seg.Points[2]
+			points += math.Hypot(c1.X-current.X, c1.Y-current.Y) + math.Hypot(c2.X-c1.X, c2.Y-c1.Y) + math.Hypot(p.X-c2.X, p.Y-c2.Y)
+		case segmentQuadratic:
+			c, p := seg.Points[0], seg.Points[1]
+			points += math.Hypot(c.X-current.X, c.Y-current.Y) + math.Hypot(p.X-c.X, p.Y-c.Y)
+		}
+		if len(seg.Points) > 0 {
+			current = seg.Points[len(seg.Points)-1]
+		}
+	}
+	return points <= maxShapePoints*(w+h)
+}
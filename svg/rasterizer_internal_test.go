@@ -0,0 +1,217 @@
+package svg
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fogleman/gg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const headSVG = `<svg id="root" viewBox="0 0 100 100" xmlns="http://www.w3.org/2000/svg" fill="#00ccaa">
+<circle fill="none" cx="12.52" cy="28.55" r="9.26"/>
+<path d="M0 100h100L56 55.39l44-39.89V.11L0 0zm12.52-80.71a9.26 9.26 0 1 1-9.26 9.26 9.26 9.26 0 0 1 9.26-9.26z"/>
+</svg>`
+
+const tailSVG = `<svg id="root" viewBox="0 0 100 100" xmlns="http://www.w3.org/2000/svg" fill="#00ccaa">
+<path d="M50 0H0v100h50l50-50L50 0z"/>
+</svg>`
+
+var (
+	teal        = color.NRGBA{0x00, 0xcc, 0xaa, 0xff}
+	transparent = color.NRGBA{}
+)
+
+// assertPixel checks the colour at a point, which should be away from any edges so that antialiasing doesn't matter.
+func assertPixel(t *testing.T, img image.Image, x, y int, expected color.NRGBA) {
+	t.Helper()
+	actual := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+	assert.Equal(t, expected, actual, "pixel at (%d, %d)", x, y)
+}
+
+func TestRasterizeHead(t *testing.T) {
+	img, err := Rasterizer{}.SVGStringToPNG(context.Background(), headSVG, 100, 100)
+	require.NoError(t, err)
+	require.Equal(t, image.Rect(0, 0, 100, 100), img.Bounds())
+
+	assertPixel(t, img, 50, 90, teal)
+	assertPixel(t, img, 50, 10, teal)
+	// the eye is a hole in the head
+	assertPixel(t, img, 12, 28, transparent)
+	// the mouth
+	assertPixel(t, img, 90, 50, transparent)
+}
+
+func TestRasterizeTail(t *testing.T) {
+	img, err := Rasterizer{}.SVGStringToPNG(context.Background(), tailSVG, 20, 20)
+	require.NoError(t, err)
+	require.Equal(t, image.Rect(0, 0, 20, 20), img.Bounds())
+
+	assertPixel(t, img, 5, 10, teal)
+	assertPixel(t, img, 15, 10, teal)
+	assertPixel(t, img, 18, 2, transparent)
+	assertPixel(t, img, 18, 17, transparent)
+}
+
+func TestRasterizeFlipped(t *testing.T) {
+	flipped := `<svg viewBox="0 0 100 100" xmlns="http://www.w3.org/2000/svg" fill="#00ccaa" transform="scale(-1, 1) translate(-100, 0)">
+<path d="M50 0H0v100h50l50-50L50 0z"/>
+</svg>`
+	img, err := Rasterizer{}.SVGStringToPNG(context.Background(), flipped, 20, 20)
+	require.NoError(t, err)
+
+	assertPixel(t, img, 15, 10, teal)
+	assertPixel(t, img, 2, 2, transparent)
+	assertPixel(t, img, 2, 17, transparent)
+}
+
+func TestRasterizeAvatar(t *testing.T) {
+	// the same structure as render.AvatarSVG creates
+	avatar := `<svg id="root" xmlns="http://www.w3.org/2000/svg" fill="#00ccaa" width="60" height="20">
+	<!-- Copyright -->
+	<g transform="scale(-1, 1) translate(-20, 0)">
+		<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 100 100" width="20" height="20">
+			<path d="M50 0H0v100h50l50-50L50 0z"/>
+		</svg>
+	</g>
+	<g transform="translate(20, 0)">
+		<rect width="20" height="20" />
+	</g>
+	<g transform="translate(40, 0)">
+		<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 100 100" width="20" height="20">
+			<path d="M50 0H0v100h50l50-50L50 0z"/>
+		</svg>
+	</g>
+</svg>`
+	img, err := Rasterizer{}.SVGStringToPNG(context.Background(), avatar, 60, 20)
+	require.NoError(t, err)
+	require.Equal(t, image.Rect(0, 0, 60, 20), img.Bounds())
+
+	// the tail is flipped, so it points left
+	assertPixel(t, img, 2, 2, transparent)
+	assertPixel(t, img, 15, 2, teal)
+	assertPixel(t, img, 30, 10, teal)
+	assertPixel(t, img, 45, 2, teal)
+	assertPixel(t, img, 58, 2, transparent)
+}
+
+func TestRasterizeShapes(t *testing.T) {
+	shapes := `<svg viewBox="0 0 100 100" xmlns="http://www.w3.org/2000/svg">
+<title>not drawn</title>
+<defs><rect id="hidden" width="100" height="100" fill="red"/></defs>
+<rect x="0" y="0" width="50" height="50" rx="10" style="fill: rgb(255, 0, 0)"/>
+<circle cx="75" cy="25" r="20" fill="blue"/>
+<ellipse cx="25" cy="75" rx="20" ry="10" fill="#0f0" opacity="0.5"/>
+<g fill="white" transform="rotate(45 75 75)">
+	<polygon points="70,70 80,70 80,80 70,80"/>
+</g>
+<line x1="0" y1="99" x2="100" y2="99" stroke="black" stroke-width="2"/>
+<rect x="90" y="50" width="10" height="10" display="none"/>
+</svg>`
+	img, err := Rasterizer{}.SVGStringToPNG(context.Background(), shapes, 100, 100)
+	require.NoError(t, err)
+
+	assertPixel(t, img, 25, 25, color.NRGBA{0xff, 0x00, 0x00, 0xff})
+	// the rounded corner
+	assertPixel(t, img, 1, 1, transparent)
+	assertPixel(t, img, 75, 25, color.NRGBA{0x00, 0x00, 0xff, 0xff})
+	assertPixel(t, img, 90, 8, transparent)
+	assertPixel(t, img, 25, 75, color.NRGBA{0x00, 0xff, 0x00, 0x80})
+	assertPixel(t, img, 25, 60, transparent)
+	assertPixel(t, img, 75, 75, color.NRGBA{0xff, 0xff, 0xff, 0xff})
+	// the corner of the rotated square is inside the unrotated square, but not the rotated one
+	assertPixel(t, img, 70, 70, transparent)
+	assertPixel(t, img, 50, 99, color.NRGBA{0x00, 0x00, 0x00, 0xff})
+	assertPixel(t, img, 95, 55, transparent)
+}
+
+func TestRasterizeAspectRatio(t *testing.T) {
+	square := `<svg viewBox="0 0 10 10" xmlns="http://www.w3.org/2000/svg"><rect width="10" height="10"/></svg>`
+
+	// the viewBox is centred by default
+	img, err := Rasterizer{}.SVGStringToPNG(context.Background(), square, 40, 20)
+	require.NoError(t, err)
+	assertPixel(t, img, 5, 10, transparent)
+	assertPixel(t, img, 20, 10, color.NRGBA{0x00, 0x00, 0x00, 0xff})
+	assertPixel(t, img, 35, 10, transparent)
+
+	stretched := `<svg viewBox="0 0 10 10" preserveAspectRatio="none" xmlns="http://www.w3.org/2000/svg"><rect width="10" height="10"/></svg>`
+	img, err = Rasterizer{}.SVGStringToPNG(context.Background(), stretched, 40, 20)
+	require.NoError(t, err)
+	assertPixel(t, img, 5, 10, color.NRGBA{0x00, 0x00, 0x00, 0xff})
+	assertPixel(t, img, 35, 10, color.NRGBA{0x00, 0x00, 0x00, 0xff})
+}
+
+func TestRasterizerErrors(t *testing.T) {
+	r := Rasterizer{}
+	assert.True(t, r.IsAvailable())
+
+	_, err := r.SVGStringToPNG(context.Background(), tailSVG, 0, 20)
+	require.Equal(t, errors.New("invalid width"), err)
+	_, err = r.SVGStringToPNG(context.Background(), tailSVG, 20, 0)
+	require.Equal(t, errors.New("invalid height"), err)
+	_, err = r.SVGToPNG(context.Background(), "does/not/exist.svg", 20, 20)
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	_, err = r.SVGStringToPNG(context.Background(), "<html></html>", 20, 20)
+	require.ErrorIs(t, err, ErrNotSVG)
+	_, err = r.SVGStringToPNG(context.Background(), "", 20, 20)
+	require.ErrorIs(t, err, ErrNotSVG)
+	_, err = r.SVGStringToPNG(context.Background(), "<svg><path d='M0 0", 20, 20)
+	require.ErrorIs(t, err, ErrInvalidSVG)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = r.SVGStringToPNG(ctx, tailSVG, 20, 20)
+	require.ErrorIs(t, err, context.Canceled)
+
+	// make sure it doesn't panic with strange/bad inputs
+	for _, svg := range []string{
+		"afe9*#@(#f2038208",
+		"<svg><foo></>",
+		`<svg viewBox="0 0 0 0"><rect width="-1" height="NaN" rx="x"/></svg>`,
+		`<svg><path d="M 10 10 A 0 0 0 1 1 20 20 L"/><polygon points="1"/><circle r="-5"/></svg>`,
+		`<svg><g transform="rotate(45"><rect width="10" height="10" fill="#12"/></g></svg>`,
+	} {
+		_, _ = r.SVGStringToPNG(context.Background(), svg, 20, 20)
+	}
+}
+
+func TestRasterizeHugeShapes(t *testing.T) {
+	// flattening this circle into lines would need billions of points
+	img, err := Rasterizer{}.SVGStringToPNG(context.Background(), `<svg viewBox="0 0 1 1"><circle r="1e9" stroke="red" stroke-width="1"/></svg>`, 20, 20)
+	require.NoError(t, err)
+	assertPixel(t, img, 10, 10, transparent)
+
+	// shapes that go a little outside of the image are still drawn
+	img, err = Rasterizer{}.SVGStringToPNG(context.Background(), `<svg viewBox="0 0 10 10"><circle cx="5" cy="5" r="20" fill="#00ccaa"/></svg>`, 20, 20)
+	require.NoError(t, err)
+	assertPixel(t, img, 10, 10, teal)
+}
+
+func TestDrawShapeCancelled(t *testing.T) {
+	segments, err := parsePathData("M 0 0 C 10 0 10 10 0 10 Z")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	dc := gg.NewContext(20, 20)
+	err = drawShape(ctx, dc, segments, defaultStyle, scale(1, 1))
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestRasterizerSVGToPNG(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tail.svg")
+	require.NoError(t, os.WriteFile(path, []byte(tailSVG), 0o600))
+
+	img, err := Rasterizer{}.SVGToPNG(context.Background(), path, 20, 20)
+	require.NoError(t, err)
+	require.Equal(t, image.Rect(0, 0, 20, 20), img.Bounds())
+	assertPixel(t, img, 5, 10, teal)
+}
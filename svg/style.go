@@ -0,0 +1,323 @@
+package svg
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/BattlesnakeOfficial/exporter/parse"
+)
+
+// matrix is a 2D affine transform, in the same order as the SVG matrix(a b c d e f) transform.
+type matrix struct {
+	A, B, C, D, E, F float64
+}
+
+var identity = matrix{A: 1, D: 1}
+
+// multiply applies n first, then m.
+func (m matrix) multiply(n matrix) matrix {
+	return matrix{
+		A: m.A*n.A + m.C*n.B,
+		B: m.B*n.A + m.D*n.B,
+		C: m.A*n.C + m.C*n.D,
+		D: m.B*n.C + m.D*n.D,
+		E: m.A*n.E + m.C*n.F + m.E,
+		F: m.B*n.E + m.D*n.F + m.F,
+	}
+}
+
+func (m matrix) apply(p point) point {
+	return point{m.A*p.X + m.C*p.Y + m.E, m.B*p.X + m.D*p.Y + m.F}
+}
+
+// scale is the average amount lengths are scaled by, which is used for stroke widths.
+func (m matrix) scale() float64 {
+	return math.Sqrt(math.Abs(m.A*m.D - m.B*m.C))
+}
+
+func translate(x, y float64) matrix { return matrix{A: 1, D: 1, E: x, F: y} }
+func scale(x, y float64) matrix     { return matrix{A: x, D: y} }
+
+var reTransform = regexp.MustCompile(`\s*(matrix|translate|scale|rotate|skewX|skewY)\s*\(([^)]*)\)\s*,?`)
+
+// parseTransform parses a transform attribute, which is a list of transforms applied from right to left.
+func parseTransform(s string) (matrix, error) {
+	m := identity
+	rest := strings.TrimSpace(s)
+	for rest != "" {
+		loc := reTransform.FindStringSubmatchIndex(rest)
+		if loc == nil || loc[0] != 0 {
+			return identity, fmt.Errorf("invalid transform: %q", s)
+		}
+		name := rest[loc[2]:loc[3]]
+		args, err := parseNumberList(rest[loc[4]:loc[5]])
+		if err != nil {
+			return identity, fmt.Errorf("invalid transform: %q", s)
+		}
+		rest = rest[loc[1]:]
+
+		var t matrix
+		switch {
+		case name == "matrix" && len(args) == 6:
+			t = matrix{args[0], args[1], args[2], args[3], args[4], args[5]}
+		case name == "translate" && len(args) == 1:
+			t = translate(args[0], 0)
+		case name == "translate" && len(args) == 2:
+			t = translate(args[0], args[1])
+		case name == "scale" && len(args) == 1:
+			t = scale(args[0], args[0])
+		case name == "scale" && len(args) == 2:
+			t = scale(args[0], args[1])
+		case name == "rotate" && (len(args) == 1 || len(args) == 3):
+			sin, cos := math.Sincos(args[0] * math.Pi / 180)
+			t = matrix{A: cos, B: sin, C: -sin, D: cos}
+			if len(args) == 3 {
+				t = translate(args[1], args[2]).multiply(t).multiply(translate(-args[1], -args[2]))
+			}
+		case name == "skewX" && len(args) == 1:
+			t = matrix{A: 1, C: math.Tan(args[0] * math.Pi / 180), D: 1}
+		case name == "skewY" && len(args) == 1:
+			t = matrix{A: 1, B: math.Tan(args[0] * math.Pi / 180), D: 1}
+		default:
+			return identity, fmt.Errorf("invalid transform: %q", s)
+		}
+		m = m.multiply(t)
+	}
+	return m, nil
+}
+
+// parseNumberList parses numbers separated by whitespace and/or commas.
+func parseNumberList(s string) ([]float64, error) {
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n' || r == '\r'
+	})
+	nums := make([]float64, len(fields))
+	for i, f := range fields {
+		v, err := parseFloat(f)
+		if err != nil {
+			return nil, err
+		}
+		nums[i] = v
+	}
+	return nums, nil
+}
+
+// parseLength parses a length in user units, where percentages are relative to the given size.
+// Units other than px aren't supported, since customization SVGs don't use them.
+func parseLength(s string, relativeTo float64) (float64, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasSuffix(s, "%") {
+		v, err := parseFloat(strings.TrimSuffix(s, "%"))
+		return v / 100 * relativeTo, err
+	}
+	return parseFloat(strings.TrimSuffix(s, "px"))
+}
+
+// parseFloat parses a finite number, since strconv also accepts things like "NaN" and "Inf".
+func parseFloat(s string) (float64, error) {
+	v, err := strconv.ParseFloat(s, 64)
+	if err == nil && (math.IsNaN(v) || math.IsInf(v, 0)) {
+		return 0, fmt.Errorf("invalid number: %q", s)
+	}
+	return v, err
+}
+
+// paint is how a shape is filled or stroked.
+type paint struct {
+	none bool
+	// current uses the value of the color property
+	current bool
+	color   color.RGBA
+}
+
+var reHexColor = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+var reRGBColor = regexp.MustCompile(`^rgba?\(\s*([0-9.]+%?)\s*,\s*([0-9.]+%?)\s*,\s*([0-9.]+%?)\s*(?:,\s*([0-9.]+)\s*)?\)$`)
+
+// namedColors are the colour keywords that are likely to be used by customizations.
+var namedColors = map[string]color.RGBA{
+	"black":   {0x00, 0x00, 0x00, 0xff},
+	"white":   {0xff, 0xff, 0xff, 0xff},
+	"red":     {0xff, 0x00, 0x00, 0xff},
+	"green":   {0x00, 0x80, 0x00, 0xff},
+	"blue":    {0x00, 0x00, 0xff, 0xff},
+	"yellow":  {0xff, 0xff, 0x00, 0xff},
+	"orange":  {0xff, 0xa5, 0x00, 0xff},
+	"purple":  {0x80, 0x00, 0x80, 0xff},
+	"pink":    {0xff, 0xc0, 0xcb, 0xff},
+	"gray":    {0x80, 0x80, 0x80, 0xff},
+	"grey":    {0x80, 0x80, 0x80, 0xff},
+	"silver":  {0xc0, 0xc0, 0xc0, 0xff},
+	"lime":    {0x00, 0xff, 0x00, 0xff},
+	"navy":    {0x00, 0x00, 0x80, 0xff},
+	"teal":    {0x00, 0x80, 0x80, 0xff},
+	"maroon":  {0x80, 0x00, 0x00, 0xff},
+	"olive":   {0x80, 0x80, 0x00, 0xff},
+	"aqua":    {0x00, 0xff, 0xff, 0xff},
+	"cyan":    {0x00, 0xff, 0xff, 0xff},
+	"fuchsia": {0xff, 0x00, 0xff, 0xff},
+	"magenta": {0xff, 0x00, 0xff, 0xff},
+}
+
+func parsePaint(s string) (paint, error) {
+	s = strings.TrimSpace(s)
+	switch strings.ToLower(s) {
+	case "none", "transparent":
+		return paint{none: true}, nil
+	case "currentcolor":
+		return paint{current: true}, nil
+	}
+	c, err := parseColor(s)
+	return paint{color: c}, err
+}
+
+func parseColor(s string) (color.RGBA, error) {
+	s = strings.TrimSpace(s)
+	if reHexColor.MatchString(s) {
+		return color.RGBAModel.Convert(parse.HexColor(s)).(color.RGBA), nil
+	}
+	if c, ok := namedColors[strings.ToLower(s)]; ok {
+		return c, nil
+	}
+	if m := reRGBColor.FindStringSubmatch(s); m != nil {
+		channel := func(v string) uint8 {
+			if strings.HasSuffix(v, "%") {
+				f, _ := strconv.ParseFloat(strings.TrimSuffix(v, "%"), 64)
+				return uint8(math.Round(math.Min(f, 100) / 100 * 255))
+			}
+			f, _ := strconv.ParseFloat(v, 64)
+			return uint8(math.Min(f, 255))
+		}
+		c := color.NRGBA{channel(m[1]), channel(m[2]), channel(m[3]), 0xff}
+		if m[4] != "" {
+			a, _ := strconv.ParseFloat(m[4], 64)
+			c.A = uint8(math.Round(math.Min(a, 1) * 255))
+		}
+		return color.RGBAModel.Convert(c).(color.RGBA), nil
+	}
+	return color.RGBA{}, fmt.Errorf("unsupported color: %q", s)
+}
+
+// style is the set of inherited presentation properties that affect how shapes are drawn.
+type style struct {
+	fill          paint
+	fillOpacity   float64
+	fillRule      string
+	stroke        paint
+	strokeOpacity float64
+	strokeWidth   float64
+	lineCap       string
+	lineJoin      string
+	color         color.RGBA
+	// opacity is the product of the opacity of every element above the shape.
+	// This is an approximation, since real group opacity composites the group as a whole.
+	opacity float64
+	display bool
+}
+
+// defaultStyle is the initial value of each property, from the SVG spec.
+var defaultStyle = style{
+	fill:          paint{color: color.RGBA{0, 0, 0, 0xff}},
+	fillOpacity:   1,
+	fillRule:      "nonzero",
+	stroke:        paint{none: true},
+	strokeOpacity: 1,
+	strokeWidth:   1,
+	lineCap:       "butt",
+	lineJoin:      "miter",
+	color:         color.RGBA{0, 0, 0, 0xff},
+	opacity:       1,
+	display:       true,
+}
+
+// properties gets the presentation properties of an element, where the style attribute overrides the other attributes.
+func properties(attrs map[string]string) map[string]string {
+	props := make(map[string]string, len(attrs))
+	for k, v := range attrs {
+		props[k] = v
+	}
+	for _, decl := range strings.Split(attrs["style"], ";") {
+		k, v, ok := strings.Cut(decl, ":")
+		if !ok {
+			continue
+		}
+		props[strings.TrimSpace(k)] = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(v), "!important"))
+	}
+	return props
+}
+
+// inherit applies an element's presentation properties on top of the style inherited from its parent.
+// Invalid values are ignored, so the inherited value is used instead.
+func (s style) inherit(attrs map[string]string) style {
+	props := properties(attrs)
+	number := func(name string, v *float64) {
+		if p, ok := props[name]; ok {
+			if f, err := parseFloat(strings.TrimSpace(p)); err == nil {
+				*v = math.Max(0, f)
+			}
+		}
+	}
+
+	if p, ok := props["color"]; ok {
+		if c, err := parseColor(p); err == nil {
+			s.color = c
+		}
+	}
+	if p, ok := props["fill"]; ok {
+		if f, err := parsePaint(p); err == nil {
+			s.fill = f
+		}
+	}
+	if p, ok := props["stroke"]; ok {
+		if f, err := parsePaint(p); err == nil {
+			s.stroke = f
+		}
+	}
+	number("fill-opacity", &s.fillOpacity)
+	number("stroke-opacity", &s.strokeOpacity)
+	if p, ok := props["stroke-width"]; ok {
+		if w, err := parseLength(p, 0); err == nil {
+			s.strokeWidth = math.Max(0, w)
+		}
+	}
+	if p := props["fill-rule"]; p == "nonzero" || p == "evenodd" {
+		s.fillRule = p
+	}
+	if p := props["stroke-linecap"]; p == "butt" || p == "round" || p == "square" {
+		s.lineCap = p
+	}
+	if p := props["stroke-linejoin"]; p == "miter" || p == "round" || p == "bevel" {
+		s.lineJoin = p
+	}
+
+	// opacity isn't inherited, but applies to everything inside the element
+	opacity := 1.0
+	number("opacity", &opacity)
+	s.opacity *= math.Min(opacity, 1)
+	if props["display"] == "none" || props["visibility"] == "hidden" {
+		s.display = false
+	}
+	return s
+}
+
+// paintColor resolves the colour for a paint, including its opacity.
+func (s style) paintColor(p paint, opacity float64) color.NRGBA {
+	c := p.color
+	if p.current {
+		c = s.color
+	}
+	alpha := float64(c.A) / 0xff * math.Min(opacity, 1) * s.opacity
+	if c.A == 0 {
+		return color.NRGBA{}
+	}
+	// the colours are opaque or premultiplied, so un-premultiply them
+	return color.NRGBA{
+		R: uint8(uint32(c.R) * 0xff / uint32(c.A)),
+		G: uint8(uint32(c.G) * 0xff / uint32(c.A)),
+		B: uint8(uint32(c.B) * 0xff / uint32(c.A)),
+		A: uint8(math.Round(alpha * 0xff)),
+	}
+}